@@ -0,0 +1,661 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// annotation export formats for -format
+const (
+	annotationsXFDF = "xfdf"
+	annotationsJSON = "json"
+)
+
+// xfdfNamespace is the XML namespace of XFDF documents
+const xfdfNamespace = "http://ns.adobe.com/xfdf/"
+
+// annotationFlags are the names of the annotation flag bits, from bit 1, as XFDF writes them
+var annotationFlags = []string{"invisible", "hidden", "print", "nozoom", "norotate", "noview", "readonly", "locked", "togglenoview", "lockedcontents"}
+
+// exportedAnnotation is an annotation as exported, in a form both XFDF and JSON are written from
+type exportedAnnotation struct {
+	Page          int         `json:"page"` //1-based
+	Type          string      `json:"type"` //the subtype, e.g. "Text" or "Highlight"
+	Rect          []float64   `json:"rect,omitempty"`
+	Name          string      `json:"name,omitempty"`
+	Author        string      `json:"author,omitempty"`
+	Subject       string      `json:"subject,omitempty"`
+	Contents      string      `json:"contents,omitempty"`
+	Modified      string      `json:"modified,omitempty"` //PDF date, e.g. "D:20240701093000Z"
+	Created       string      `json:"created,omitempty"`
+	Color         string      `json:"color,omitempty"` //"#rrggbb"
+	Flags         []string    `json:"flags,omitempty"`
+	InReplyTo     string      `json:"in_reply_to,omitempty"` //name of the annotation replied to
+	Icon          string      `json:"icon,omitempty"`        //of Text, Stamp and FileAttachment annotations
+	QuadPoints    []float64   `json:"quad_points,omitempty"` //of text markup annotations
+	Line          []float64   `json:"line,omitempty"`        //x1, y1, x2, y2 of Line annotations
+	Vertices      []float64   `json:"vertices,omitempty"`    //of Polygon and PolyLine annotations
+	InkList       [][]float64 `json:"ink_list,omitempty"`    //of Ink annotations
+	Appearance    string      `json:"default_appearance,omitempty"`
+	PopupRect     []float64   `json:"popup_rect,omitempty"`
+	PopupOpen     bool        `json:"popup_open,omitempty"`
+	InteriorColor string      `json:"interior_color,omitempty"`
+}
+
+// runAnnotations runs an annotations subcommand
+func runAnnotations(args []string) {
+	if len(args) > 0 && args[0] == "export" {
+		runAnnotationsExport(args[1:])
+	} else if len(args) > 0 && args[0] == "import" {
+		runAnnotationsImport(args[1:])
+	} else {
+		fmt.Fprintln(os.Stderr, "Usage of pdf-splitter annotations: export|import [flags] input.pdf")
+		exit(exitUsage)
+	}
+}
+
+// runAnnotationsExport writes the annotations of a PDF, with the pages they are on, to an XFDF or
+// JSON file, so review comments are kept before the PDF is flattened or split
+func runAnnotationsExport(args []string) {
+	fs := flag.NewFlagSet("annotations export", flag.ExitOnError)
+	output := outputFlags(fs)
+	out := fs.String("out", "", "XFDF or JSON file to write the annotations to")
+	format := fs.String("format", "", "export `format`: \"xfdf\" or \"json\" (default json for a .json -out, otherwise xfdf)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter annotations export: -out annotations.xfdf [-format xfdf|json] input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("annotations export", output)
+	defer finishOutput()
+
+	if *out == "" || fs.NArg() != 1 {
+		usage(fs)
+	}
+	if *format == "" {
+		*format = annotationsXFDF
+		if strings.EqualFold(filepath.Ext(*out), ".json") {
+			*format = annotationsJSON
+		}
+	}
+	if *format != annotationsXFDF && *format != annotationsJSON {
+		argError("-format must be xfdf or json")
+	}
+
+	in := fs.Arg(0)
+	pdf, f, err := openPDF(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	annots := exportAnnotations(pdf)
+
+	var data []byte
+	if *format == annotationsJSON {
+		data, err = json.MarshalIndent(struct {
+			Source      string               `json:"source"`
+			Annotations []exportedAnnotation `json:"annotations"`
+		}{path.Base(in), annots}, "", "  ")
+	} else {
+		data, err = marshalXFDF(path.Base(in), annots)
+	}
+	if err != nil {
+		fatal("Unable to export annotations:", err)
+	}
+
+	logInfo("Writing", *out)
+	if err = os.WriteFile(*out, append(data, '\n'), 0644); err != nil {
+		exitError(exitWrite, "Unable to write", *out+":", err)
+	}
+	addResult(fileResult{File: *out})
+	logInfo("Exported", len(annots), "annotations.")
+}
+
+// runAnnotationsImport adds the annotations of an XFDF file to the pages of a PDF they were
+// exported from. -source-pages maps the pages of a part split from the exported PDF back to it.
+func runAnnotationsImport(args []string) {
+	fs := flag.NewFlagSet("annotations import", flag.ExitOnError)
+	output := outputFlags(fs)
+	out := fs.String("out", "", "PDF file to write with the annotations added")
+	xfdf := fs.String("xfdf", "", "XFDF `file` of the annotations, e.g. from annotations export")
+	sourcePages := fs.String("source-pages", "", "the pages of the exported PDF the pages of the input are, one for each, e.g. \"5-8\" for a part of those pages (default the same pages)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter annotations import: -xfdf annotations.xfdf -out annotated.pdf [-source-pages ranges] input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("annotations import", output)
+	defer finishOutput()
+
+	if *out == "" || *xfdf == "" || fs.NArg() != 1 {
+		usage(fs)
+	}
+	annots, err := readXFDF(*xfdf)
+	if err != nil {
+		argError("Invalid -xfdf:", err)
+	}
+	im, err := newAnnotationImporter(annots)
+	if err != nil {
+		argError("Invalid -xfdf:", err)
+	}
+
+	in := fs.Arg(0)
+	pdf, f, err := openPDF(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	numbers := make([]int, len(pdf.PageList))
+	for i := range numbers {
+		numbers[i] = i + 1
+	}
+	if *sourcePages != "" {
+		//the pages of the exported PDF aren't known, but those after the last annotated page
+		//have nothing to import, so there are enough for the input after it
+		numPages := 0
+		for _, a := range annots {
+			if a.Page > numPages {
+				numPages = a.Page
+			}
+		}
+		numPages += len(pdf.PageList)
+		if numbers, err = parseRanges(*sourcePages, numPages); err == nil && len(numbers) != len(pdf.PageList) {
+			err = fmt.Errorf("%d pages given, but %s has %d", len(numbers), in, len(pdf.PageList))
+		}
+		if err != nil {
+			argError("Invalid -source-pages:", err)
+		}
+	}
+
+	imported := map[int]bool{}
+	for _, n := range numbers {
+		imported[n] = true
+	}
+	count := 0
+	for _, a := range annots {
+		if imported[a.Page] {
+			count++
+		}
+	}
+	if skipped := len(annots) - count; skipped > 0 {
+		warning("%d annotations are on pages not in %s, skipping them", skipped, in)
+	}
+
+	pages := im.apply(pdf.PageList, numbers)
+
+	logInfo("Writing", *out)
+	if _, err := writePDF(*out, pdfPart{pages: pages}); err != nil {
+		exitError(writeStatus(err), err)
+	}
+	addResult(fileResult{File: *out, Pages: len(pages)})
+	logInfo("Imported", count, "annotations.")
+}
+
+// exportAnnotations returns the annotations of the pages of pdf, in page order. Widgets, which
+// are form fields, are left out, and popups are exported with the annotation they belong to.
+func exportAnnotations(pdf *model.PdfReader) []exportedAnnotation {
+	annots := []exportedAnnotation{}
+	for i, p := range pdf.PageList {
+		for _, annot := range p.Annotations {
+			ind, ok := annot.GetContainingPdfObject().(*core.PdfIndirectObject)
+			if !ok {
+				continue
+			}
+			dict, ok := ind.PdfObject.(*core.PdfObjectDictionary)
+			if !ok {
+				continue
+			}
+			subtype, _ := resolve(pdf, dict.Get("Subtype")).(*core.PdfObjectName)
+			if subtype == nil || *subtype == "Widget" || *subtype == "Popup" {
+				continue
+			}
+			annots = append(annots, exportAnnotation(pdf, i+1, string(*subtype), dict))
+		}
+	}
+	return annots
+}
+
+// exportAnnotation converts the annotation dictionary dict of the given subtype on page
+func exportAnnotation(pdf *model.PdfReader, page int, subtype string, dict *core.PdfObjectDictionary) exportedAnnotation {
+	text := func(key core.PdfObjectName) string {
+		if s, ok := resolve(pdf, dict.Get(key)).(*core.PdfObjectString); ok {
+			return decodeTextString(string(*s))
+		}
+		return ""
+	}
+
+	a := exportedAnnotation{
+		Page:          page,
+		Type:          subtype,
+		Rect:          numberArray(pdf, dict.Get("Rect")),
+		Name:          text("NM"),
+		Author:        text("T"),
+		Subject:       text("Subj"),
+		Contents:      text("Contents"),
+		Modified:      text("M"),
+		Created:       text("CreationDate"),
+		Color:         annotationColor(numberArray(pdf, dict.Get("C"))),
+		InteriorColor: annotationColor(numberArray(pdf, dict.Get("IC"))),
+		QuadPoints:    numberArray(pdf, dict.Get("QuadPoints")),
+		Line:          numberArray(pdf, dict.Get("L")),
+		Vertices:      numberArray(pdf, dict.Get("Vertices")),
+		Appearance:    text("DA"),
+	}
+	if name, ok := resolve(pdf, dict.Get("Name")).(*core.PdfObjectName); ok {
+		a.Icon = string(*name)
+	}
+	if flags, ok := resolve(pdf, dict.Get("F")).(*core.PdfObjectInteger); ok {
+		for bit, name := range annotationFlags {
+			if int64(*flags)&(1<<uint(bit)) != 0 {
+				a.Flags = append(a.Flags, name)
+			}
+		}
+	}
+	if ink, ok := resolve(pdf, dict.Get("InkList")).(*core.PdfObjectArray); ok {
+		for _, path := range *ink {
+			a.InkList = append(a.InkList, numberArray(pdf, path))
+		}
+	}
+	if parent, ok := resolve(pdf, dict.Get("IRT")).(*core.PdfObjectDictionary); ok {
+		if s, ok := resolve(pdf, parent.Get("NM")).(*core.PdfObjectString); ok {
+			a.InReplyTo = decodeTextString(string(*s))
+		}
+	}
+	if popup, ok := resolve(pdf, dict.Get("Popup")).(*core.PdfObjectDictionary); ok {
+		a.PopupRect = numberArray(pdf, popup.Get("Rect"))
+		if open, ok := resolve(pdf, popup.Get("Open")).(*core.PdfObjectBool); ok {
+			a.PopupOpen = bool(*open)
+		}
+	}
+	return a
+}
+
+// numberArray returns the numbers of the array obj, or nil if it isn't an array of numbers
+func numberArray(pdf *model.PdfReader, obj core.PdfObject) []float64 {
+	arr, ok := resolve(pdf, obj).(*core.PdfObjectArray)
+	if !ok {
+		return nil
+	}
+	values := make([]float64, 0, len(*arr))
+	for _, item := range *arr {
+		switch n := resolve(pdf, item).(type) {
+		case *core.PdfObjectInteger:
+			values = append(values, float64(*n))
+		case *core.PdfObjectFloat:
+			values = append(values, float64(*n))
+		default:
+			return nil
+		}
+	}
+	return values
+}
+
+// annotationColor returns the gray, RGB or CMYK color c as "#rrggbb", or "" if it has none
+func annotationColor(c []float64) string {
+	var r, g, b float64
+	switch len(c) {
+	case 1:
+		r, g, b = c[0], c[0], c[0]
+	case 3:
+		r, g, b = c[0], c[1], c[2]
+	case 4:
+		r, g, b = (1-c[0])*(1-c[3]), (1-c[1])*(1-c[3]), (1-c[2])*(1-c[3])
+	default:
+		return ""
+	}
+	component := func(v float64) int {
+		if v < 0 {
+			v = 0
+		} else if v > 1 {
+			v = 1
+		}
+		return int(v*255 + 0.5)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", component(r), component(g), component(b))
+}
+
+// xfdfElements are the XFDF elements of annotation subtypes, which are the subtype in lower case
+// for the others
+var xfdfElements = map[string]string{
+	"FreeText":       "freetext",
+	"FileAttachment": "fileattachment",
+	"PolyLine":       "polyline",
+	"StrikeOut":      "strikeout",
+}
+
+// xfdfFile is an XFDF document
+type xfdfFile struct {
+	XMLName xml.Name `xml:"xfdf"`
+	NS      string   `xml:"xmlns,attr"`
+	Space   string   `xml:"xml:space,attr"`
+	File    struct {
+		Href string `xml:"href,attr"`
+	} `xml:"f"`
+	Annots struct {
+		Items []xfdfAnnotation `xml:",any"`
+	} `xml:"annots"`
+}
+
+// xfdfAnnotation is an annotation element of an XFDF document
+type xfdfAnnotation struct {
+	XMLName    xml.Name
+	Attrs      []xml.Attr   `xml:",any,attr"`
+	Contents   string       `xml:"contents,omitempty"`
+	Appearance string       `xml:"defaultappearance,omitempty"`
+	Vertices   string       `xml:"vertices,omitempty"`
+	InkList    *xfdfInkList `xml:"inklist"`
+	Popup      *xfdfPopup   `xml:"popup"`
+}
+
+// xfdfInkList is the paths of an XFDF ink annotation, each "x,y;x,y;..."
+type xfdfInkList struct {
+	Gestures []string `xml:"gesture"`
+}
+
+// xfdfPopup is the popup window of an XFDF annotation
+type xfdfPopup struct {
+	Rect string `xml:"rect,attr"`
+	Open string `xml:"open,attr,omitempty"`
+}
+
+// marshalXFDF returns the XFDF document of the annotations of the PDF file source
+func marshalXFDF(source string, annots []exportedAnnotation) ([]byte, error) {
+	doc := xfdfFile{NS: xfdfNamespace, Space: "preserve"}
+	doc.File.Href = source
+
+	for _, a := range annots {
+		element, ok := xfdfElements[a.Type]
+		if !ok {
+			element = strings.ToLower(a.Type)
+		}
+		x := xfdfAnnotation{XMLName: xml.Name{Local: element}, Contents: a.Contents, Appearance: a.Appearance, Vertices: xfdfPoints(a.Vertices, ";")}
+		attr := func(name, value string) {
+			if value != "" {
+				x.Attrs = append(x.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+			}
+		}
+		attr("page", fmt.Sprint(a.Page-1))
+		attr("rect", xfdfPoints(a.Rect, ","))
+		attr("name", a.Name)
+		attr("title", a.Author)
+		attr("subject", a.Subject)
+		attr("date", a.Modified)
+		attr("creationdate", a.Created)
+		attr("color", a.Color)
+		attr("interior-color", a.InteriorColor)
+		attr("flags", strings.Join(a.Flags, ","))
+		attr("inreplyto", a.InReplyTo)
+		attr("icon", a.Icon)
+		attr("coords", xfdfPoints(a.QuadPoints, ","))
+		if len(a.Line) == 4 {
+			attr("start", xfdfPoints(a.Line[:2], ","))
+			attr("end", xfdfPoints(a.Line[2:], ","))
+		}
+		if a.InkList != nil {
+			x.InkList = &xfdfInkList{}
+			for _, path := range a.InkList {
+				x.InkList.Gestures = append(x.InkList.Gestures, xfdfPoints(path, ";"))
+			}
+		}
+		if a.PopupRect != nil {
+			x.Popup = &xfdfPopup{Rect: xfdfPoints(a.PopupRect, ",")}
+			if a.PopupOpen {
+				x.Popup.Open = "yes"
+			}
+		}
+		doc.Annots.Items = append(doc.Annots.Items, x)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// xfdfPoints formats numbers as XFDF does: pairs of coordinates, "x,y", separated by sep, or all
+// separated by commas if sep is ","
+func xfdfPoints(values []float64, sep string) string {
+	var b strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			if i%2 == 0 {
+				b.WriteString(sep)
+			} else {
+				b.WriteString(",")
+			}
+		}
+		fmt.Fprintf(&b, "%g", v)
+	}
+	return b.String()
+}
+
+// readXFDF reads the annotations of the XFDF file fn, as they would have been exported
+func readXFDF(fn string) ([]exportedAnnotation, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var doc xfdfFile
+	if err = xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid XFDF: %w", err)
+	}
+
+	subtypes := map[string]string{}
+	for subtype, element := range xfdfElements {
+		subtypes[element] = subtype
+	}
+
+	annots := make([]exportedAnnotation, 0, len(doc.Annots.Items))
+	for i, x := range doc.Annots.Items {
+		a := exportedAnnotation{Type: subtypes[x.XMLName.Local], Contents: x.Contents, Appearance: x.Appearance}
+		if a.Type == "" && x.XMLName.Local != "" {
+			a.Type = strings.ToUpper(x.XMLName.Local[:1]) + x.XMLName.Local[1:]
+		}
+		attrs := map[string]string{}
+		for _, attr := range x.Attrs {
+			attrs[attr.Name.Local] = attr.Value
+		}
+
+		page, err := strconv.Atoi(attrs["page"])
+		if err != nil || page < 0 {
+			return nil, fmt.Errorf("annotation %d (%s): invalid page %q", i+1, x.XMLName.Local, attrs["page"])
+		}
+		a.Page = page + 1
+
+		//numbers are separated by commas, and the points of vertices and gestures by semicolons
+		points := func(s string) []float64 {
+			var values []float64
+			for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || unicode.IsSpace(r) }) {
+				v, e := strconv.ParseFloat(f, 64)
+				if e != nil && err == nil {
+					err = fmt.Errorf("annotation %d (%s): invalid number %q", i+1, x.XMLName.Local, f)
+				}
+				values = append(values, v)
+			}
+			return values
+		}
+		a.Rect = points(attrs["rect"])
+		a.Name = attrs["name"]
+		a.Author = attrs["title"]
+		a.Subject = attrs["subject"]
+		a.Modified = attrs["date"]
+		a.Created = attrs["creationdate"]
+		a.Color = attrs["color"]
+		a.InteriorColor = attrs["interior-color"]
+		if attrs["flags"] != "" {
+			a.Flags = strings.Split(attrs["flags"], ",")
+		}
+		a.InReplyTo = attrs["inreplyto"]
+		a.Icon = attrs["icon"]
+		a.QuadPoints = points(attrs["coords"])
+		if attrs["start"] != "" && attrs["end"] != "" {
+			a.Line = append(points(attrs["start"]), points(attrs["end"])...)
+		}
+		a.Vertices = points(x.Vertices)
+		if x.InkList != nil {
+			a.InkList = [][]float64{}
+			for _, gesture := range x.InkList.Gestures {
+				a.InkList = append(a.InkList, points(gesture))
+			}
+		}
+		if x.Popup != nil {
+			a.PopupRect = points(x.Popup.Rect)
+			a.PopupOpen = x.Popup.Open == "yes"
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(a.Rect) != 4 {
+			return nil, fmt.Errorf("annotation %d (%s): rect must have 4 numbers", i+1, x.XMLName.Local)
+		}
+		annots = append(annots, a)
+	}
+	return annots, nil
+}
+
+// annotationImporter adds imported annotations to the pages they were exported from
+type annotationImporter struct {
+	pages map[int][]*model.PdfAnnotation //by 1-based page number, with their popups
+}
+
+// newAnnotationImporter returns an importer of the annotations read from an XFDF file
+func newAnnotationImporter(annots []exportedAnnotation) (*annotationImporter, error) {
+	im := &annotationImporter{pages: map[int][]*model.PdfAnnotation{}}
+
+	//replies refer to the annotations they reply to by name
+	named := map[string]*core.PdfIndirectObject{}
+	objs := make([]*core.PdfIndirectObject, len(annots))
+	for i, a := range annots {
+		annot := model.NewPdfAnnotation()
+		objs[i] = annot.GetContainingPdfObject().(*core.PdfIndirectObject)
+		if a.Name != "" {
+			named[a.Name] = objs[i]
+		}
+		im.pages[a.Page] = append(im.pages[a.Page], annot)
+	}
+
+	for i, a := range annots {
+		dict := objs[i].PdfObject.(*core.PdfObjectDictionary)
+		if err := setAnnotation(dict, a); err != nil {
+			return nil, fmt.Errorf("annotation %d (%s) on page %d: %v", i+1, a.Type, a.Page, err)
+		}
+		if a.InReplyTo != "" {
+			if parent, ok := named[a.InReplyTo]; ok {
+				dict.Set("IRT", parent)
+			} else {
+				warning("Annotation %d on page %d replies to %q, which isn't imported", i+1, a.Page, a.InReplyTo)
+			}
+		}
+		if a.PopupRect != nil {
+			popup := model.NewPdfAnnotation()
+			obj := popup.GetContainingPdfObject().(*core.PdfIndirectObject)
+			popupDict := obj.PdfObject.(*core.PdfObjectDictionary)
+			popupDict.Set("Subtype", core.MakeName("Popup"))
+			popupDict.Set("Rect", core.MakeArrayFromFloats(a.PopupRect))
+			popupDict.Set("Open", core.MakeBool(a.PopupOpen))
+			popupDict.Set("Parent", objs[i])
+			dict.Set("Popup", obj)
+			im.pages[a.Page] = append(im.pages[a.Page], popup)
+		}
+	}
+	return im, nil
+}
+
+// setAnnotation sets the entries of the annotation dictionary dict from a
+func setAnnotation(dict *core.PdfObjectDictionary, a exportedAnnotation) error {
+	if a.Type == "" || a.Type == "Widget" || a.Type == "Popup" {
+		return fmt.Errorf("unsupported annotation type %q", a.Type)
+	}
+	dict.Set("Subtype", core.MakeName(a.Type))
+	dict.Set("Rect", core.MakeArrayFromFloats(a.Rect))
+
+	text := func(key core.PdfObjectName, value string) {
+		if value != "" {
+			dict.Set(key, core.MakeString(encodeTextString(value)))
+		}
+	}
+	text("NM", a.Name)
+	text("T", a.Author)
+	text("Subj", a.Subject)
+	text("Contents", a.Contents)
+	text("M", a.Modified)
+	text("CreationDate", a.Created)
+	text("DA", a.Appearance)
+
+	for key, color := range map[core.PdfObjectName]string{"C": a.Color, "IC": a.InteriorColor} {
+		if color == "" {
+			continue
+		}
+		var r, g, b uint8
+		if _, err := fmt.Sscanf(color, "#%02x%02x%02x", &r, &g, &b); err != nil {
+			return fmt.Errorf("invalid color %q", color)
+		}
+		dict.Set(key, core.MakeArrayFromFloats([]float64{float64(r) / 255, float64(g) / 255, float64(b) / 255}))
+	}
+
+	if len(a.Flags) > 0 {
+		flags := 0
+	next:
+		for _, name := range a.Flags {
+			for bit, known := range annotationFlags {
+				if strings.EqualFold(strings.TrimSpace(name), known) {
+					flags |= 1 << uint(bit)
+					continue next
+				}
+			}
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		dict.Set("F", core.MakeInteger(int64(flags)))
+	}
+	if a.Icon != "" {
+		dict.Set("Name", core.MakeName(a.Icon))
+	}
+	if a.QuadPoints != nil {
+		dict.Set("QuadPoints", core.MakeArrayFromFloats(a.QuadPoints))
+	}
+	if len(a.Line) == 4 {
+		dict.Set("L", core.MakeArrayFromFloats(a.Line))
+	}
+	if a.Vertices != nil {
+		dict.Set("Vertices", core.MakeArrayFromFloats(a.Vertices))
+	}
+	if a.InkList != nil {
+		ink := core.MakeArray()
+		for _, path := range a.InkList {
+			ink.Append(core.MakeArrayFromFloats(path))
+		}
+		dict.Set("InkList", ink)
+	}
+	return nil
+}
+
+// apply returns pages with the imported annotations of their input pages, numbers, added after
+// the annotations they have. pages aren't modified.
+func (im *annotationImporter) apply(pages []*model.PdfPage, numbers []int) []*model.PdfPage {
+	out := make([]*model.PdfPage, len(pages))
+	for i, p := range pages {
+		annots := im.pages[numbers[i]]
+		if len(annots) == 0 {
+			out[i] = p
+			continue
+		}
+		dup := p.Duplicate()
+		dup.Annotations = append(append([]*model.PdfAnnotation{}, p.Annotations...), annots...)
+		out[i] = dup
+	}
+	return out
+}
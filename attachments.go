@@ -0,0 +1,147 @@
+package main
+
+import (
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// attachment policies for -attachments
+const (
+	attachAll   = "all"   //each output gets every attachment of the input
+	attachPages = "pages" //each output gets the attachments its pages refer to
+	attachNone  = "none"  //outputs get no attachments
+)
+
+// embeddedFile is a document-level attachment of the input, an entry of its EmbeddedFiles name tree
+type embeddedFile struct {
+	name   string
+	spec   core.PdfObject //the file specification, with the references it holds resolved
+	objNum int64          //object number of the file specification, 0 if it is a direct object
+}
+
+// attachmentPolicy gives outputs the document-level attachments of the input by -attachments
+type attachmentPolicy struct {
+	pdf    *model.PdfReader
+	policy string
+	files  []embeddedFile //in name order
+}
+
+// newAttachmentPolicy returns the policy giving outputs the attachments of pdf
+func newAttachmentPolicy(pdf *model.PdfReader, policy string) (*attachmentPolicy, error) {
+	a := &attachmentPolicy{pdf: pdf, policy: policy}
+	r, err := newOutlineReader(pdf)
+	if err != nil {
+		return nil, err
+	}
+	names, ok := r.resolve(r.catalog.Get("Names")).(*core.PdfObjectDictionary)
+	if !ok {
+		return a, nil
+	}
+
+	seen := map[*core.PdfObjectDictionary]bool{}
+	var walk func(obj core.PdfObject, depth int)
+	walk = func(obj core.PdfObject, depth int) {
+		node, ok := r.resolve(obj).(*core.PdfObjectDictionary)
+		if !ok || seen[node] || depth > core.TraceMaxDepth {
+			return
+		}
+		seen[node] = true
+		if entries, ok := r.resolve(node.Get("Names")).(*core.PdfObjectArray); ok {
+			for i := 0; i+1 < len(*entries); i += 2 {
+				name, ok := r.resolve((*entries)[i]).(*core.PdfObjectString)
+				if !ok {
+					continue
+				}
+				f := embeddedFile{name: string(*name)}
+				if ref, ok := (*entries)[i+1].(*core.PdfObjectReference); ok {
+					f.objNum = ref.ObjectNumber
+				}
+				f.spec = resolveReferences(pdf, (*entries)[i+1], map[core.PdfObject]bool{})
+				a.files = append(a.files, f)
+			}
+		}
+		if kids, ok := r.resolve(node.Get("Kids")).(*core.PdfObjectArray); ok {
+			for _, kid := range *kids {
+				walk(kid, depth+1)
+			}
+		}
+	}
+	walk(names.Get("EmbeddedFiles"), 0)
+	return a, nil
+}
+
+// forPages returns the attachments for an output of the input pages
+func (a *attachmentPolicy) forPages(pages []*model.PdfPage) []embeddedFile {
+	switch a.policy {
+	case attachAll:
+		return a.files
+	case attachNone:
+		return nil
+	}
+
+	//attachments are referred to by file attachment annotations, or by name from links going
+	//to embedded documents
+	objNums := map[int64]bool{}
+	names := map[string]bool{}
+	for _, p := range pages {
+		for _, annot := range p.Annotations {
+			ind, ok := annot.GetContainingPdfObject().(*core.PdfIndirectObject)
+			if !ok {
+				continue
+			}
+			dict, ok := ind.PdfObject.(*core.PdfObjectDictionary)
+			if !ok {
+				continue
+			}
+			switch fs := dict.Get("FS").(type) {
+			case *core.PdfIndirectObject:
+				objNums[fs.ObjectNumber] = true
+			case *core.PdfObjectReference:
+				objNums[fs.ObjectNumber] = true
+			}
+			if action, ok := resolve(a.pdf, dict.Get("A")).(*core.PdfObjectDictionary); ok {
+				if s, _ := resolve(a.pdf, action.Get("S")).(*core.PdfObjectName); s != nil && *s == "GoToE" {
+					if target, ok := resolve(a.pdf, action.Get("T")).(*core.PdfObjectDictionary); ok {
+						if n, ok := resolve(a.pdf, target.Get("N")).(*core.PdfObjectString); ok {
+							names[string(*n)] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var files []embeddedFile
+	for _, f := range a.files {
+		if names[f.name] || (f.objNum > 0 && objNums[f.objNum]) {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// apply gives the output written by w the attachments files
+func (a *attachmentPolicy) apply(w *model.PdfWriter, files []embeddedFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	entries := core.MakeArray()
+	for _, f := range files {
+		entries.Append(core.MakeString(f.name))
+		entries.Append(f.spec)
+	}
+	tree := core.MakeDict()
+	tree.Set("Names", entries)
+	names := core.MakeDict()
+	names.Set("EmbeddedFiles", tree)
+	return w.SetCatalogEntry("Names", names)
+}
+
+// attachmentNames returns the names of files, as recorded in the results
+func attachmentNames(files []embeddedFile) []string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = decodeTextString(f.name)
+	}
+	return names
+}
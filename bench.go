@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// benchScenario is a standard split run by the bench command on each corpus file
+type benchScenario struct {
+	name    string
+	prepare func(data []byte) ([]byte, error) //if set, turns a corpus file into the input, untimed
+	parts   func(numPages int) [][]int        //the pages of each output
+}
+
+// benchScenarios are the scenarios of the bench command, in the order they run.
+// chunk is the pages per output of the chunks scenario.
+func benchScenarios(chunk int) []benchScenario {
+	eachPage := func(numPages int) [][]int {
+		parts := make([][]int, numPages)
+		for i := range parts {
+			parts[i] = []int{i + 1}
+		}
+		return parts
+	}
+
+	chunks := func(numPages int) [][]int {
+		var parts [][]int
+		for first := 1; first <= numPages; first += chunk {
+			var pages []int
+			for n := first; n < first+chunk && n <= numPages; n++ {
+				pages = append(pages, n)
+			}
+			parts = append(parts, pages)
+		}
+		return parts
+	}
+
+	//AES-256 without a user password, as for -allow, so the input opens without one
+	encrypted := func(data []byte) ([]byte, error) {
+		enc, err := newEncryption(encryptAES256, "")
+		if err != nil {
+			return nil, err
+		}
+		enc.perms = core.AccessPermissions{Printing: true}
+		return enc.encrypt("bench", data, "")
+	}
+
+	return []benchScenario{
+		{name: "pages", parts: eachPage},
+		{name: "chunks", parts: chunks},
+		{name: "encrypted", prepare: encrypted, parts: eachPage},
+	}
+}
+
+// benchResult is the total of a scenario over the corpus
+type benchResult struct {
+	files, errors int
+	pages         int
+	bytes         int64
+	elapsed       time.Duration
+}
+
+// scenarioResult is the throughput of a bench scenario with -output json
+type scenarioResult struct {
+	Scenario       string   `json:"scenario"`
+	Files          int      `json:"files"`
+	Errors         int      `json:"errors"`
+	Pages          int      `json:"pages"`
+	MB             float64  `json:"mb"`
+	Seconds        float64  `json:"seconds"`
+	PagesPerSecond float64  `json:"pages_per_second"`
+	MBPerSecond    float64  `json:"mb_per_second"`
+	PeakRSSMB      *float64 `json:"peak_rss_mb,omitempty"` //nil if unknown on this system
+}
+
+// runBench times the bench scenarios on a corpus of PDFs and reports their throughput, to
+// compare performance across versions
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	output := outputFlags(fs)
+	chunk := fs.Int("chunk", 10, "pages per output of the chunks scenario")
+	runs := fs.Int("runs", 1, "times each scenario is run on the corpus")
+	only := fs.String("scenario", "", "run only this scenario: \"pages\", \"chunks\" or \"encrypted\"")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter bench: [flags] file.pdf|directory ...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("bench", output)
+	defer finishOutput()
+
+	if fs.NArg() == 0 {
+		usage(fs)
+	}
+	if *chunk < 1 || *runs < 1 {
+		argError("-chunk and -runs must be at least 1")
+	}
+
+	scenarios := benchScenarios(*chunk)
+	if *only != "" {
+		var found bool
+		for _, sc := range scenarios {
+			if sc.name == *only {
+				scenarios, found = []benchScenario{sc}, true
+				break
+			}
+		}
+		if !found {
+			argError("-scenario must be pages, chunks or encrypted")
+		}
+	}
+
+	corpus, err := benchCorpus(fs.Args())
+	if err != nil {
+		exitError(exitUnreadable, "Unable to read corpus:", err)
+	}
+	if len(corpus) == 0 {
+		fatal("No PDFs found in corpus")
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	if jsonReport == nil {
+		fmt.Fprintln(tw, "Scenario\tFiles\tErrors\tPages\tMB\tSeconds\tPages/s\tMB/s\tPeak RSS MB\t")
+	}
+	for _, sc := range scenarios {
+		var res benchResult
+		for run := 0; run < *runs; run++ {
+			for _, fn := range corpus {
+				benchFile(sc, fn, &res)
+			}
+		}
+
+		seconds := res.elapsed.Seconds()
+		mb := float64(res.bytes) / (1 << 20)
+		if jsonReport != nil {
+			sr := scenarioResult{Scenario: sc.name, Files: res.files, Errors: res.errors, Pages: res.pages, MB: mb,
+				Seconds: seconds, PagesPerSecond: float64(res.pages) / seconds, MBPerSecond: mb / seconds}
+			if rss := peakRSS(); rss != 0 {
+				peak := float64(rss) / (1 << 20)
+				sr.PeakRSSMB = &peak
+			}
+			addResult(sr)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f\t%.2f\t%.1f\t%.1f\t%s\t\n", sc.name, res.files, res.errors, res.pages,
+			mb, seconds, float64(res.pages)/seconds, mb/seconds, formatRSS(peakRSS()))
+	}
+	tw.Flush()
+}
+
+// benchCorpus returns the PDF files given as args, and those in directories given as args
+func benchCorpus(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		err := filepath.Walk(arg, func(fn string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fn == arg && !fi.IsDir() || !fi.IsDir() && strings.EqualFold(filepath.Ext(fn), ".pdf") {
+				files = append(files, fn)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// benchFile runs the scenario sc on the corpus file fn, adding its time to res.
+// Loading the input is timed, as decryption and most parsing happen then; reading the file and
+// preparing it are not.
+func benchFile(sc benchScenario, fn string, res *benchResult) {
+	data, err := os.ReadFile(fn)
+	if err == nil && sc.prepare != nil {
+		data, err = sc.prepare(data)
+	}
+	if err != nil {
+		logInfo("Skipping", fn, "in", sc.name+":", err)
+		res.errors++
+		return
+	}
+
+	runtime.GC()
+	start := time.Now()
+	pages, err := benchSplit(data, sc.parts)
+	res.elapsed += time.Since(start)
+	if err != nil {
+		logInfo("Unable to split", fn, "in", sc.name+":", err)
+		res.errors++
+		return
+	}
+
+	res.files++
+	res.pages += pages
+	res.bytes += int64(len(data))
+}
+
+// benchSplit splits the PDF data into the outputs given by parts, writing them to memory.
+// It returns the number of pages written.
+func benchSplit(data []byte, parts func(numPages int) [][]int) (int, error) {
+	pdf, err := readPDF(bytes.NewReader(data), nil, runtime.NumCPU())
+	if err != nil {
+		return 0, err
+	}
+	numPages, err := pdf.GetNumPages()
+	if err != nil {
+		return 0, err
+	}
+
+	var written int
+	for _, pt := range parts(numPages) {
+		pages := make([]*model.PdfPage, len(pt))
+		for i, n := range pt {
+			pages[i] = pdf.PageList[n-1]
+		}
+
+		w, err := newPageWriter(pages)
+		if err != nil {
+			return written, err
+		}
+		var buf seekBuffer
+		if err = w.Write(&buf); err != nil {
+			return written, err
+		}
+		written += len(pages)
+	}
+
+	return written, nil
+}
+
+// formatRSS formats a peak resident set size in MB, or "-" if it is unknown
+func formatRSS(rss int64) string {
+	if rss == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", float64(rss)/(1<<20))
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// bookmark is an outline item and the page it points to
+type bookmark struct {
+	titles []string //titles of the item and its ancestors, top level first
+	page   int      //1-based
+}
+
+// title returns the title of the item itself
+func (b bookmark) title() string {
+	return b.titles[len(b.titles)-1]
+}
+
+// outlineReader reads outline items straight from the document's dictionaries, which
+// model.PdfOutlineTreeNode doesn't expose sibling links for
+type outlineReader struct {
+	pdf     *model.PdfReader
+	catalog *core.PdfObjectDictionary
+	pages   map[int64]int //page object number -> 1-based page number
+	seen    map[*core.PdfObjectDictionary]bool
+}
+
+// bookmarks returns the outline items of pdf down to the given level (1 for top-level items
+// only) that point to a page in the document, in outline order
+func bookmarks(pdf *model.PdfReader, level int) ([]bookmark, error) {
+	r, err := newOutlineReader(pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	outlines, ok := r.resolve(r.catalog.Get("Outlines")).(*core.PdfObjectDictionary)
+	if !ok {
+		return nil, nil
+	}
+
+	return r.items(outlines, nil, level), nil
+}
+
+// newOutlineReader returns a reader of the outline and destinations of pdf
+func newOutlineReader(pdf *model.PdfReader) (*outlineReader, error) {
+	r := &outlineReader{pdf: pdf, pages: map[int64]int{}, seen: map[*core.PdfObjectDictionary]bool{}}
+
+	trailer, err := pdf.GetTrailer()
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := r.resolve(trailer.Get("Root")).(*core.PdfObjectDictionary)
+	if !ok {
+		return nil, errors.New("invalid catalog")
+	}
+	r.catalog = catalog
+
+	for i, p := range pdf.PageList {
+		r.pages[p.GetPageAsIndirectObject().ObjectNumber] = i + 1
+	}
+
+	return r, nil
+}
+
+// items returns the bookmarks for the children of an outline node and their descendants
+// down to level more levels
+func (r *outlineReader) items(node *core.PdfObjectDictionary, titles []string, level int) []bookmark {
+	var marks []bookmark
+
+	item, _ := r.resolve(node.Get("First")).(*core.PdfObjectDictionary)
+	for item != nil && !r.seen[item] {
+		r.seen[item] = true
+
+		title := ""
+		if s, ok := r.resolve(item.Get("Title")).(*core.PdfObjectString); ok {
+			title = decodeTextString(string(*s))
+		}
+		path := append(append([]string{}, titles...), title)
+
+		if n := r.destPage(item); n > 0 {
+			marks = append(marks, bookmark{titles: path, page: n})
+		}
+		if level > 1 {
+			marks = append(marks, r.items(item, path, level-1)...)
+		}
+
+		item, _ = r.resolve(item.Get("Next")).(*core.PdfObjectDictionary)
+	}
+
+	return marks
+}
+
+// resolve follows references and indirect objects to a direct object
+func (r *outlineReader) resolve(obj core.PdfObject) core.PdfObject {
+	return resolve(r.pdf, obj)
+}
+
+// destPage returns the 1-based page number an outline item points to, or 0 if it doesn't
+// point to a page in this document
+func (r *outlineReader) destPage(item *core.PdfObjectDictionary) int {
+	dest := item.Get("Dest")
+	if dest == nil {
+		dest = item.Get("A")
+	}
+	n, _ := r.pageDest(dest)
+	return n
+}
+
+// pageDest returns the 1-based page number of a destination, or of a GoTo action to one, and the
+// explicit destination [page /XYZ left top zoom] it resolves to. The page number is 0 if it
+// doesn't point to a page in this document.
+func (r *outlineReader) pageDest(dest core.PdfObject) (int, *core.PdfObjectArray) {
+	if action, ok := r.resolve(dest).(*core.PdfObjectDictionary); ok && action.Get("S") != nil {
+		if s, _ := r.resolve(action.Get("S")).(*core.PdfObjectName); s == nil || *s != "GoTo" {
+			return 0, nil
+		}
+		dest = action.Get("D")
+	}
+
+	//named destinations
+	switch name := r.resolve(dest).(type) {
+	case *core.PdfObjectName:
+		dest = r.namedDest(string(*name))
+	case *core.PdfObjectString:
+		dest = r.namedDest(string(*name))
+	}
+
+	//explicit destination [page /XYZ left top zoom], or a dictionary holding it in D
+	if d, ok := r.resolve(dest).(*core.PdfObjectDictionary); ok {
+		dest = d.Get("D")
+	}
+	arr, ok := r.resolve(dest).(*core.PdfObjectArray)
+	if !ok || len(*arr) == 0 {
+		return 0, nil
+	}
+
+	switch p := (*arr)[0].(type) {
+	case *core.PdfIndirectObject:
+		return r.pages[p.ObjectNumber], arr
+	case *core.PdfObjectReference:
+		return r.pages[p.ObjectNumber], arr
+	}
+
+	return 0, nil
+}
+
+// namedDest looks up a named destination in the catalog's Dests dictionary (PDF 1.1) or
+// the Dests name tree in the Names dictionary
+func (r *outlineReader) namedDest(name string) core.PdfObject {
+	if dests, ok := r.resolve(r.catalog.Get("Dests")).(*core.PdfObjectDictionary); ok {
+		if dest := dests.Get(core.PdfObjectName(name)); dest != nil {
+			return dest
+		}
+	}
+
+	names, ok := r.resolve(r.catalog.Get("Names")).(*core.PdfObjectDictionary)
+	if !ok {
+		return nil
+	}
+
+	return r.lookupNameTree(r.resolve(names.Get("Dests")), name, 0)
+}
+
+// lookupNameTree finds key in a name tree node and its descendants
+func (r *outlineReader) lookupNameTree(obj core.PdfObject, key string, depth int) core.PdfObject {
+	node, ok := obj.(*core.PdfObjectDictionary)
+	if !ok || depth > core.TraceMaxDepth {
+		return nil
+	}
+
+	if names, ok := r.resolve(node.Get("Names")).(*core.PdfObjectArray); ok {
+		for i := 0; i+1 < len(*names); i += 2 {
+			if s, ok := r.resolve((*names)[i]).(*core.PdfObjectString); ok && string(*s) == key {
+				return (*names)[i+1]
+			}
+		}
+	}
+
+	if kids, ok := r.resolve(node.Get("Kids")).(*core.PdfObjectArray); ok {
+		for _, kid := range *kids {
+			if dest := r.lookupNameTree(r.resolve(kid), key, depth+1); dest != nil {
+				return dest
+			}
+		}
+	}
+
+	return nil
+}
+
+// bookmarkPart is the part started by a bookmark
+type bookmarkPart struct {
+	bookmark
+	pages []int
+}
+
+// bookmarkParts splits a document of numPages pages at each bookmark, running to the page
+// before the next bookmark. Pages before the first bookmark go into the first part. Of several
+// bookmarks on the same page the most nested one is used, and bookmarks pointing back to an
+// earlier page are skipped.
+func bookmarkParts(marks []bookmark, numPages int) []bookmarkPart {
+	var parts []bookmarkPart
+
+	for i := 0; i < len(marks); i++ {
+		m := marks[i]
+		if len(parts) > 0 && m.page <= parts[len(parts)-1].page {
+			continue
+		}
+
+		//pick one of the bookmarks on this page
+		for i+1 < len(marks) && marks[i+1].page == m.page {
+			i++
+			if len(marks[i].titles) > len(m.titles) {
+				m = marks[i]
+			}
+		}
+
+		start, end := m.page, numPages
+		if len(parts) == 0 {
+			start = 1
+		}
+		for _, next := range marks[i+1:] {
+			if next.page > m.page {
+				end = next.page - 1
+				break
+			}
+		}
+
+		pt := bookmarkPart{bookmark: m}
+		for n := start; n <= end; n++ {
+			pt.pages = append(pt.pages, n)
+		}
+		parts = append(parts, pt)
+	}
+
+	return parts
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// tokenParts splits the pages of pdf into runs of whole pages whose extracted text has at most
+// maxTokens tokens, as estimated by countTokens, returning the 1-based page numbers of each part.
+// A page over the budget on its own is a part by itself, with a warning. If warn is set, pages
+// whose text can't be extracted are counted as empty, calling warn for each.
+func tokenParts(pdf *model.PdfReader, maxTokens int, warn func(core.Warning)) ([][]int, error) {
+	var parts [][]int
+	var current []int
+	tokens := 0
+	for i, p := range pdf.PageList {
+		text, err := pageText(p)
+		if err != nil && warn != nil {
+			obj, _ := p.GetContainingPdfObject().(*core.PdfIndirectObject)
+			var objNum int64
+			if obj != nil {
+				objNum = obj.ObjectNumber
+			}
+			warn(core.Warning{ObjectNumber: objNum, Offset: -1, Problem: err.Error(), Action: fmt.Sprintf("page %d counted as empty", i+1)})
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to extract PDF page %d text: %v", i+1, err)
+		}
+
+		n := countTokens(text)
+		if n > maxTokens {
+			warning("Page %d has about %d tokens, over -max-tokens %d on its own", i+1, n, maxTokens)
+		}
+		if len(current) > 0 && tokens+n > maxTokens {
+			parts = append(parts, current)
+			current, tokens = nil, 0
+		}
+		current = append(current, i+1)
+		tokens += n
+	}
+	if len(current) > 0 {
+		parts = append(parts, current)
+	}
+	return parts, nil
+}
+
+// countTokens estimates the number of tokens a language model tokenizer splits text into: a
+// token for every 4 characters of a word, at least one per word, and one for each CJK character,
+// which are written without spaces. Tokenizers differ, so budgets should leave some room.
+func countTokens(text string) int {
+	tokens := 0
+	for _, word := range strings.Fields(text) {
+		chars := 0
+		for _, r := range word {
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+				tokens++
+			} else {
+				chars++
+			}
+		}
+		if chars > 0 {
+			tokens += (chars + 3) / 4
+		}
+	}
+	return tokens
+}
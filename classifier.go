@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// classifierTimeout bounds each request to an HTTP -classifier, which may run a model
+const classifierTimeout = 60 * time.Second
+
+// thumbnailSize is the longest side, in pixels, of the scan thumbnails sent to -classifier
+const thumbnailSize = 256
+
+// pageFeatures are what -classifier is sent for each page, as JSON
+type pageFeatures struct {
+	Page      int    `json:"page"`  //1-based
+	Pages     int    `json:"pages"` //of the input
+	Text      string `json:"text"`
+	Thumbnail string `json:"thumbnail,omitempty"` //base64 PNG of the scan, for scanned pages only
+}
+
+// pageDecision is the answer of -classifier for a page
+type pageDecision struct {
+	NewDocument bool   `json:"new_document"`
+	Name        string `json:"name"` //suggested name of the document the page starts, optional
+}
+
+// pageClassifier decides where documents start, e.g. with a machine learning model
+type pageClassifier interface {
+	classify(f pageFeatures) (pageDecision, error)
+}
+
+// newPageClassifier returns the classifier for a -classifier of an HTTP or HTTPS URL, or else a
+// command split at spaces
+func newPageClassifier(target string) pageClassifier {
+	if isURL(target) {
+		return &httpClassifier{url: target, client: &http.Client{Timeout: classifierTimeout}}
+	}
+	return commandClassifier{args: strings.Fields(target)}
+}
+
+// httpClassifier posts the features of each page to an endpoint, answering with the decision
+type httpClassifier struct {
+	url    string
+	client *http.Client
+}
+
+func (c *httpClassifier) classify(f pageFeatures) (pageDecision, error) {
+	var d pageDecision
+	data, err := json.Marshal(f)
+	if err != nil {
+		return d, err
+	}
+	resp, err := c.client.Post(c.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return d, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return d, fmt.Errorf("classifier: %s", resp.Status)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return d, fmt.Errorf("classifier: %v", err)
+	}
+	return d, nil
+}
+
+// commandClassifier runs a command for each page, with the features on its stdin, printing the
+// decision. Its stderr is that of the splitter.
+type commandClassifier struct {
+	args []string
+}
+
+func (c commandClassifier) classify(f pageFeatures) (pageDecision, error) {
+	var d pageDecision
+	data, err := json.Marshal(f)
+	if err != nil {
+		return d, err
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command(c.args[0], c.args[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return d, fmt.Errorf("%s: %w", c.args[0], err)
+	}
+	if err = json.Unmarshal(stdout.Bytes(), &d); err != nil {
+		return d, fmt.Errorf("%s: %v", c.args[0], err)
+	}
+	return d, nil
+}
+
+// classifiedPart is a document found by -classifier
+type classifiedPart struct {
+	pages []int  //1-based
+	name  string //suggested by the classifier, if any
+}
+
+// classifierParts splits the pages of pdf where c answers a page starts a new document. The
+// first page always starts one. If warn is set, pages whose text can't be extracted are sent
+// without text, calling warn for each.
+func classifierParts(pdf *model.PdfReader, c pageClassifier, warn func(core.Warning)) ([]classifiedPart, error) {
+	var parts []classifiedPart
+	for i, p := range pdf.PageList {
+		text, err := pageText(p)
+		if err != nil && warn != nil {
+			obj, _ := p.GetContainingPdfObject().(*core.PdfIndirectObject)
+			var objNum int64
+			if obj != nil {
+				objNum = obj.ObjectNumber
+			}
+			warn(core.Warning{ObjectNumber: objNum, Offset: -1, Problem: err.Error(), Action: fmt.Sprintf("page %d sent without text", i+1)})
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to extract PDF page %d text: %v", i+1, err)
+		}
+
+		f := pageFeatures{Page: i + 1, Pages: len(pdf.PageList), Text: text, Thumbnail: pageThumbnail(p)}
+		d, err := c.classify(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to classify page %d: %v", i+1, err)
+		}
+		if d.NewDocument || len(parts) == 0 {
+			parts = append(parts, classifiedPart{name: d.Name})
+		} else if d.Name != "" {
+			logInfof("Ignoring name %q suggested for page %d, which doesn't start a document\n", d.Name, i+1)
+		}
+		parts[len(parts)-1].pages = append(parts[len(parts)-1].pages, i+1)
+	}
+	return parts, nil
+}
+
+// pageThumbnail returns a grayscale PNG of the scan of p, at most thumbnailSize pixels on its
+// longest side, base64 encoded, or "" if p isn't a scan or the scan can't be decoded. Pages
+// aren't rendered, so those with vector content have no thumbnail.
+func pageThumbnail(p *model.PdfPage) string {
+	box, err := p.GetMediaBox()
+	if err != nil {
+		return ""
+	}
+	stream, _, ok := scanImage(p, box)
+	if !ok {
+		return ""
+	}
+	ximg, err := model.NewXObjectImageFromStream(stream)
+	if err != nil || ximg.ColorSpace == nil {
+		return ""
+	}
+	img, err := ximg.ToImage()
+	if err != nil {
+		return ""
+	}
+	gray, err := imageToGray(ximg.ColorSpace, img)
+	if err != nil {
+		return ""
+	}
+
+	width, height := int(gray.Width), int(gray.Height)
+	samples := gray.GetSamples()
+	if width == 0 || height == 0 || len(samples) < width*height {
+		return ""
+	}
+	scale := 1
+	for width/scale > thumbnailSize || height/scale > thumbnailSize {
+		scale++
+	}
+	maxValue := uint64(1)<<uint(gray.BitsPerComponent) - 1
+	thumb := image.NewGray(image.Rect(0, 0, width/scale, height/scale))
+	for y := 0; y < thumb.Rect.Dy(); y++ {
+		for x := 0; x < thumb.Rect.Dx(); x++ {
+			//each thumbnail pixel averages a block of scale by scale samples
+			var sum uint64
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					sum += uint64(samples[(y*scale+dy)*width+x*scale+dx])
+				}
+			}
+			thumb.Pix[y*thumb.Stride+x] = uint8(sum * 255 / (maxValue * uint64(scale*scale)))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, thumb); err != nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// exit statuses, so wrapper scripts can tell classes of failure apart
+const (
+	exitFailure    = 1 //any other failure, or differences found by diff and validate
+	exitUsage      = 2 //invalid flags or arguments
+	exitEncryption = 3 //an input encrypted by a security handler or algorithm this tool can't open
+	exitUnreadable = 4 //an input that can't be opened or parsed
+	exitPassword   = 5 //an input that needs a user password
+	exitWrite      = 6 //an output that can't be created or written, e.g. as the disk is full
+	exitPartial    = 7 //some outputs were written, but others failed and were skipped
+	exitMemory     = 8 //the peak memory went over -max-memory
+)
+
+// output formats for -output
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// cliReport is what a command prints with -output json: one JSON document on stdout with its
+// results, the warnings logged on the way and the error it failed with, if any. Logs still go to
+// stderr, so scripts can read stdout without parsing text meant for people.
+type cliReport struct {
+	Command  string        `json:"command"`
+	Results  []interface{} `json:"results"`
+	Warnings []string      `json:"warnings,omitempty"`
+	Error    string        `json:"error,omitempty"`
+
+	PeakMemoryMB *float64 `json:"peak_memory_mb,omitempty"` //peak resident set size, if known
+}
+
+// jsonReport is the report of the running command with -output json, and nil otherwise
+var jsonReport *cliReport
+
+// textOut is where commands print text that isn't a result, e.g. -debug page text. With
+// -output json it goes to stderr, keeping stdout for the report.
+var textOut io.Writer = os.Stdout
+
+// outputOptions are the flags every command has for its output: -output, and -q, -v and -vv for
+// how much is logged
+type outputOptions struct {
+	format  *string
+	quiet   *bool
+	verbose *bool
+	debug   *bool
+}
+
+// outputFlags adds the output flags to the flags of a command. A command with a -q flag of its
+// own, such as diff, keeps it.
+func outputFlags(fs *flag.FlagSet) *outputOptions {
+	o := &outputOptions{quiet: new(bool)}
+	o.format = fs.String("output", outputText, "output `format`: \"text\", or \"json\" for a JSON document of results, warnings and errors on stdout, with logs on stderr")
+	if fs.Lookup("q") == nil {
+		o.quiet = fs.Bool("q", false, "only log errors, not progress or warnings")
+	}
+	o.verbose = fs.Bool("v", false, "also log the errors and warnings of the PDF library, e.g. about malformed objects")
+	o.debug = fs.Bool("vv", false, "also log the debug messages of the PDF library")
+	return o
+}
+
+// startOutput starts the output of command with the output flags o, exiting if they are invalid
+func startOutput(command string, o *outputOptions) {
+	switch *o.format {
+	case outputText:
+	case outputJSON:
+		jsonReport = &cliReport{Command: command, Results: []interface{}{}}
+		textOut = os.Stderr
+	default:
+		fmt.Println("-output must be text or json")
+		os.Exit(exitUsage)
+	}
+
+	switch {
+	case *o.quiet && (*o.verbose || *o.debug):
+		argError("-q can't be combined with -v or -vv")
+	case *o.quiet:
+		setLogLevel(levelQuiet)
+	case *o.debug:
+		setLogLevel(levelDebug)
+	case *o.verbose:
+		setLogLevel(levelVerbose)
+	}
+}
+
+// addResult adds a result to the JSON report, if any
+func addResult(v interface{}) {
+	if jsonReport != nil {
+		jsonReport.Results = append(jsonReport.Results, v)
+	}
+}
+
+// warning logs a warning, unless -q is set, adding it to the JSON report, if any
+func warning(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logger.Warn(msg)
+	if jsonReport != nil {
+		jsonReport.Warnings = append(jsonReport.Warnings, msg)
+	}
+	if progress != nil {
+		progress.warn(msg)
+	}
+}
+
+// argError reports invalid arguments and exits with exitUsage
+func argError(args ...interface{}) {
+	if jsonReport != nil {
+		jsonReport.Error = strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+	} else {
+		fmt.Println(args...)
+	}
+	exit(exitUsage)
+}
+
+// usage prints the usage of a command with invalid arguments and exits with exitUsage
+func usage(fs *flag.FlagSet) {
+	fs.Usage()
+	if jsonReport != nil {
+		jsonReport.Error = "invalid arguments"
+	}
+	exit(exitUsage)
+}
+
+// fatal logs args and exits with exitFailure, like log.Fatalln, reporting them as the error
+func fatal(args ...interface{}) {
+	exitError(exitFailure, args...)
+}
+
+// exitError logs args and exits with code, reporting them as the error
+func exitError(code int, args ...interface{}) {
+	msg := strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+	if jsonReport != nil {
+		jsonReport.Error = msg
+	}
+	logger.Error(msg)
+	exit(code)
+}
+
+// fatalf logs a formatted message and exits with exitFailure, like log.Fatalf
+func fatalf(format string, args ...interface{}) {
+	fatal(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+// writeStatus returns the exit status for err from writing an output: exitWrite if a file couldn't
+// be created or written, and exitFailure if the output couldn't be built
+func writeStatus(err error) int {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return exitWrite
+	}
+	return exitFailure
+}
+
+// exitFuncs are run on exit, e.g. to remove temporary files, which deferred calls don't do
+var exitFuncs []func()
+
+// exitStatus is the status the command exits with, set by exit before running the exit functions
+var exitStatus int
+
+// atExit adds f to the functions run on exit
+func atExit(f func()) {
+	exitFuncs = append(exitFuncs, f)
+}
+
+// exit runs the exit functions, prints the JSON report, if any, and exits with code
+func exit(code int) {
+	exitStatus = code
+	for i := len(exitFuncs) - 1; i >= 0; i-- {
+		exitFuncs[i]()
+	}
+	finishOutput()
+	os.Exit(code)
+}
+
+// finishOutput prints the JSON report, if any, once
+func finishOutput() {
+	if jsonReport == nil {
+		return
+	}
+
+	jsonReport.PeakMemoryMB = peakMemoryMB()
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(jsonReport); err != nil {
+		logger.Error("Unable to write JSON output: " + err.Error())
+	}
+	jsonReport = nil
+}
+
+// fileResult is a file written by a command
+type fileResult struct {
+	File  string `json:"file"`
+	Pages int    `json:"pages,omitempty"`
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// credentialProvider fetches secrets such as document passwords from outside the command line
+type credentialProvider interface {
+	lookup(key string) (string, error)
+}
+
+// credentialProviders are the providers of -password-from and the like, by name
+var credentialProviders = map[string]credentialProvider{
+	"env":      envCredentials{},
+	"keychain": keychainCredentials{},
+	"helper":   helperCredentials{},
+}
+
+// fetchCredential fetches the secret of a source such as "env:STATEMENTS_PASSWORD": the name of
+// a provider and the key it looks the secret up by
+func fetchCredential(source string) (string, error) {
+	i := strings.Index(source, ":")
+	if i < 0 {
+		return "", fmt.Errorf("invalid credential source %q, expected provider:key", source)
+	}
+	p, ok := credentialProviders[source[:i]]
+	if !ok {
+		return "", fmt.Errorf("unknown credential provider %q, expected \"env\", \"keychain\" or \"helper\"", source[:i])
+	}
+	if strings.TrimSpace(source[i+1:]) == "" {
+		return "", fmt.Errorf("credential source %q has no key", source)
+	}
+	return p.lookup(source[i+1:])
+}
+
+// envCredentials looks secrets up in the environment variable named by the key
+type envCredentials struct{}
+
+func (envCredentials) lookup(key string) (string, error) {
+	secret, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not set", key)
+	}
+	return secret, nil
+}
+
+// keychainCredentials looks secrets up in the keychain of the OS by a key of service/account, or
+// service alone, with the security tool on macOS and secret-tool of libsecret elsewhere, e.g. the
+// GNOME keyring or KWallet
+type keychainCredentials struct{}
+
+func (keychainCredentials) lookup(key string) (string, error) {
+	service, account := key, ""
+	if i := strings.Index(key, "/"); i >= 0 {
+		service, account = key[:i], key[i+1:]
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-w")
+		if account != "" {
+			cmd.Args = append(cmd.Args, "-a", account)
+		}
+	case "windows":
+		return "", errors.New("keychain credentials not supported on Windows, use a helper")
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", service)
+		if account != "" {
+			cmd.Args = append(cmd.Args, "account", account)
+		}
+	}
+	return runCredentialCommand(cmd)
+}
+
+// helperCredentials runs the key as a command, split at spaces, and takes the first line it
+// prints as the secret, e.g. "pass show statements" for the pass password manager
+type helperCredentials struct{}
+
+func (helperCredentials) lookup(key string) (string, error) {
+	args := strings.Fields(key)
+	return runCredentialCommand(exec.Command(args[0], args[1:]...))
+}
+
+// runCredentialCommand runs cmd and returns the first line of its output. Its stdin and stderr
+// are those of the splitter, so it can prompt, e.g. to unlock a keychain.
+func runCredentialCommand(cmd *exec.Cmd) (string, error) {
+	var stdout bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+	return readPasswordLine(&stdout)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// runDiff compares two PDFs page by page and reports the pages that differ.
+// It exits with status 1 if any page differs, like diff(1).
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	content := fs.Bool("content", false, "also compare page content streams and images, not just extracted text")
+	quiet := fs.Bool("q", false, "only list differing pages, not the differing lines")
+	output := outputFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter diff: [flags] a.pdf b.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("diff", output)
+	defer finishOutput()
+
+	if fs.NArg() != 2 {
+		usage(fs)
+	}
+
+	a, af, err := openPDF(fs.Arg(0))
+	if err != nil {
+		fatalInput(fs.Arg(0), err)
+	}
+	defer af.Close()
+
+	b, bf, err := openPDF(fs.Arg(1))
+	if err != nil {
+		fatalInput(fs.Arg(1), err)
+	}
+	defer bf.Close()
+
+	if !diffPDFs(a, b, *content, *quiet) {
+		exit(exitFailure)
+	}
+}
+
+// diffPDFs reports the differences between a and b and reports whether they are the same
+func diffPDFs(a, b *model.PdfReader, content, quiet bool) bool {
+	same := true
+
+	if len(a.PageList) != len(b.PageList) {
+		reportDiff(diffResult{Kind: "count", Counts: []int{len(a.PageList), len(b.PageList)}})
+		same = false
+	}
+
+	for i := 0; i < len(a.PageList) && i < len(b.PageList); i++ {
+		pa, pb := a.PageList[i], b.PageList[i]
+
+		ta, err := pageText(pa)
+		if err != nil {
+			fatalf("Unable to extract page %d text: %v\n", i+1, err)
+		}
+		tb, err := pageText(pb)
+		if err != nil {
+			fatalf("Unable to extract page %d text: %v\n", i+1, err)
+		}
+
+		if ta != tb {
+			res := diffResult{Kind: "text", Page: i + 1}
+			if !quiet {
+				res.Lines = lineDiff(strings.Split(ta, "\n"), strings.Split(tb, "\n"))
+			}
+			reportDiff(res)
+			same = false
+			continue
+		}
+
+		if content {
+			ha, err := pageHash(pa)
+			if err != nil {
+				fatalf("Unable to hash page %d: %v\n", i+1, err)
+			}
+			hb, err := pageHash(pb)
+			if err != nil {
+				fatalf("Unable to hash page %d: %v\n", i+1, err)
+			}
+			if ha != hb {
+				reportDiff(diffResult{Kind: "content", Page: i + 1})
+				same = false
+			}
+		}
+	}
+
+	return same
+}
+
+// diffResult is a difference found by diff
+type diffResult struct {
+	Kind   string   `json:"kind"`             //"count", "text" or "content"
+	Page   int      `json:"page,omitempty"`   //for "text" and "content"
+	Counts []int    `json:"counts,omitempty"` //page counts of both PDFs, for "count"
+	Lines  []string `json:"lines,omitempty"`  //differing text lines, for "text"
+}
+
+// reportDiff prints a difference, or adds it to the JSON report
+func reportDiff(res diffResult) {
+	if jsonReport != nil {
+		addResult(res)
+		return
+	}
+
+	if res.Kind == "count" {
+		fmt.Printf("Page count differs: %d != %d\n", res.Counts[0], res.Counts[1])
+		return
+	}
+	fmt.Printf("Page %d: %s differs\n", res.Page, res.Kind)
+	for _, line := range res.Lines {
+		fmt.Println(line)
+	}
+}
+
+// lineDiff returns the lines removed from a, prefixed "- ", and added in b, prefixed "+ ",
+// using the longest common subsequence of lines
+func lineDiff(a, b []string) []string {
+	//lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+ "+b[j])
+	}
+	return diff
+}
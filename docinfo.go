@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// metadataVars are the variables of metadata templates besides the -name ones
+var metadataVars = []string{"name", "source", "pages"}
+
+// infoFlags collects repeated -info flags, in order
+type infoFlags []infoEntry
+
+// infoEntry is a custom entry of the document information of outputs
+type infoEntry struct {
+	key  string
+	tmpl nameTemplate
+}
+
+// infoKey matches the keys of -info entries, which are PDF names such as "CaseNumber"
+var infoKey = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+
+func (f *infoFlags) String() string {
+	var defs []string
+	for _, e := range *f {
+		defs = append(defs, e.key+"="+e.tmpl.tmpl)
+	}
+	return strings.Join(defs, " ")
+}
+
+func (f *infoFlags) Set(def string) error {
+	i := strings.Index(def, "=")
+	if i < 0 || !infoKey.MatchString(def[:i]) {
+		return fmt.Errorf("entry %q must be of the form key=template, with a key of letters, digits, \"_\", \".\" and \"-\"", def)
+	}
+	*f = append(*f, infoEntry{key: def[:i], tmpl: nameTemplate{tmpl: def[i+1:]}})
+	return nil
+}
+
+// metadataFlags are the flags setting the file identifier and metadata of outputs
+type metadataFlags struct {
+	inheritID *bool
+	id        *string
+	producer  *string
+	creator   *string
+	info      infoFlags
+	xmpFile   *string
+}
+
+// addMetadataFlags adds the flags setting the file identifier and metadata of outputs to fs
+func addMetadataFlags(fs *flag.FlagSet) *metadataFlags {
+	f := &metadataFlags{
+		inheritID: fs.Bool("inherit-id", false, "keep the first file identifier of the input in the outputs, marking them as derived from it (default an identifier derived from each output)"),
+		id:        fs.String("id", "", "first file identifier of the outputs, as a `template` with the -name variables plus {name}, {source} and {pages}, e.g. \"ACME-{value}\" (default one derived from each output)"),
+		producer:  fs.String("producer", "", "Producer of the outputs, as a `template` like -id (default the PDF library)"),
+		creator:   fs.String("creator", "", "Creator of the outputs, as a `template` like -id (default the PDF library)"),
+		xmpFile:   fs.String("xmp", "", "XMP metadata `template` file for the outputs, with the variables of -id"),
+	}
+	fs.Var(&f.info, "info", "custom document information entry `key=template` of the outputs, with the variables of -id, e.g. \"CaseNumber={value}\" (may be repeated)")
+	return f
+}
+
+// metadata returns the metadata set by the flags, or nil if they keep the default, exiting if
+// they are invalid
+func (f *metadataFlags) metadata() *docMetadata {
+	if *f.id == "" && *f.producer == "" && *f.creator == "" && len(f.info) == 0 && *f.xmpFile == "" {
+		return nil
+	}
+	if *f.id != "" && *f.inheritID {
+		argError("-id and -inherit-id can't both be set")
+	}
+	m, err := newDocMetadata(*f.id, *f.producer, *f.creator, f.info, *f.xmpFile)
+	if err != nil {
+		argError(err)
+	}
+	return m
+}
+
+// docMetadata sets the file identifier, document information and XMP metadata of outputs, e.g. as
+// correlation keys or routing data of a document management system, from templates with the
+// -name variables plus {name}, {source}, the input file, and {pages}, the input pages of the
+// output
+type docMetadata struct {
+	id       nameTemplate
+	producer nameTemplate
+	creator  nameTemplate
+	info     infoFlags //custom entries, in the order given
+	xmp      nameTemplate
+}
+
+// newDocMetadata returns the metadata of the id, producer and creator templates, the custom
+// information entries and the XMP template file xmpFile, any of which may be empty to keep the
+// default
+func newDocMetadata(id, producer, creator string, info infoFlags, xmpFile string) (*docMetadata, error) {
+	m := &docMetadata{id: nameTemplate{tmpl: id}, producer: nameTemplate{tmpl: producer}, creator: nameTemplate{tmpl: creator}, info: info}
+	if xmpFile != "" {
+		data, err := os.ReadFile(xmpFile)
+		if err != nil {
+			return nil, err
+		}
+		m.xmp.tmpl = string(data)
+		if err = checkXML(m.xmp.expandRaw(nil)); err != nil {
+			return nil, fmt.Errorf("-xmp: %v", err)
+		}
+	}
+
+	for _, t := range []struct {
+		flag string
+		tmpl nameTemplate
+	}{{"-id", m.id}, {"-producer", m.producer}, {"-creator", m.creator}, {"-xmp", m.xmp}} {
+		if err := t.tmpl.check(metadataVars...); err != nil {
+			return nil, fmt.Errorf("%s: %v", t.flag, err)
+		}
+	}
+	for _, e := range info {
+		if err := e.tmpl.check(metadataVars...); err != nil {
+			return nil, fmt.Errorf("-info %s: %v", e.key, err)
+		}
+	}
+	return m, nil
+}
+
+// apply sets the metadata of the output name holding the pageRanges of the input source, with the
+// given template variables, on w. The -id value becomes the first, permanent element of the file
+// identifier.
+func (m *docMetadata) apply(w *model.PdfWriter, name, source, pageRanges string, vars map[string]string) error {
+	all := map[string]string{"name": name, "source": path.Base(source), "pages": pageRanges}
+	for k, v := range vars {
+		all[k] = v
+	}
+
+	if id := m.id.expandRaw(all); id != "" {
+		w.SetOriginalID(id)
+	}
+	if producer := m.producer.expandRaw(all); producer != "" {
+		w.GetInfo().Set("Producer", core.MakeString(encodeTextString(producer)))
+	}
+	if creator := m.creator.expandRaw(all); creator != "" {
+		w.GetInfo().Set("Creator", core.MakeString(encodeTextString(creator)))
+	}
+	for _, e := range m.info {
+		if value := e.tmpl.expandRaw(all); value != "" {
+			w.GetInfo().Set(core.PdfObjectName(e.key), core.MakeString(encodeTextString(value)))
+		}
+	}
+
+	if m.xmp.tmpl == "" {
+		return nil
+	}
+	escaped := map[string]string{}
+	for k, v := range all {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(v))
+		escaped[k] = b.String()
+	}
+	packet := m.xmp.expandRaw(escaped)
+	if !strings.HasPrefix(strings.TrimSpace(packet), "<?xpacket") {
+		packet = "<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" + packet + "\n<?xpacket end=\"w\"?>"
+	}
+	xmp := core.MakeDict()
+	xmp.Set("Type", core.MakeName("Metadata"))
+	xmp.Set("Subtype", core.MakeName("XML"))
+	xmp.Set("Length", core.MakeInteger(int64(len(packet))))
+	return w.SetCatalogEntry("Metadata", &core.PdfObjectStream{PdfObjectDictionary: xmp, Stream: []byte(packet)})
+}
+
+// checkXML returns an error if s isn't well-formed XML
+func checkXML(s string) error {
+	d := xml.NewDecoder(strings.NewReader(s))
+	elements := 0
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if _, ok := t.(xml.StartElement); ok {
+			elements++
+		}
+	}
+	if elements == 0 {
+		return errors.New("no XML elements")
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// duplicate page handling modes for -dupes
+const (
+	dupesReport = "report"
+	dupesDrop   = "drop"
+)
+
+// pageHash returns a hash of the page content streams and the raw data of the XObjects
+// (scanned images, forms) in its resources. Identical pages hash the same.
+func pageHash(p *model.PdfPage) (string, error) {
+	h := sha256.New()
+
+	contents, err := p.GetAllContentStreams()
+	if err != nil {
+		return "", err
+	}
+	h.Write([]byte(contents))
+
+	if p.Resources != nil {
+		if xobjs, ok := core.TraceToDirectObject(p.Resources.XObject).(*core.PdfObjectDictionary); ok {
+			keys := xobjs.Keys()
+			sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+			for _, name := range keys {
+				h.Write([]byte(name))
+				if stream, ok := core.TraceToDirectObject(xobjs.Get(name)).(*core.PdfObjectStream); ok {
+					h.Write(stream.Stream)
+				}
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// pageHashes returns the content hash of each page. Pages that can't be hashed get an
+// empty hash and are never considered duplicates.
+func pageHashes(pages []*model.PdfPage) []string {
+	hashes := make([]string, len(pages))
+	for i, p := range pages {
+		hash, err := pageHash(p)
+		if err != nil {
+			warning("Unable to hash page %d: %v", i+1, err)
+			continue
+		}
+		hashes[i] = hash
+	}
+	return hashes
+}
+
+// reportDuplicates logs each page whose content is identical to an earlier page
+func reportDuplicates(hashes []string) {
+	first := map[string]int{}
+	for i, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		if n, ok := first[hash]; ok {
+			warning("Page %d is a duplicate of page %d", i+1, n)
+			continue
+		}
+		first[hash] = i + 1
+	}
+}
+
+// isDuplicate reports whether two page hashes are known and identical
+func isDuplicate(a, b string) bool {
+	return a != "" && a == b
+}
+
+// dupesIndex is the -dupes-index file of the hashes of the pages of earlier inputs of a batch,
+// one line of hash, input and page number, tab separated, for each distinct page
+type dupesIndex struct {
+	fn   string
+	seen map[string]string //hash to where the page was first seen, e.g. "in.pdf page 3"
+}
+
+// readDupesIndex reads the index fn, which is empty if it doesn't exist yet
+func readDupesIndex(fn string) (*dupesIndex, error) {
+	x := &dupesIndex{fn: fn, seen: map[string]string{}}
+	f, err := os.Open(fn)
+	if errors.Is(err, fs.ErrNotExist) {
+		return x, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected hash, input and page", fn, line)
+		}
+		if _, ok := x.seen[fields[0]]; !ok {
+			x.seen[fields[0]] = fields[1] + " page " + fields[2]
+		}
+	}
+	return x, scanner.Err()
+}
+
+// match returns where each page of hashes was seen in an earlier input, "" if it wasn't
+func (x *dupesIndex) match(hashes []string) []string {
+	seen := make([]string, len(hashes))
+	for i, hash := range hashes {
+		if hash != "" {
+			seen[i] = x.seen[hash]
+		}
+	}
+	return seen
+}
+
+// add appends the hashes of the pages of the input in not in the index yet
+func (x *dupesIndex) add(in string, hashes []string) error {
+	f, err := os.OpenFile(x.fn, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for i, hash := range hashes {
+		if _, ok := x.seen[hash]; ok || hash == "" {
+			continue
+		}
+		x.seen[hash] = in + " page " + strconv.Itoa(i+1)
+		fmt.Fprintf(w, "%s\t%s\t%d\n", hash, in, i+1)
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// encryption algorithms for -encrypt
+const (
+	encryptAES256 = "aes256"
+	encryptAES128 = "aes128"
+	encryptRC4    = "rc4"
+)
+
+var encryptAlgorithms = map[string]model.EncryptionAlgorithm{
+	encryptAES256: model.AES_256bit,
+	encryptAES128: model.AES_128bit,
+	encryptRC4:    model.RC4_128bit,
+}
+
+// allPermissions lets the password protect opening an output, not what can be done with it
+// once open
+var allPermissions = core.AccessPermissions{
+	Printing:          true,
+	Modify:            true,
+	ExtractGraphics:   true,
+	Annotate:          true,
+	FillForms:         true,
+	DisabilityExtract: true,
+	RotateInsert:      true,
+	FullPrintQuality:  true,
+}
+
+// encryption password protects outputs. Each output's user password comes from the first of
+// the password list, the password template and the fixed password that gives one.
+type encryption struct {
+	algorithm     model.EncryptionAlgorithm
+	owner         string                 //owner password, random if not given
+	perms         core.AccessPermissions //allowed without the owner password
+	plainMetadata bool                   //leave XMP metadata unencrypted, for indexing
+	password      string                 //fixed user password
+	template      nameTemplate           //user password template, using the output's name variables
+	match         *regexp.Regexp         //capture group in the output's first page text, for {match}
+	list          map[string]string      //user passwords by output name
+	report        *csv.Writer            //if set, output names and passwords are written to it
+	reportF       *os.File
+}
+
+// newEncryption returns the encryption for the given algorithm and owner password.
+// An empty owner password is replaced by a random one, as anyone could otherwise open the
+// outputs with full permissions.
+func newEncryption(algorithm, owner string) (*encryption, error) {
+	algo, ok := encryptAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption %q, must be aes256, aes128 or rc4", algorithm)
+	}
+
+	if owner == "" {
+		b := make([]byte, 16)
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return nil, err
+		}
+		owner = hex.EncodeToString(b)
+	}
+
+	return &encryption{algorithm: algo, owner: owner, perms: allPermissions}, nil
+}
+
+// parsePermissions returns the permissions in a comma separated list of permission names,
+// "all" or "none"
+func parsePermissions(s string) (core.AccessPermissions, error) {
+	var perms core.AccessPermissions
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "all":
+			perms = allPermissions
+		case "none":
+		case "print":
+			perms.Printing = true
+		case "print-high":
+			perms.Printing = true
+			perms.FullPrintQuality = true
+		case "modify":
+			perms.Modify = true
+		case "copy":
+			perms.ExtractGraphics = true
+		case "annotate":
+			perms.Annotate = true
+		case "forms":
+			perms.FillForms = true
+		case "accessibility":
+			perms.DisabilityExtract = true
+		case "assemble":
+			perms.RotateInsert = true
+		default:
+			return perms, fmt.Errorf("unknown permission %q", name)
+		}
+	}
+
+	return perms, nil
+}
+
+// loadPasswords reads a CSV file of output name, password rows
+func loadPasswords(fn string) (map[string]string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	list := map[string]string{}
+	for _, row := range rows {
+		list[row[0]] = row[1]
+	}
+
+	return list, nil
+}
+
+// createReport creates the password report fn, readable by the owner only
+func (e *encryption) createReport(fn string) error {
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	e.reportF = f
+	e.report = csv.NewWriter(f)
+	return nil
+}
+
+// close finishes the password report, if any
+func (e *encryption) close() error {
+	if e.report == nil {
+		return nil
+	}
+
+	e.report.Flush()
+	if err := e.report.Error(); err != nil {
+		e.reportF.Close()
+		return err
+	}
+	return e.reportF.Close()
+}
+
+// userPassword returns the user password for the output name with the given pages and
+// template variables
+func (e *encryption) userPassword(name string, pages []*model.PdfPage, vars map[string]string) (string, error) {
+	if password, ok := e.list[name]; ok {
+		return password, nil
+	}
+
+	if e.template.tmpl != "" {
+		all := map[string]string{"name": name}
+		for k, v := range vars {
+			all[k] = v
+		}
+
+		if e.match != nil && len(pages) > 0 {
+			text, err := pageText(pages[0])
+			if err != nil {
+				return "", fmt.Errorf("unable to extract text for %s password: %v", name, err)
+			}
+			matches := e.match.FindStringSubmatch(text)
+			if len(matches) != 2 {
+				return "", fmt.Errorf("unable to locate password value for %s", name)
+			}
+			all["match"] = matches[1]
+		}
+
+		if password := e.template.expandRaw(all); password != "" {
+			return password, nil
+		}
+	}
+
+	if e.password != "" {
+		return e.password, nil
+	}
+
+	return "", fmt.Errorf("no password for %s", name)
+}
+
+// encrypt returns data, an unencrypted PDF, encrypted with the user password.
+// The writer encrypts objects in place, so the PDF is read back first rather than encrypting
+// page objects shared with other outputs.
+func (e *encryption) encrypt(name string, data []byte, password string) ([]byte, error) {
+	pdf, err := model.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if encrypted, err := pdf.IsEncrypted(); err != nil || encrypted {
+		return nil, errors.New("PDF is already encrypted")
+	}
+
+	w, err := newPageWriter(pdf.PageList)
+	if err != nil {
+		return nil, err
+	}
+	if err = copyOutline(w, pdf); err != nil {
+		return nil, err
+	}
+	if err = keepCatalog(w, pdf); err != nil {
+		return nil, err
+	}
+	keepIdentity(w, pdf, data)
+	if err = w.Encrypt([]byte(password), []byte(e.owner), &model.EncryptOptions{Permissions: e.perms, Algorithm: e.algorithm, UnencryptedMetadata: e.plainMetadata}); err != nil {
+		return nil, err
+	}
+
+	var buf seekBuffer
+	if err = w.Write(&buf); err != nil {
+		return nil, err
+	}
+
+	//flush each row so the report covers every output written, even if a later one fails
+	if e.report != nil {
+		e.report.Write([]string{name, password})
+		if e.report.Flush(); e.report.Error() != nil {
+			return nil, errors.New("unable to write password report")
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// runEncrypt password protects a PDF without splitting it
+func runEncrypt(args []string) {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	output := outputFlags(fs)
+	out := fs.String("out", "", "output PDF")
+	algo := fs.String("encrypt", encryptAES256, "encryption: \"aes256\", \"aes128\" or \"rc4\"")
+	password := fs.String("password", "", "user password needed to open the output (default none)")
+	ownerPassword := fs.String("owner-password", "", "owner password, which lifts the -allow restrictions (default random)")
+	allow := fs.String("allow", "all", "comma separated `permissions` without the owner password: \"print\", \"print-high\", \"modify\", \"copy\", \"annotate\", \"forms\", \"accessibility\", \"assemble\", \"all\" or \"none\"")
+	encryptMetadata := fs.Bool("encrypt-metadata", true, "encrypt the XMP metadata (false needs aes128 or aes256)")
+	passwordSources := []*passwordFlags{
+		addPasswordFlags(fs, "password", password),
+		addPasswordFlags(fs, "owner-password", ownerPassword),
+	}
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter encrypt: -out protected.pdf [flags] input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("encrypt", output)
+	defer finishOutput()
+
+	if *out == "" || fs.NArg() != 1 {
+		usage(fs)
+	}
+	if err := readPasswords(passwordSources...); err != nil {
+		argError(err)
+	}
+
+	enc, err := newEncryption(*algo, *ownerPassword)
+	if err != nil {
+		argError(err)
+	}
+	if enc.perms, err = parsePermissions(*allow); err != nil {
+		argError("Invalid -allow:", err)
+	}
+	if *password == "" && enc.perms == allPermissions {
+		argError("-password or -allow must be set")
+	}
+	if !*encryptMetadata {
+		if *algo == encryptRC4 {
+			argError("-encrypt-metadata=false needs -encrypt aes128 or aes256")
+		}
+		enc.plainMetadata = true
+	}
+
+	in := fs.Arg(0)
+	data, err := os.ReadFile(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+
+	if data, err = enc.encrypt(*out, data, *password); err != nil {
+		checkEncryption(in, err)
+		fatal("Unable to encrypt", in+":", err)
+	}
+
+	logInfo("Writing", *out)
+	if err = os.WriteFile(*out, data, 0644); err != nil {
+		exitError(exitWrite, "Unable to write PDF file", *out+":", err)
+	}
+	addResult(fileResult{File: *out})
+}
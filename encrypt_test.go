@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/unidoc/unidoc/pdf/core"
+)
+
+// rawPDF returns a PDF of the numbered objects, the first of them the catalog, with the cross
+// reference table written for them
+func rawPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// Test that the encrypt command keeps the catalog of its input: its form, attachments, initial
+// view, page labels and structure tree.
+func TestEncryptKeepsCatalog(t *testing.T) {
+	input := rawPDF(
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [6 0 R] >> /Names << /EmbeddedFiles << /Names [(a.txt) 9 0 R] >> >> "+
+			"/ViewerPreferences << /HideToolbar true >> /PageLayout /TwoColumnLeft /PageLabels << /Nums [0 << /S /r >>] >> /StructTreeRoot 11 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 5 0 R /Annots [7 0 R] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 5 0 R /Annots [8 0 R] >>",
+		"<< /Length 0 >>\nstream\n\nendstream",
+		"<< /T (recipient) /FT /Tx /Kids [7 0 R 8 0 R] >>",
+		"<< /Type /Annot /Subtype /Widget /Parent 6 0 R /T (0) /V (Alice) /Rect [0 0 100 20] /P 3 0 R >>",
+		"<< /Type /Annot /Subtype /Widget /Parent 6 0 R /T (1) /V (Bob) /Rect [0 0 100 20] /P 4 0 R >>",
+		"<< /Type /Filespec /F (a.txt) /EF << /F 10 0 R >> >>",
+		"<< /Type /EmbeddedFile /Length 8 >>\nstream\nattached\nendstream",
+		"<< /Type /StructTreeRoot >>",
+	)
+
+	for _, algorithm := range []string{encryptAES256, encryptAES128, encryptRC4} {
+		enc, err := newEncryption(algorithm, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := enc.encrypt("input.pdf", input, "x")
+		if err != nil {
+			t.Fatalf("%s: %v", algorithm, err)
+		}
+		fn := path.Join(t.TempDir(), "out.pdf")
+		if err = os.WriteFile(fn, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, catalog := readOutput(t, fn, "x")
+		for i, expected := range []struct{ name, value string }{{"recipient.0", "Alice"}, {"recipient.1", "Bob"}} {
+			if len(out.PageList[i].Annotations) != 1 {
+				t.Errorf("%s: page %d has %d annotations, expected 1", algorithm, i+1, len(out.PageList[i].Annotations))
+				continue
+			}
+			widget := out.PageList[i].Annotations[0].GetContainingPdfObject().(*core.PdfIndirectObject).PdfObject.(*core.PdfObjectDictionary)
+			f := fieldOf(out, widget)
+			if value := fieldString(out, f.value); f.name != expected.name || value != expected.value {
+				t.Errorf("%s: page %d has field %s=%q, expected %s=%q", algorithm, i+1, f.name, value, expected.name, expected.value)
+			}
+		}
+		if form, ok := resolve(out, catalog.Get("AcroForm")).(*core.PdfObjectDictionary); !ok || form.Get("Fields") == nil {
+			t.Errorf("%s: AcroForm %v, expected the fields", algorithm, catalog.Get("AcroForm"))
+		}
+		if got := attachmentData(t, out, catalog); got["a.txt"] != "attached" {
+			t.Errorf("%s: attachments %v, expected a.txt", algorithm, got)
+		}
+		if prefs, ok := resolve(out, catalog.Get("ViewerPreferences")).(*core.PdfObjectDictionary); !ok || prefs.Get("HideToolbar") == nil {
+			t.Errorf("%s: ViewerPreferences %v, expected HideToolbar", algorithm, catalog.Get("ViewerPreferences"))
+		}
+		if layout, ok := resolve(out, catalog.Get("PageLayout")).(*core.PdfObjectName); !ok || *layout != "TwoColumnLeft" {
+			t.Errorf("%s: PageLayout %v, expected TwoColumnLeft", algorithm, catalog.Get("PageLayout"))
+		}
+		if _, ok := resolve(out, catalog.Get("PageLabels")).(*core.PdfObjectDictionary); !ok {
+			t.Errorf("%s: no PageLabels", algorithm)
+		}
+		if tree, ok := resolve(out, catalog.Get("StructTreeRoot")).(*core.PdfObjectDictionary); !ok || tree.Get("Type") == nil {
+			t.Errorf("%s: StructTreeRoot %v", algorithm, catalog.Get("StructTreeRoot"))
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// eventsPasswordEnv is the environment variable with the password of the -events user, or the
+// NATS token if the URL has no user
+const eventsPasswordEnv = "PDF_SPLITTER_EVENTS_PASSWORD"
+
+// eventVersion is the version of the event schema, raised on incompatible changes
+const eventVersion = 1
+
+// event types
+const (
+	eventJobStarted  = "job.started"
+	eventPartWritten = "part.written"
+	eventJobFinished = "job.finished"
+)
+
+// eventTimeout limits connecting to the event broker and each publish
+const eventTimeout = 10 * time.Second
+
+// splitEvent is a JSON event published to -events. Every event has the version, type, time, job
+// and source; the other fields depend on the type.
+type splitEvent struct {
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+	Time    string `json:"time"` //RFC 3339, UTC
+	Job     string `json:"job"`
+	Source  string `json:"source"`
+
+	//job.started: the input page count; part.written: the output page count
+	PageCount int `json:"page_count,omitempty"`
+
+	//part.written
+	Output     string `json:"output,omitempty"`
+	Pages      string `json:"pages,omitempty"` //input pages, e.g. "1-3,7"
+	Bytes      *int64 `json:"bytes,omitempty"` //if written
+	DurationMS *int64 `json:"duration_ms,omitempty"`
+	Status     string `json:"status,omitempty"` //written, skipped or failed
+	Error      string `json:"error,omitempty"`
+
+	//job.finished
+	Outputs    *eventCounts `json:"outputs,omitempty"`
+	ExitStatus *int         `json:"exit_status,omitempty"`
+
+	//part.written and job.finished: peak resident set size of the split so far, if known
+	PeakMemoryMB *float64 `json:"peak_memory_mb,omitempty"`
+}
+
+// eventCounts counts the outputs of a job by status
+type eventCounts struct {
+	Written int `json:"written"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// eventSink is a broker events are published to
+type eventSink interface {
+	publish(data []byte) error
+	close() error
+}
+
+// eventPublisher publishes the events of a job. Failing to publish an event is logged as a
+// warning rather than failing the split.
+type eventPublisher struct {
+	sink     eventSink
+	url      string //redacted
+	job      string
+	source   string
+	counts   eventCounts
+	finished bool
+}
+
+// newEventPublisher connects to the broker at the -events URL rawURL, either
+// "nats://host:4222/subject" or "kafka+http://host:8082/topic" for a Kafka REST Proxy, with
+// "kafka+https" or "tls" in place of "nats" for TLS, to publish the events of splitting source
+func newEventPublisher(rawURL, job, source string) (*eventPublisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("events URL %s needs a host and a subject or topic", u.Redacted())
+	}
+	if _, ok := u.User.Password(); ok {
+		return nil, fmt.Errorf("events URL %s must not contain a password, set %s instead", u.Redacted(), eventsPasswordEnv)
+	}
+
+	var sink eventSink
+	switch u.Scheme {
+	case "nats", "tls":
+		sink, err = dialNATS(u)
+	case "kafka+http", "kafka+https":
+		sink, err = newKafkaREST(u)
+	default:
+		return nil, fmt.Errorf("events URL %s must be nats, tls, kafka+http or kafka+https", u.Redacted())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &eventPublisher{sink: sink, url: u.Redacted(), job: job, source: source}, nil
+}
+
+// send publishes e, filling in the common fields
+func (p *eventPublisher) send(e splitEvent) {
+	e.Version = eventVersion
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	e.Job = p.job
+	e.Source = p.source
+
+	data, err := json.Marshal(e)
+	if err == nil {
+		err = p.sink.publish(data)
+	}
+	if err != nil {
+		warning("Unable to publish %s event to %s: %v", e.Type, p.url, err)
+	}
+}
+
+// start publishes the job.started event, once the input is loaded with the given page count
+func (p *eventPublisher) start(pages int) {
+	p.send(splitEvent{Type: eventJobStarted, PageCount: pages})
+}
+
+// output publishes the part.written event of an output, whether it was written or not
+func (p *eventPublisher) output(rec outputRecord) {
+	status, msg := rec.outcome()
+	ms := rec.duration.Milliseconds()
+	e := splitEvent{Type: eventPartWritten, Output: rec.status.file, Pages: rec.pageRanges, PageCount: rec.pages, DurationMS: &ms, Status: status, Error: msg, PeakMemoryMB: peakMemoryMB()}
+	switch status {
+	case statusWritten:
+		p.counts.Written++
+		e.Bytes = &rec.status.size
+	case statusSkipped:
+		p.counts.Skipped++
+	default:
+		p.counts.Failed++
+	}
+	p.send(e)
+}
+
+// finish publishes the job.finished event with the exit status of the command and disconnects,
+// once
+func (p *eventPublisher) finish(exitStatus int) {
+	if p.finished {
+		return
+	}
+	p.finished = true
+
+	counts := p.counts
+	p.send(splitEvent{Type: eventJobFinished, Outputs: &counts, ExitStatus: &exitStatus, PeakMemoryMB: peakMemoryMB()})
+	if err := p.sink.close(); err != nil {
+		warning("Unable to publish events to %s: %v", p.url, err)
+	}
+}
+
+// natsSink publishes to a subject of a NATS server with the NATS client protocol
+type natsSink struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	subject string
+}
+
+// natsInfo is the part of the INFO a NATS server sends on connecting that clients need
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+// dialNATS connects to the NATS server at u, publishing to the subject in its path
+func dialNATS(u *url.URL) (*natsSink, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	conn, err := net.DialTimeout("tcp", host, eventTimeout)
+	if err != nil {
+		return nil, err
+	}
+	s := &natsSink{conn: conn, r: bufio.NewReader(conn), subject: strings.Trim(u.Path, "/")}
+	if strings.ContainsAny(s.subject, " \t\r\n") || strings.Contains(s.subject, "/") {
+		conn.Close()
+		return nil, fmt.Errorf("invalid NATS subject %q", s.subject)
+	}
+	if err = s.handshake(u); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("NATS server %s: %v", u.Host, err)
+	}
+	return s, nil
+}
+
+// handshake reads the INFO of the server, upgrading to TLS if needed, and sends CONNECT with the
+// credentials, waiting for the server to accept them
+func (s *natsSink) handshake(u *url.URL) error {
+	s.conn.SetDeadline(time.Now().Add(eventTimeout))
+	defer s.conn.SetDeadline(time.Time{})
+
+	line, err := s.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+	var info natsInfo
+	if err = json.Unmarshal([]byte(line[len("INFO "):]), &info); err != nil {
+		return fmt.Errorf("invalid INFO: %v", err)
+	}
+
+	if info.TLSRequired || u.Scheme == "tls" {
+		conn := tls.Client(s.conn, &tls.Config{ServerName: u.Hostname()})
+		if err = conn.Handshake(); err != nil {
+			return err
+		}
+		s.conn, s.r = conn, bufio.NewReader(conn)
+	}
+
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "pdf-splitter", "lang": "go", "version": "1.0.0", "protocol": 0}
+	if pw := os.Getenv(eventsPasswordEnv); u.User != nil {
+		opts["user"], opts["pass"] = u.User.Username(), pw
+	} else if pw != "" {
+		opts["auth_token"] = pw
+	}
+	connect, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(s.conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return err
+	}
+	return s.pong()
+}
+
+// pong waits for the PONG answering a PING, answering the PINGs of the server on the way
+func (s *natsSink) pong() error {
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err = io.WriteString(s.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+	}
+}
+
+func (s *natsSink) publish(data []byte) error {
+	s.conn.SetWriteDeadline(time.Now().Add(eventTimeout))
+	_, err := fmt.Fprintf(s.conn, "PUB %s %d\r\n%s\r\n", s.subject, len(data), data)
+	return err
+}
+
+// close flushes the events with a PING, so errors of the server are reported, and disconnects
+func (s *natsSink) close() error {
+	s.conn.SetDeadline(time.Now().Add(eventTimeout))
+	_, err := io.WriteString(s.conn, "PING\r\n")
+	if err == nil {
+		err = s.pong()
+	}
+	if cerr := s.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// kafkaREST publishes to a Kafka topic through a Confluent Kafka REST Proxy, with the v2 API
+type kafkaREST struct {
+	url    string //of the topic
+	user   string
+	client *http.Client
+}
+
+// newKafkaREST returns a sink publishing to the topic in the path of u
+func newKafkaREST(u *url.URL) (*kafkaREST, error) {
+	topic := strings.Trim(u.Path, "/")
+	if strings.Contains(topic, "/") {
+		return nil, fmt.Errorf("invalid Kafka topic %q", topic)
+	}
+	k := &kafkaREST{client: &http.Client{Timeout: eventTimeout}}
+	target := url.URL{Scheme: strings.TrimPrefix(u.Scheme, "kafka+"), Host: u.Host, Path: "/topics/" + topic}
+	k.url = target.String()
+	if u.User != nil {
+		k.user = u.User.Username()
+	}
+	return k, nil
+}
+
+// kafkaResponse is the part of the response of the REST Proxy to a produce request with errors
+type kafkaResponse struct {
+	Offsets []struct {
+		Error string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (k *kafkaREST) publish(data []byte) error {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, `{"records":[{"value":%s}]}`, data)
+	req, err := http.NewRequest("POST", k.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if k.user != "" {
+		req.SetBasicAuth(k.user, os.Getenv(eventsPasswordEnv))
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Kafka REST Proxy: %s", resp.Status)
+	}
+
+	var result kafkaResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("Kafka REST Proxy: %v", err)
+	}
+	for _, o := range result.Offsets {
+		if o.Error != "" {
+			return fmt.Errorf("Kafka REST Proxy: %s", o.Error)
+		}
+	}
+	return nil
+}
+
+func (k *kafkaREST) close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// runExtract writes a set of page ranges of a PDF, in the order given, to a single PDF, an
+// excerpt, named and given metadata like the outputs of a split
+func runExtract(args []string) {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	output := outputFlags(fs)
+	out := fs.String("out", "", "output PDF, whose name may be a `template` with the document information variables of -name plus {page} and {pages}, e.g. \"{title} excerpt.pdf\"")
+	ranges := fs.String("pages", "", "input pages and page `ranges` to extract, e.g. \"1-3,9,20-\"")
+	nameOpts := addNameFlags(fs)
+	metadataOpts := addMetadataFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter extract: -out excerpt.pdf -pages ranges [flags] input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("extract", output)
+	defer finishOutput()
+
+	if *out == "" || *ranges == "" || fs.NArg() != 1 {
+		usage(fs)
+	}
+
+	//the directory is taken as given, the file name is a template
+	tmpl := nameTemplate{tmpl: filepath.Base(*out), names: nameOpts.options()}
+	if err := tmpl.check("pages"); err != nil {
+		argError(err)
+	}
+	ow := &outputWriter{dir: filepath.Dir(*out), metadata: metadataOpts.metadata()}
+
+	in := fs.Arg(0)
+	pdf, f, err := openPDF(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	numbers, err := parseRanges(*ranges, len(pdf.PageList))
+	if err != nil {
+		argError("Invalid -pages:", err)
+	}
+	pages := make([]*model.PdfPage, len(numbers))
+	for i, n := range numbers {
+		pages[i] = pdf.PageList[n-1]
+	}
+
+	ow.setInput(in, pdf)
+	if *metadataOpts.inheritID {
+		if trailer, err := pdf.GetTrailer(); err == nil {
+			ow.originalID = firstID(trailer)
+		}
+	}
+
+	vars := docVars(docInfo(pdf))
+	vars["page"] = strconv.Itoa(numbers[0])
+	vars["pages"] = formatRanges(ow.inputPages, pages)
+	if err = ow.write(tmpl.expand(vars), pages, vars); err != nil {
+		exitError(writeStatus(err), err)
+	}
+	if err = ow.close(); err != nil {
+		exitError(writeStatus(err), "Unable to finish output:", err)
+	}
+
+	logInfo("Wrote", len(pages), "pages.")
+}
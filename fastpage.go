@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+
+	"github.com/unidoc/unidoc/pdf/core"
+)
+
+// inheritedPageKeys are the page attributes that may be set on an ancestor Pages node
+var inheritedPageKeys = []core.PdfObjectName{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// writeSinglePagePart writes def directly from the raw parser if it selects exactly one page.
+// This skips loading and traversing the whole document, which model.NewPdfReader always does.
+// It returns false if def must be handled by the normal path instead, and a *partError if def is
+// invalid.
+// If warn is set, malformed objects are worked around, calling warn for each. If inheritID is set,
+// the output keeps the first file identifier of the input.
+func writeSinglePagePart(rs io.ReadSeeker, def string, dir string, warn func(core.Warning), inheritID bool) (bool, error) {
+	parser, err := core.NewParser(rs)
+	if err != nil {
+		return false, nil
+	}
+	parser.SetLenient(warn)
+
+	//encrypted files need the full reader to decrypt
+	if encrypted, err := parser.IsEncrypted(); err != nil || encrypted {
+		return false, nil
+	}
+
+	pages, err := lookupPages(parser)
+	if err != nil {
+		return false, nil
+	}
+	count, ok := core.TraceToDirectObject(pages.PdfObject.(*core.PdfObjectDictionary).Get("Count")).(*core.PdfObjectInteger)
+	if !ok {
+		return false, nil
+	}
+
+	//the initial view of the input needs the full reader to be moved to the output page
+	if root, err := parser.Trace(parser.GetTrailer().Get("Root")); err == nil {
+		if catalog, ok := root.(*core.PdfObjectDictionary); ok {
+			for _, key := range viewerEntries {
+				if catalog.Get(key) != nil {
+					return false, nil
+				}
+			}
+		}
+	}
+
+	pt, err := parsePart(def, int(*count))
+	if err != nil {
+		return false, &partError{err}
+	}
+	if len(pt.pages) != 1 {
+		return false, nil
+	}
+
+	fn := path.Join(dir, pt.name)
+
+	logInfo("Writing", fn)
+
+	originalID := ""
+	if inheritID {
+		originalID = firstID(parser.GetTrailer())
+	}
+	if err = writeSinglePage(parser, pages, pt.pages[0], fn, originalID); err != nil {
+		return false, err
+	}
+
+	addResult(partResult{File: fn, Pages: 1, SourcePages: []int{pt.pages[0]}})
+	logInfo("Wrote 1 parts.")
+	checkMemory("writing " + fn)
+
+	return true, nil
+}
+
+// partError is an invalid part definition, which is an argument error rather than a failure to
+// write the part
+type partError struct {
+	err error
+}
+
+func (e *partError) Error() string {
+	return e.err.Error()
+}
+
+// lookupPages returns the root Pages node of the document
+func lookupPages(parser *core.PdfParser) (*core.PdfIndirectObject, error) {
+	trailer := parser.GetTrailer()
+	if trailer == nil {
+		return nil, errors.New("missing trailer")
+	}
+
+	root, err := parser.Trace(trailer.Get("Root"))
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := root.(*core.PdfObjectDictionary)
+	if !ok {
+		return nil, errors.New("invalid catalog")
+	}
+
+	ref, ok := catalog.Get("Pages").(*core.PdfObjectReference)
+	if !ok {
+		return nil, errors.New("invalid Pages reference")
+	}
+	obj, err := parser.LookupByReference(*ref)
+	if err != nil {
+		return nil, err
+	}
+	pages, ok := obj.(*core.PdfIndirectObject)
+	if !ok {
+		return nil, errors.New("invalid Pages object")
+	}
+	if _, ok = pages.PdfObject.(*core.PdfObjectDictionary); !ok {
+		return nil, errors.New("invalid Pages dictionary")
+	}
+
+	return pages, nil
+}
+
+// findPage descends the page tree to page n (1-based), using each node's Count to skip
+// whole subtrees. Inherited attributes found on the way are copied into the page dictionary.
+func findPage(parser *core.PdfParser, node *core.PdfIndirectObject, n int) (*core.PdfIndirectObject, error) {
+	inherited := core.MakeDict()
+
+	for depth := 0; depth < core.TraceMaxDepth; depth++ {
+		dict := node.PdfObject.(*core.PdfObjectDictionary)
+		for _, key := range inheritedPageKeys {
+			if v := dict.Get(key); v != nil {
+				inherited.Set(key, v)
+			}
+		}
+
+		kidsObj, err := parser.Trace(dict.Get("Kids"))
+		if err != nil {
+			return nil, err
+		}
+		kids, ok := kidsObj.(*core.PdfObjectArray)
+		if !ok {
+			return nil, errors.New("invalid Kids array")
+		}
+
+		var next *core.PdfIndirectObject
+		for _, kidRef := range *kids {
+			ref, ok := kidRef.(*core.PdfObjectReference)
+			if !ok {
+				return nil, errors.New("page tree kid is not a reference")
+			}
+			obj, err := parser.LookupByReference(*ref)
+			if err != nil {
+				return nil, err
+			}
+			kid, ok := obj.(*core.PdfIndirectObject)
+			if !ok {
+				return nil, errors.New("invalid page tree node")
+			}
+			kidDict, ok := kid.PdfObject.(*core.PdfObjectDictionary)
+			if !ok {
+				return nil, errors.New("invalid page tree node dictionary")
+			}
+
+			if t, _ := kidDict.Get("Type").(*core.PdfObjectName); t != nil && *t == "Pages" {
+				count, ok := core.TraceToDirectObject(kidDict.Get("Count")).(*core.PdfObjectInteger)
+				if !ok {
+					return nil, errors.New("invalid Pages Count")
+				}
+				if n > int(*count) {
+					n -= int(*count)
+					continue
+				}
+				next = kid
+				break
+			}
+
+			if n == 1 {
+				for _, key := range inheritedPageKeys {
+					if kidDict.Get(key) == nil && inherited.Get(key) != nil {
+						kidDict.Set(key, inherited.Get(key))
+					}
+				}
+				return kid, nil
+			}
+			n--
+		}
+
+		if next == nil {
+			return nil, errors.New("page not found in page tree")
+		}
+		node = next
+	}
+
+	return nil, errors.New("page tree too deep")
+}
+
+// resolvePageObjects replaces every reference reachable from obj with the object it refers to
+// and returns the indirect and stream objects found, in discovery order. References to other
+// page tree nodes are replaced with null so only the selected page is pulled in.
+func resolvePageObjects(parser *core.PdfParser, page *core.PdfIndirectObject) ([]core.PdfObject, error) {
+	objects := []core.PdfObject{page}
+	seen := map[core.PdfObject]bool{page: true}
+
+	var resolve func(obj core.PdfObject) (core.PdfObject, error)
+	resolve = func(obj core.PdfObject) (core.PdfObject, error) {
+		switch o := obj.(type) {
+		case *core.PdfObjectReference:
+			target, err := parser.LookupByReference(*o)
+			if err != nil {
+				return nil, err
+			}
+			if target == core.PdfObject(page) {
+				return page, nil
+			}
+			if ind, ok := target.(*core.PdfIndirectObject); ok {
+				if d, ok := ind.PdfObject.(*core.PdfObjectDictionary); ok {
+					if t, _ := d.Get("Type").(*core.PdfObjectName); t != nil && (*t == "Page" || *t == "Pages") {
+						return core.MakeNull(), nil
+					}
+				}
+			}
+			if !seen[target] {
+				seen[target] = true
+				objects = append(objects, target)
+				if _, err = resolve(target); err != nil {
+					return nil, err
+				}
+			}
+			return target, nil
+		case *core.PdfIndirectObject:
+			v, err := resolve(o.PdfObject)
+			if err != nil {
+				return nil, err
+			}
+			o.PdfObject = v
+		case *core.PdfObjectStream:
+			if _, err := resolve(o.PdfObjectDictionary); err != nil {
+				return nil, err
+			}
+		case *core.PdfObjectDictionary:
+			for _, key := range o.Keys() {
+				if key == "Parent" && o == page.PdfObject {
+					continue
+				}
+				v, err := resolve(o.Get(key))
+				if err != nil {
+					return nil, err
+				}
+				o.Set(key, v)
+			}
+		case *core.PdfObjectArray:
+			for i, item := range *o {
+				v, err := resolve(item)
+				if err != nil {
+					return nil, err
+				}
+				(*o)[i] = v
+			}
+		}
+		return obj, nil
+	}
+
+	if _, err := resolve(page); err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// writeSinglePage writes page n as a standalone PDF. Only the objects reachable from the page
+// are resolved and stream data is copied raw, without decoding or re-encoding. originalID, if set,
+// is the first element of its file identifier.
+func writeSinglePage(parser *core.PdfParser, pages *core.PdfIndirectObject, n int, fn string, originalID string) error {
+	page, err := findPage(parser, pages, n)
+	if err != nil {
+		return fmt.Errorf("unable to find page %d: %v", n, err)
+	}
+
+	objects, err := resolvePageObjects(parser, page)
+	if err != nil {
+		return fmt.Errorf("unable to resolve page %d objects: %v", n, err)
+	}
+
+	//new catalog and page tree holding only the one page
+	pagesDict := core.MakeDict()
+	pagesDict.Set("Type", core.MakeName("Pages"))
+	pagesDict.Set("Kids", core.MakeArray(page))
+	pagesDict.Set("Count", core.MakeInteger(1))
+	newPages := core.MakeIndirectObject(pagesDict)
+
+	catalogDict := core.MakeDict()
+	catalogDict.Set("Type", core.MakeName("Catalog"))
+	catalogDict.Set("Pages", newPages)
+	catalog := core.MakeIndirectObject(catalogDict)
+
+	page.PdfObject.(*core.PdfObjectDictionary).Set("Parent", newPages)
+
+	objects = append([]core.PdfObject{catalog, newPages}, objects...)
+
+	//renumber objects
+	for i, obj := range objects {
+		switch o := obj.(type) {
+		case *core.PdfIndirectObject:
+			o.ObjectNumber, o.GenerationNumber = int64(i+1), 0
+		case *core.PdfObjectStream:
+			o.ObjectNumber, o.GenerationNumber = int64(i+1), 0
+		}
+	}
+
+	//names may include directories
+	if err := os.MkdirAll(path.Dir(fn), 0755); err != nil {
+		return fmt.Errorf("unable to create output directory: %w", err)
+	}
+	f, err := os.Create(fn)
+	if err != nil {
+		return fmt.Errorf("unable to open new PDF file %s for writing: %w", fn, err)
+	}
+
+	w := &countingWriter{w: bufio.NewWriter(f), hash: md5.New()}
+	fmt.Fprintf(w, "%%PDF-1.7\n%%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int64, len(objects))
+	for i, obj := range objects {
+		offsets[i] = w.n
+		fmt.Fprintf(w, "%d 0 obj\n", i+1)
+		switch o := obj.(type) {
+		case *core.PdfIndirectObject:
+			fmt.Fprintf(w, "%s\nendobj\n", o.PdfObject.DefaultWriteString())
+		case *core.PdfObjectStream:
+			fmt.Fprintf(w, "%s\nstream\n", o.PdfObjectDictionary.DefaultWriteString())
+			w.Write(o.Stream)
+			fmt.Fprintf(w, "\nendstream\nendobj\n")
+		}
+	}
+
+	//the file identifier is derived from the content, like those of the PDF writer
+	id := string(w.hash.Sum(nil))
+	if originalID == "" {
+		originalID = id
+	}
+	trailer := core.MakeDict()
+	trailer.Set("Root", catalog)
+	trailer.Set("ID", core.MakeArray(core.MakeString(originalID), core.MakeString(id)))
+	err = core.WriteXref(w, offsets, trailer, w.n)
+	if err == nil {
+		err = w.w.Flush()
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write PDF file %s: %w", fn, err)
+	}
+
+	return f.Close()
+}
+
+// countingWriter tracks the number of bytes written, for xref offsets, and hashes them
+type countingWriter struct {
+	w    *bufio.Writer
+	n    int64
+	hash hash.Hash
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.hash.Write(p[:n])
+	return n, err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+// Test that the single page fast path writes one-page parts, leaves others to the normal path
+// and reports invalid parts as a *partError, so they exit as argument errors.
+func TestSinglePagePart(t *testing.T) {
+	data := testPDF(t, 4, nil)
+	dir := t.TempDir()
+
+	tests := []struct {
+		def     string
+		done    bool
+		invalid bool
+	}{
+		{"one.pdf=2", true, false},
+		{"two.pdf=2-3", false, false},
+		{"end.pdf=4-", true, false},
+		{"none.pdf=5", false, true},
+		{"none.pdf=0", false, true},
+		{"none.pdf=3-2", false, true},
+		{"none.pdf=x", false, true},
+		{"none.pdf", false, true},
+	}
+	for _, test := range tests {
+		done, err := writeSinglePagePart(bytes.NewReader(data), test.def, dir, nil, false)
+		var pe *partError
+		if errors.As(err, &pe) != test.invalid || !test.invalid && err != nil {
+			t.Errorf("%s: error %v", test.def, err)
+		}
+		if done != test.done {
+			t.Errorf("%s: done %v, expected %v", test.def, done, test.done)
+		}
+	}
+
+	for _, fn := range []string{"one.pdf", "end.pdf"} {
+		out, err := os.ReadFile(path.Join(dir, fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pdf, err := loadPDF(bytes.NewReader(out), nil, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", fn, err)
+		}
+		if len(pdf.PageList) != 1 {
+			t.Errorf("%s: %d pages, expected 1", fn, len(pdf.PageList))
+		}
+	}
+	if _, err := os.Stat(path.Join(dir, "two.pdf")); err == nil {
+		t.Error("two.pdf written by the fast path")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// fieldPart is an output of -field: a run of pages with the same field value
+type fieldPart struct {
+	value string
+	pages []int //1-based
+}
+
+// widgetField is the field of a widget annotation
+type widgetField struct {
+	name   string                    //fully qualified
+	field  *core.PdfObjectDictionary //the terminal field, which has the value; the widget itself if merged with it
+	kind   string                    //the field type, FT, inherited
+	flags  int64                     //the field flags, Ff, inherited
+	value  core.PdfObject            //inherited
+	layout string                    //the default appearance, DA, inherited
+}
+
+// fieldParts splits the pages of pdf where the value of the form field name changes, for mail
+// merge outputs merged into one document. Pages without the field, or with it empty, continue
+// the current part, and any before the first page with a value start the first part. It returns
+// no parts if no page has a value of the field.
+func fieldParts(pdf *model.PdfReader, name string) []fieldPart {
+	var parts []fieldPart
+	var leading []int
+	for i, p := range pdf.PageList {
+		value, ok := fieldValue(pdf, p, name)
+		switch {
+		case ok && (len(parts) == 0 || value != parts[len(parts)-1].value):
+			parts = append(parts, fieldPart{value: value, pages: append(leading, i+1)})
+			leading = nil
+		case len(parts) > 0:
+			parts[len(parts)-1].pages = append(parts[len(parts)-1].pages, i+1)
+		default:
+			leading = append(leading, i+1)
+		}
+	}
+	return parts
+}
+
+// fieldValue returns the value of the form field name on page p, and whether the page has the
+// field with a value. A field matches if its fully qualified name is name or starts with name
+// and ".", so the fields of a mail merge renamed per recipient, e.g. "recipient.0" and
+// "recipient.1", match "recipient".
+func fieldValue(pdf *model.PdfReader, p *model.PdfPage, name string) (string, bool) {
+	for _, annot := range p.Annotations {
+		ind, ok := annot.GetContainingPdfObject().(*core.PdfIndirectObject)
+		if !ok {
+			continue
+		}
+		widget, ok := ind.PdfObject.(*core.PdfObjectDictionary)
+		if !ok {
+			continue
+		}
+		if subtype, _ := resolve(pdf, widget.Get("Subtype")).(*core.PdfObjectName); subtype == nil || *subtype != "Widget" {
+			continue
+		}
+
+		f := fieldOf(pdf, widget)
+		if value := fieldString(pdf, f.value); value != "" && (f.name == name || strings.HasPrefix(f.name, name+".")) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// fieldOf returns the field of a widget annotation: its fully qualified name, joining the partial
+// names of the field and its ancestors with ".", the terminal field, and the entries it inherits
+func fieldOf(pdf *model.PdfReader, widget *core.PdfObjectDictionary) widgetField {
+	var f widgetField
+	var names []string
+	seen := map[*core.PdfObjectDictionary]bool{}
+	for node := widget; node != nil && !seen[node] && len(seen) < core.TraceMaxDepth; {
+		seen[node] = true
+		if t, ok := resolve(pdf, node.Get("T")).(*core.PdfObjectString); ok {
+			names = append([]string{decodeTextString(string(*t))}, names...)
+			if f.field == nil {
+				f.field = node
+			}
+		}
+		if ft, ok := resolve(pdf, node.Get("FT")).(*core.PdfObjectName); ok && f.kind == "" {
+			f.kind = string(*ft)
+		}
+		if ff, ok := resolve(pdf, node.Get("Ff")).(*core.PdfObjectInteger); ok && f.flags == 0 {
+			f.flags = int64(*ff)
+		}
+		if da, ok := resolve(pdf, node.Get("DA")).(*core.PdfObjectString); ok && f.layout == "" {
+			f.layout = string(*da)
+		}
+		if f.value == nil {
+			f.value = resolve(pdf, node.Get("V"))
+		}
+		node, _ = resolve(pdf, node.Get("Parent")).(*core.PdfObjectDictionary)
+	}
+	if f.field == nil {
+		f.field = widget
+	}
+	f.name = strings.Join(names, ".")
+	return f
+}
+
+// fieldString returns a field value as text: text fields hold strings, check boxes and radio
+// buttons names, and list boxes arrays of the options selected
+func fieldString(pdf *model.PdfReader, v core.PdfObject) string {
+	switch o := v.(type) {
+	case *core.PdfObjectString:
+		return decodeTextString(string(*o))
+	case *core.PdfObjectName:
+		return string(*o)
+	case *core.PdfObjectArray:
+		values := make([]string, 0, len(*o))
+		for _, item := range *o {
+			values = append(values, fieldString(pdf, resolve(pdf, item)))
+		}
+		return strings.Join(values, ", ")
+	}
+	return ""
+}
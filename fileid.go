@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/md5"
+	"errors"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// firstID returns the first, permanent element of the file identifier in trailer, or "" if it has
+// none
+func firstID(trailer *core.PdfObjectDictionary) string {
+	ids, ok := core.TraceToDirectObject(trailer.Get("ID")).(*core.PdfObjectArray)
+	if !ok || len(*ids) != 2 {
+		return ""
+	}
+	id, ok := core.TraceToDirectObject((*ids)[0]).(*core.PdfObjectString)
+	if !ok {
+		return ""
+	}
+	return string(*id)
+}
+
+// keepIdentity gives w, rewriting the PDF data read into pdf, the XMP metadata and document
+// information of data, its first file identifier and a second one derived from data, as the file
+// is changed. Without this the rewrite would get its own, dropping those set for the output.
+func keepIdentity(w *model.PdfWriter, pdf *model.PdfReader, data []byte) {
+	hash := md5.Sum(data)
+	id0 := string(hash[:])
+	trailer, err := pdf.GetTrailer()
+	if err == nil {
+		if id := firstID(trailer); id != "" {
+			id0 = id
+		}
+		if catalog, ok := resolve(pdf, trailer.Get("Root")).(*core.PdfObjectDictionary); ok {
+			if xmp, ok := resolve(pdf, catalog.Get("Metadata")).(*core.PdfObjectStream); ok {
+				w.SetCatalogEntry("Metadata", xmp)
+			}
+		}
+		if info, ok := resolve(pdf, trailer.Get("Info")).(*core.PdfObjectDictionary); ok {
+			for _, key := range info.Keys() {
+				if s, ok := resolve(pdf, info.Get(key)).(*core.PdfObjectString); ok {
+					w.GetInfo().Set(key, core.MakeString(string(*s)))
+				}
+			}
+		}
+	}
+	w.SetID(id0, string(hash[:]))
+}
+
+// rebuiltEntries are the catalog entries a rewrite builds itself rather than copying
+var rebuiltEntries = map[core.PdfObjectName]bool{"Type": true, "Version": true, "Pages": true, "Outlines": true, "Metadata": true}
+
+// keepCatalog gives w, rewriting the PDF read into pdf, the other catalog entries of pdf, such as
+// its initial view, attachments and form. Without this the rewrite would only have the pages and
+// outline. It is called after the outline is added, so the page mode of pdf replaces the one the
+// outline opens with.
+func keepCatalog(w *model.PdfWriter, pdf *model.PdfReader) error {
+	trailer, err := pdf.GetTrailer()
+	if err != nil {
+		return err
+	}
+	catalog, ok := resolve(pdf, trailer.Get("Root")).(*core.PdfObjectDictionary)
+	if !ok {
+		return errors.New("invalid catalog")
+	}
+
+	//page destinations resolve to the pages of pdf, which w writes again
+	seen := map[core.PdfObject]bool{}
+	for _, key := range catalog.Keys() {
+		if rebuiltEntries[key] {
+			continue
+		}
+		if err = w.SetCatalogEntry(key, resolveReferences(pdf, catalog.Get(key), seen)); err != nil {
+			return err
+		}
+	}
+
+	//adding the pages pointed their widgets at the fields of the reader's model of the form, which
+	//w doesn't write, rather than at those of the form copied
+	if form, ok := resolve(pdf, catalog.Get("AcroForm")).(*core.PdfObjectDictionary); ok {
+		setParents(form.Get("Fields"), nil, map[core.PdfObject]bool{})
+	}
+	return nil
+}
+
+// resolveReferences returns obj with the references in it, and in the objects it holds, replaced
+// by the objects of pdf they point to, as the PDF writer only writes objects, not references.
+// Dictionaries and arrays are changed in place.
+func resolveReferences(pdf *model.PdfReader, obj core.PdfObject, seen map[core.PdfObject]bool) core.PdfObject {
+	if ref, ok := obj.(*core.PdfObjectReference); ok {
+		target, err := pdf.GetIndirectObjectByNumber(int(ref.ObjectNumber))
+		if err != nil {
+			return core.MakeNull()
+		}
+		obj = target
+	}
+	if seen[obj] {
+		return obj
+	}
+	seen[obj] = true
+
+	switch o := obj.(type) {
+	case *core.PdfIndirectObject:
+		o.PdfObject = resolveReferences(pdf, o.PdfObject, seen)
+	case *core.PdfObjectStream:
+		resolveReferences(pdf, o.PdfObjectDictionary, seen)
+	case *core.PdfObjectDictionary:
+		for _, key := range o.Keys() {
+			o.Set(key, resolveReferences(pdf, o.Get(key), seen))
+		}
+	case *core.PdfObjectArray:
+		for i, item := range *o {
+			(*o)[i] = resolveReferences(pdf, item, seen)
+		}
+	}
+	return obj
+}
+
+// setParents sets the parent of the fields and widgets in kids, and below them in the field tree,
+// to parent, or removes it if parent is nil, for top level fields
+func setParents(kids core.PdfObject, parent *core.PdfIndirectObject, seen map[core.PdfObject]bool) {
+	arr, ok := core.TraceToDirectObject(kids).(*core.PdfObjectArray)
+	if !ok {
+		return
+	}
+	for _, kid := range *arr {
+		ind, ok := kid.(*core.PdfIndirectObject)
+		if !ok || seen[ind] {
+			continue
+		}
+		seen[ind] = true
+		dict, ok := ind.PdfObject.(*core.PdfObjectDictionary)
+		if !ok {
+			continue
+		}
+		if parent != nil {
+			dict.Set("Parent", parent)
+		} else {
+			dict.Remove("Parent")
+		}
+		setParents(dict.Get("Kids"), ind, seen)
+	}
+}
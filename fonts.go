@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// pageFont is a font used by a page, or by a form drawn on it
+type pageFont struct {
+	name    string //BaseFont, or the resource name if it has none
+	subtype string //e.g. "TrueType" or "Type0"
+	dict    *core.PdfObjectDictionary
+}
+
+// subsetPrefix matches the tag of a subset font name, e.g. "ABCDEF+" in "ABCDEF+Arial"
+var subsetPrefix = regexp.MustCompile(`^[A-Z]{6}\+`)
+
+// status describes whether the font is embedded and subset, with the descriptor key of its program
+func (pf pageFont) status() string {
+	if pf.subtype == "Type3" {
+		return "embedded (Type 3)"
+	}
+	key, stream := fontFile(pf.dict)
+	if stream == nil {
+		return "not embedded"
+	}
+	if subsetPrefix.MatchString(pf.name) {
+		return fmt.Sprintf("embedded subset (%s)", key)
+	}
+	return fmt.Sprintf("embedded (%s)", key)
+}
+
+// runFonts lists the fonts used by each page of a PDF, with whether they are embedded and subset,
+// and optionally writes the embedded font programs to a directory
+func runFonts(args []string) {
+	fs := flag.NewFlagSet("fonts", flag.ExitOnError)
+	output := outputFlags(fs)
+	extract := fs.String("extract", "", "directory to write the embedded font programs to, named after the font")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter fonts: [-extract directory] input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("fonts", output)
+	defer finishOutput()
+
+	if fs.NArg() != 1 {
+		usage(fs)
+	}
+
+	in := fs.Arg(0)
+	f, err := openInput(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	pdf, err := loadPDF(f, nil, 0)
+	if err != nil {
+		fatalInput(in, err)
+	}
+
+	if *extract != "" {
+		if err = os.MkdirAll(*extract, 0755); err != nil {
+			exitError(exitWrite, "Unable to create output directory:", err)
+		}
+	}
+
+	written := map[*core.PdfObjectStream]bool{}
+	used := map[string]int{}
+	for i, p := range pdf.PageList {
+		res, err := pageResources(p)
+		if err != nil {
+			fatalf("Unable to read page %d resources: %v\n", i+1, err)
+		}
+		fonts := fontsUsed(res)
+		page := fontsResult{Page: i + 1, Fonts: []fontResult{}}
+		for _, pf := range fonts {
+			page.Fonts = append(page.Fonts, fontResult{Name: pf.name, Subtype: pf.subtype, Status: pf.status()})
+		}
+		page.report()
+
+		for _, pf := range fonts {
+			key, stream := fontFile(pf.dict)
+			if *extract == "" || stream == nil || written[stream] {
+				continue
+			}
+			written[stream] = true
+			name := nameOptions{policy: sanitizePOSIX, replace: "_"}.fileName(pf.name)
+			fn := path.Join(*extract, uniqueName(used, name+fontExt(key, stream)))
+			logInfo("Writing", fn)
+			if err = writeFontFile(fn, stream); err != nil {
+				exitError(writeStatus(err), err)
+			}
+			addResult(fileResult{File: fn})
+		}
+	}
+
+	if *extract != "" {
+		logInfo("Wrote", len(written), "fonts.")
+	}
+}
+
+// fontsResult is the fonts used by a page
+type fontsResult struct {
+	Page  int          `json:"page"`
+	Fonts []fontResult `json:"fonts"`
+}
+
+// fontResult is a font in a fontsResult
+type fontResult struct {
+	Name    string `json:"name"`
+	Subtype string `json:"subtype"`
+	Status  string `json:"status"`
+}
+
+// report prints the fonts of the page, or adds them to the JSON report
+func (res fontsResult) report() {
+	if jsonReport != nil {
+		addResult(res)
+		return
+	}
+
+	if len(res.Fonts) == 0 {
+		fmt.Printf("Page %d: no fonts\n", res.Page)
+		return
+	}
+	fmt.Printf("Page %d:\n", res.Page)
+	for _, f := range res.Fonts {
+		fmt.Printf("  %s (%s): %s\n", f.Name, f.Subtype, f.Status)
+	}
+}
+
+// fontsUsed returns the fonts of the page resources res and of the forms they draw, each once
+func fontsUsed(res *model.PdfPageResources) []pageFont {
+	var fonts []pageFont
+	seen := map[*core.PdfObjectDictionary]bool{}
+	visited := map[*core.PdfObjectStream]bool{}
+
+	var collect func(res *core.PdfObjectDictionary)
+	collect = func(res *core.PdfObjectDictionary) {
+		if res == nil {
+			return
+		}
+		if fontDict, ok := core.TraceToDirectObject(res.Get("Font")).(*core.PdfObjectDictionary); ok {
+			for _, name := range fontDict.Keys() {
+				font, ok := core.TraceToDirectObject(fontDict.Get(name)).(*core.PdfObjectDictionary)
+				if !ok || seen[font] {
+					continue
+				}
+				seen[font] = true
+				pf := pageFont{name: string(name), subtype: "unknown", dict: font}
+				if bf, ok := core.TraceToDirectObject(font.Get("BaseFont")).(*core.PdfObjectName); ok {
+					pf.name = string(*bf)
+				}
+				if subtype, ok := core.TraceToDirectObject(font.Get("Subtype")).(*core.PdfObjectName); ok {
+					pf.subtype = string(*subtype)
+				}
+				fonts = append(fonts, pf)
+			}
+		}
+		if xobjs, ok := core.TraceToDirectObject(res.Get("XObject")).(*core.PdfObjectDictionary); ok {
+			for _, name := range xobjs.Keys() {
+				form, ok := core.TraceToDirectObject(xobjs.Get(name)).(*core.PdfObjectStream)
+				if !ok || visited[form] || !isForm(form) {
+					continue
+				}
+				visited[form] = true
+				formRes, _ := core.TraceToDirectObject(form.Get("Resources")).(*core.PdfObjectDictionary)
+				collect(formRes)
+			}
+		}
+	}
+
+	if res != nil {
+		collect(resourcesDict(res))
+	}
+	return fonts
+}
+
+// fontExt returns the file extension for a font program by its descriptor key and, for
+// FontFile3, its Subtype
+func fontExt(key core.PdfObjectName, stream *core.PdfObjectStream) string {
+	switch key {
+	case "FontFile":
+		return ".pfa"
+	case "FontFile2":
+		return ".ttf"
+	}
+	if subtype, ok := core.TraceToDirectObject(stream.Get("Subtype")).(*core.PdfObjectName); ok && *subtype == "OpenType" {
+		return ".otf"
+	}
+	return ".cff"
+}
+
+// writeFontFile writes the decoded font program stream to the file fn
+func writeFontFile(fn string, stream *core.PdfObjectStream) error {
+	data, err := core.DecodeStream(stream)
+	if err != nil {
+		return fmt.Errorf("unable to decode font %s: %v", fn, err)
+	}
+	if err = os.WriteFile(fn, data, 0644); err != nil {
+		return fmt.Errorf("unable to write font file %s: %w", fn, err)
+	}
+	return nil
+}
@@ -0,0 +1,561 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+	"github.com/unidoc/unidoc/pdf/model/fonts"
+)
+
+// form data formats for -format
+const (
+	formsFDF  = "fdf"
+	formsJSON = "json"
+)
+
+// field flags (Ff) of button fields
+const (
+	fieldRadio      = 1 << 15
+	fieldPushbutton = 1 << 16
+)
+
+// annotationHidden is the annotation flag of widgets that aren't shown or printed
+const annotationHidden = 1 << 1
+
+// formField is a form field as exported, with its fully qualified name
+type formField struct {
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"` //"text", "checkbox", "radio", "choice" or "signature"
+	Value string `json:"value"`          //the option name of check boxes and radio buttons, "Off" if unset
+	Page  int    `json:"page,omitempty"` //1-based page of its first widget
+}
+
+// runForms runs a forms subcommand
+func runForms(args []string) {
+	if len(args) > 0 && args[0] == "export" {
+		runFormsExport(args[1:])
+	} else if len(args) > 0 && args[0] == "fill" {
+		runFormsFill(args[1:])
+	} else {
+		fmt.Fprintln(os.Stderr, "Usage of pdf-splitter forms: export|fill [flags] input.pdf")
+		exit(exitUsage)
+	}
+}
+
+// runFormsExport writes the values of the form fields of a PDF to an FDF or JSON file
+func runFormsExport(args []string) {
+	fs := flag.NewFlagSet("forms export", flag.ExitOnError)
+	output := outputFlags(fs)
+	out := fs.String("out", "", "FDF or JSON file to write the field values to")
+	format := fs.String("format", "", "export `format`: \"fdf\" or \"json\" (default json for a .json -out, otherwise fdf)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter forms export: -out values.fdf [-format fdf|json] input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("forms export", output)
+	defer finishOutput()
+
+	if *out == "" || fs.NArg() != 1 {
+		usage(fs)
+	}
+	if *format == "" {
+		*format = formsFDF
+		if strings.EqualFold(filepath.Ext(*out), ".json") {
+			*format = formsJSON
+		}
+	}
+	if *format != formsFDF && *format != formsJSON {
+		argError("-format must be fdf or json")
+	}
+
+	in := fs.Arg(0)
+	pdf, f, err := openPDF(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	fields := exportFields(pdf)
+
+	var data []byte
+	if *format == formsJSON {
+		data, err = json.MarshalIndent(struct {
+			Source string      `json:"source"`
+			Fields []formField `json:"fields"`
+		}{path.Base(in), fields}, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data = marshalFDF(path.Base(in), fields)
+	}
+	if err != nil {
+		fatal("Unable to export fields:", err)
+	}
+
+	logInfo("Writing", *out)
+	if err = os.WriteFile(*out, data, 0644); err != nil {
+		exitError(exitWrite, "Unable to write", *out+":", err)
+	}
+	addResult(fileResult{File: *out})
+	logInfo("Exported", len(fields), "fields.")
+}
+
+// runFormsFill sets the form fields of a PDF to the values of an FDF or JSON file, optionally
+// flattening the form into the page content
+func runFormsFill(args []string) {
+	fs := flag.NewFlagSet("forms fill", flag.ExitOnError)
+	output := outputFlags(fs)
+	out := fs.String("out", "", "PDF file to write with the fields filled")
+	dataFile := fs.String("data", "", "FDF or JSON `file` of the field values, e.g. from forms export")
+	flatten := fs.Bool("flatten", false, "draw the fields into the page content and remove them, so the values can't be changed")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter forms fill: -data values.fdf -out filled.pdf [-flatten] input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("forms fill", output)
+	defer finishOutput()
+
+	if *out == "" || *dataFile == "" || fs.NArg() != 1 {
+		usage(fs)
+	}
+	values, err := readFormData(*dataFile)
+	if err != nil {
+		argError("Invalid -data:", err)
+	}
+
+	in := fs.Arg(0)
+	pdf, f, err := openPDF(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	filled := fillFields(pdf, values)
+	var missing []string
+	for name := range values {
+		if !filled[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		warning("No fields named %s, skipping their values", strings.Join(missing, ", "))
+	}
+
+	pages := pdf.PageList
+	if *flatten {
+		if pages, err = flattenFields(pdf); err != nil {
+			fatal("Unable to flatten form:", err)
+		}
+	}
+
+	logInfo("Writing", *out)
+	if _, err := writePDF(*out, pdfPart{pages: pages}); err != nil {
+		exitError(writeStatus(err), err)
+	}
+	addResult(fileResult{File: *out, Pages: len(pages)})
+	logInfo("Filled", len(filled), "fields.")
+}
+
+// typeName returns the type of the field as exported
+func (f widgetField) typeName() string {
+	switch {
+	case f.kind == "Tx":
+		return "text"
+	case f.kind == "Btn" && f.flags&fieldPushbutton != 0:
+		return "button"
+	case f.kind == "Btn" && f.flags&fieldRadio != 0:
+		return "radio"
+	case f.kind == "Btn":
+		return "checkbox"
+	case f.kind == "Ch":
+		return "choice"
+	case f.kind == "Sig":
+		return "signature"
+	}
+	return ""
+}
+
+// widgets calls fn with the widget annotations of the pages of pdf and their fields, with the
+// 0-based index of their page and annotation
+func widgets(pdf *model.PdfReader, fn func(page, i int, widget *core.PdfObjectDictionary, f widgetField)) {
+	for page, p := range pdf.PageList {
+		for i, annot := range p.Annotations {
+			ind, ok := annot.GetContainingPdfObject().(*core.PdfIndirectObject)
+			if !ok {
+				continue
+			}
+			widget, ok := ind.PdfObject.(*core.PdfObjectDictionary)
+			if !ok {
+				continue
+			}
+			if subtype, _ := resolve(pdf, widget.Get("Subtype")).(*core.PdfObjectName); subtype == nil || *subtype != "Widget" {
+				continue
+			}
+			fn(page, i, widget, fieldOf(pdf, widget))
+		}
+	}
+}
+
+// exportFields returns the fields of the widgets on the pages of pdf, in the order of their first
+// widgets. Push buttons, which have no value, are left out.
+func exportFields(pdf *model.PdfReader) []formField {
+	fields := []formField{}
+	seen := map[string]bool{}
+	widgets(pdf, func(page, _ int, _ *core.PdfObjectDictionary, f widgetField) {
+		kind := f.typeName()
+		if f.name == "" || seen[f.name] || kind == "button" {
+			return
+		}
+		seen[f.name] = true
+		value := fieldString(pdf, f.value)
+		if value == "" && (kind == "checkbox" || kind == "radio") {
+			value = "Off"
+		}
+		fields = append(fields, formField{Name: f.name, Type: kind, Value: value, Page: page + 1})
+	})
+	return fields
+}
+
+// fillFields sets the fields of the widgets of pdf to values, by fully qualified name, and
+// generates the appearances of their widgets. It returns the names of the fields filled.
+func fillFields(pdf *model.PdfReader, values map[string]string) map[string]bool {
+	filled := map[string]bool{}
+	widgets(pdf, func(page, _ int, widget *core.PdfObjectDictionary, f widgetField) {
+		value, ok := values[f.name]
+		if !ok {
+			return
+		}
+		switch f.typeName() {
+		case "checkbox", "radio":
+			//the value is the state of the widget that is on, each widget having its own
+			state := core.PdfObjectName("Off")
+			if normal, ok := resolve(pdf, appearances(pdf, widget)).(*core.PdfObjectDictionary); ok && value != "Off" {
+				if normal.Get(core.PdfObjectName(value)) != nil {
+					state = core.PdfObjectName(value)
+				}
+			}
+			widget.Set("AS", core.MakeName(string(state)))
+			f.field.Set("V", core.MakeName(value))
+		case "text", "choice", "":
+			f.field.Set("V", core.MakeString(encodeTextString(value)))
+			if err := setTextAppearance(pdf, widget, f, value); err != nil {
+				warning("Page %d: unable to draw field %s: %v", page+1, f.name, err)
+			}
+		case "signature", "button":
+			warning("Page %d: field %s is a %s, skipping its value", page+1, f.name, f.typeName())
+			return
+		}
+		filled[f.name] = true
+	})
+	return filled
+}
+
+// appearances returns the normal appearance of the widget, a stream, or a dictionary of the
+// streams of its states
+func appearances(pdf *model.PdfReader, widget *core.PdfObjectDictionary) core.PdfObject {
+	if ap, ok := resolve(pdf, widget.Get("AP")).(*core.PdfObjectDictionary); ok {
+		return ap.Get("N")
+	}
+	return nil
+}
+
+// fontSizeRe matches the font and size of a default appearance, e.g. "/Helv 12 Tf"
+var fontSizeRe = regexp.MustCompile(`/[^\s/]+\s+([0-9.]+)\s+Tf`)
+
+// colorRe matches the text color of a default appearance, e.g. "0 0 1 rg"
+var colorRe = regexp.MustCompile(`(?:[0-9.]+\s+){1,4}(?:g|rg|k)\b`)
+
+// setTextAppearance sets the appearance of the widget of a text or choice field to value, in
+// Helvetica at the size and color of its default appearance, automatic sizes fitting the height
+func setTextAppearance(pdf *model.PdfReader, widget *core.PdfObjectDictionary, f widgetField, value string) error {
+	rect := numberArray(pdf, widget.Get("Rect"))
+	if len(rect) != 4 {
+		return errors.New("invalid widget Rect")
+	}
+	width, height := math.Abs(rect[2]-rect[0]), math.Abs(rect[3]-rect[1])
+
+	size := 0.0
+	if m := fontSizeRe.FindStringSubmatch(f.layout); m != nil {
+		size, _ = strconv.ParseFloat(m[1], 64)
+	}
+	if size <= 0 {
+		size = math.Min(12, height*0.7)
+	}
+	color := "0 g"
+	if m := colorRe.FindString(f.layout); m != "" {
+		color = m
+	}
+
+	font := fonts.NewFontHelvetica()
+	lines := []string{value}
+	if f.flags&(1<<12) != 0 { //multiline
+		lines = strings.Split(value, "\n")
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "/Tx BMC\nq\n1 1 %.2f %.2f re W n\nBT\n/Helv %.2f Tf\n%s\n", width-2, height-2, size, color)
+	y := (height-size)/2 + size*0.22
+	if len(lines) > 1 {
+		y = height - 2 - size
+	}
+	for _, line := range lines {
+		x := 2.0
+		if q, ok := resolve(pdf, f.field.Get("Q")).(*core.PdfObjectInteger); ok && *q > 0 {
+			if x = width - 2 - textWidth(font, line, size); *q == 1 {
+				x = (x + 2) / 2
+			}
+		}
+		fmt.Fprintf(&content, "1 0 0 1 %.2f %.2f Tm\n%s Tj\n", x, y, winAnsiString(line))
+		y -= size * 1.15
+	}
+	content.WriteString("ET\nQ\nEMC\n")
+
+	stream := makeContentStream(content.String())
+	stream.Set("Type", core.MakeName("XObject"))
+	stream.Set("Subtype", core.MakeName("Form"))
+	stream.Set("BBox", core.MakeArrayFromFloats([]float64{0, 0, width, height}))
+	stream.Set("Resources", core.MakeDict())
+	res := stream.Get("Resources").(*core.PdfObjectDictionary)
+	fontDict := core.MakeDict()
+	fontDict.Set("Helv", font.ToPdfObject())
+	res.Set("Font", fontDict)
+
+	ap := core.MakeDict()
+	ap.Set("N", stream)
+	widget.Set("AP", ap)
+	return nil
+}
+
+// flattenFields returns the pages of pdf with the appearances of their widgets drawn into the
+// content and the widgets removed. Hidden widgets and those without an appearance are removed
+// without being drawn.
+func flattenFields(pdf *model.PdfReader) ([]*model.PdfPage, error) {
+	draws := map[int][]string{}
+	forms := map[int][]*core.PdfObjectStream{}
+	remove := map[int]map[int]bool{}
+	widgets(pdf, func(page, i int, widget *core.PdfObjectDictionary, _ widgetField) {
+		if remove[page] == nil {
+			remove[page] = map[int]bool{}
+		}
+		remove[page][i] = true
+
+		if flags, ok := resolve(pdf, widget.Get("F")).(*core.PdfObjectInteger); ok && *flags&annotationHidden != 0 {
+			return
+		}
+		normal := resolve(pdf, appearances(pdf, widget))
+		if states, ok := normal.(*core.PdfObjectDictionary); ok {
+			state, _ := resolve(pdf, widget.Get("AS")).(*core.PdfObjectName)
+			if state == nil {
+				return
+			}
+			normal = resolve(pdf, states.Get(*state))
+		}
+		stream, ok := normal.(*core.PdfObjectStream)
+		rect := numberArray(pdf, widget.Get("Rect"))
+		if !ok || len(rect) != 4 {
+			return
+		}
+		cm, ok := appearanceMatrix(pdf, stream, rect)
+		if !ok {
+			return
+		}
+		name := fmt.Sprintf("FlatField%d", len(forms[page])+1)
+		forms[page] = append(forms[page], stream)
+		draws[page] = append(draws[page], fmt.Sprintf("q %.4f %.4f %.4f %.4f %.4f %.4f cm /%s Do Q\n", cm[0], cm[1], cm[2], cm[3], cm[4], cm[5], name))
+	})
+
+	pages := make([]*model.PdfPage, len(pdf.PageList))
+	for i, p := range pdf.PageList {
+		if remove[i] == nil {
+			pages[i] = p
+			continue
+		}
+		dup, err := copyPage(p)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %v", i+1, err)
+		}
+		dup.Annotations = nil
+		for j, annot := range p.Annotations {
+			if !remove[i][j] {
+				dup.Annotations = append(dup.Annotations, annot)
+			}
+		}
+		for j, stream := range forms[i] {
+			if err = dup.Resources.SetXObjectByName(core.PdfObjectName(fmt.Sprintf("FlatField%d", j+1)), stream); err != nil {
+				return nil, fmt.Errorf("page %d: %v", i+1, err)
+			}
+		}
+		if len(draws[i]) > 0 {
+			stampContents(dup, "", strings.Join(draws[i], ""))
+		}
+		pages[i] = dup
+	}
+	return pages, nil
+}
+
+// appearanceMatrix returns the transformation drawing the appearance stream of a widget in its
+// rect: the bounding box of the form, transformed by its matrix, is scaled and moved onto rect
+func appearanceMatrix(pdf *model.PdfReader, stream *core.PdfObjectStream, rect []float64) (matrix, bool) {
+	bbox := numberArray(pdf, stream.Get("BBox"))
+	if len(bbox) != 4 {
+		return matrix{}, false
+	}
+	m := identity
+	if arr, ok := resolve(pdf, stream.Get("Matrix")).(*core.PdfObjectArray); ok {
+		if m, ok = toMatrix(*arr); !ok {
+			return matrix{}, false
+		}
+	}
+
+	//the box of the transformed corners of the bounding box
+	llx, lly, urx, ury := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range [][2]float64{{bbox[0], bbox[1]}, {bbox[0], bbox[3]}, {bbox[2], bbox[1]}, {bbox[2], bbox[3]}} {
+		x, y := c[0]*m[0]+c[1]*m[2]+m[4], c[0]*m[1]+c[1]*m[3]+m[5]
+		llx, lly, urx, ury = math.Min(llx, x), math.Min(lly, y), math.Max(urx, x), math.Max(ury, y)
+	}
+	if urx-llx == 0 || ury-lly == 0 {
+		return matrix{}, false
+	}
+
+	rx, ry := math.Min(rect[0], rect[2]), math.Min(rect[1], rect[3])
+	sx, sy := math.Abs(rect[2]-rect[0])/(urx-llx), math.Abs(rect[3]-rect[1])/(ury-lly)
+	return matrix{sx, 0, 0, sy, rx - llx*sx, ry - lly*sy}, true
+}
+
+// marshalFDF returns the FDF document of the fields of the PDF file source. Fields are nested by
+// the parts of their names, as viewers import them.
+func marshalFDF(source string, fields []formField) []byte {
+	type node struct {
+		dict *core.PdfObjectDictionary
+		kids map[string]*node
+		list *core.PdfObjectArray
+	}
+	root := &node{kids: map[string]*node{}, list: core.MakeArray()}
+	for _, f := range fields {
+		n := root
+		parts := strings.Split(f.Name, ".")
+		for _, part := range parts {
+			kid, ok := n.kids[part]
+			if !ok {
+				kid = &node{dict: core.MakeDict(), kids: map[string]*node{}}
+				kid.dict.Set("T", core.MakeString(encodeTextString(part)))
+				if n.list == nil {
+					n.list = core.MakeArray()
+					n.dict.Set("Kids", n.list)
+				}
+				n.list.Append(kid.dict)
+				n.kids[part] = kid
+			}
+			n = kid
+		}
+		if f.Type == "checkbox" || f.Type == "radio" {
+			n.dict.Set("V", core.MakeName(f.Value))
+		} else {
+			n.dict.Set("V", core.MakeString(encodeTextString(f.Value)))
+		}
+	}
+
+	fdf := core.MakeDict()
+	fdf.Set("F", core.MakeString(source))
+	fdf.Set("Fields", root.list)
+	catalog := core.MakeDict()
+	catalog.Set("FDF", fdf)
+
+	var b strings.Builder
+	b.WriteString("%FDF-1.2\n%\xe2\xe3\xcf\xd3\n")
+	fmt.Fprintf(&b, "1 0 obj\n%s\nendobj\n", catalog.DefaultWriteString())
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return []byte(b.String())
+}
+
+// fdfObjectRe matches the start of the first object of an FDF file, its catalog
+var fdfObjectRe = regexp.MustCompile(`\d+\s+\d+\s+obj\b`)
+
+// readFormData reads the field values of an FDF or JSON file, by fully qualified name. JSON
+// files are as forms export writes them, or an object of names and values.
+func readFormData(fn string) (map[string]string, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+
+	if !strings.HasPrefix(string(data), "%FDF") {
+		var doc struct {
+			Fields json.RawMessage `json:"fields"`
+		}
+		if err = json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("neither FDF nor JSON: %v", err)
+		}
+		var fields []formField
+		if err = json.Unmarshal(doc.Fields, &fields); err == nil {
+			for _, f := range fields {
+				values[f.Name] = f.Value
+			}
+			return values, nil
+		}
+		if err = json.Unmarshal(doc.Fields, &values); err != nil {
+			return nil, errors.New("fields must be an array of names and values, or an object of values by name")
+		}
+		return values, nil
+	}
+
+	loc := fdfObjectRe.FindIndex(data)
+	if loc == nil {
+		return nil, errors.New("no FDF catalog object")
+	}
+	obj, err := core.NewParserFromString(string(data[loc[0]:])).ParseIndirectObject()
+	if err != nil {
+		return nil, fmt.Errorf("invalid FDF catalog: %v", err)
+	}
+	var fields *core.PdfObjectArray
+	if ind, ok := obj.(*core.PdfIndirectObject); ok {
+		if catalog, ok := ind.PdfObject.(*core.PdfObjectDictionary); ok {
+			if fdf, ok := catalog.Get("FDF").(*core.PdfObjectDictionary); ok {
+				fields, _ = fdf.Get("Fields").(*core.PdfObjectArray)
+			}
+		}
+	}
+	if fields == nil {
+		return nil, errors.New("no FDF Fields")
+	}
+
+	var walk func(prefix string, fields *core.PdfObjectArray, depth int)
+	walk = func(prefix string, fields *core.PdfObjectArray, depth int) {
+		for _, obj := range *fields {
+			field, ok := obj.(*core.PdfObjectDictionary)
+			if !ok || depth > core.TraceMaxDepth {
+				continue
+			}
+			name := prefix
+			if t, ok := field.Get("T").(*core.PdfObjectString); ok {
+				if name != "" {
+					name += "."
+				}
+				name += decodeTextString(string(*t))
+			}
+			if v := fieldString(nil, field.Get("V")); field.Get("V") != nil {
+				values[name] = v
+			}
+			if kids, ok := field.Get("Kids").(*core.PdfObjectArray); ok {
+				walk(name, kids, depth+1)
+			}
+		}
+	}
+	walk("", fields, 0)
+	return values, nil
+}
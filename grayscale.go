@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/unidoc/unidoc/pdf/contentstream"
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// grayscale converts output pages to DeviceGray: the fill and stroke colors of their content and
+// of the forms they draw, and their images, inline or XObjects
+type grayscale struct {
+	converted map[*core.PdfObjectStream]*core.PdfObjectStream //converted XObjects, shared by outputs
+}
+
+func newGrayscale() *grayscale {
+	return &grayscale{converted: map[*core.PdfObjectStream]*core.PdfObjectStream{}}
+}
+
+// apply is a pageTransform converting pages to grayscale
+func (g *grayscale) apply(pages []*model.PdfPage) ([]*model.PdfPage, error) {
+	gray := make([]*model.PdfPage, len(pages))
+
+	for i, p := range pages {
+		content, err := p.GetAllContentStreams()
+		if err != nil {
+			return nil, err
+		}
+
+		dup, err := copyPage(p)
+		if err != nil {
+			return nil, err
+		}
+		content, changed, err := g.convertContent(content, dup.Resources)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert page to grayscale: %v", err)
+		}
+		//unchanged content keeps its streams as encoded in the input, rather than compressed again
+		if changed {
+			if err = dup.SetContentStreams([]string{content}, core.NewFlateEncoder()); err != nil {
+				return nil, err
+			}
+		}
+
+		gray[i] = dup
+	}
+
+	return gray, nil
+}
+
+// convertContent converts the colors and inline images of content, with resources res, to
+// DeviceGray, and replaces the XObjects of res, which should be a copy from copyResources, with
+// grayscale ones. Pattern colors and shadings are left as they are. It also returns whether the
+// content changed, returning content itself if it didn't.
+func (g *grayscale) convertContent(content string, res *model.PdfPageResources) (string, bool, error) {
+	if err := g.convertXObjects(res); err != nil {
+		return "", false, err
+	}
+
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil {
+		return "", false, err
+	}
+
+	var gray contentstream.ContentStreamOperations
+	changed := false
+	proc := contentstream.NewContentStreamProcessor(*ops)
+	proc.AddHandler(contentstream.HandlerConditionEnumAllOperands, "",
+		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState, res *model.PdfPageResources) error {
+			converted := op
+			var err error
+			switch op.Operand {
+			case "CS", "cs", "SC", "SCN", "sc", "scn", "RG", "rg", "K", "k":
+				converted, err = grayColorOp(op, gs)
+			case "BI":
+				converted, err = grayInlineImage(op, res)
+			}
+			if err != nil {
+				return err
+			}
+
+			changed = changed || converted != op
+			gray = append(gray, converted)
+			return nil
+		})
+
+	//the processor looks up named color spaces in the resources, which may have none
+	procRes := *res
+	if procRes.ColorSpace == nil {
+		procRes.ColorSpace = model.NewPdfPageResourcesColorspaces()
+	}
+	if err = proc.Process(&procRes); err != nil {
+		return "", false, err
+	}
+
+	if !changed {
+		return content, false, nil
+	}
+	return string(gray.Bytes()), true, nil
+}
+
+// grayColorOp converts a color space or color operator to DeviceGray, given the graphics state
+// after it. Pattern color spaces and colors are kept.
+func grayColorOp(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState) (*contentstream.ContentStreamOperation, error) {
+	stroking := op.Operand == "CS" || op.Operand == "SC" || op.Operand == "SCN" || op.Operand == "RG" || op.Operand == "K"
+	cs, color := gs.ColorspaceNonStroking, gs.ColorNonStroking
+	if stroking {
+		cs, color = gs.ColorspaceStroking, gs.ColorStroking
+	}
+	if _, ok := cs.(*model.PdfColorspaceSpecialPattern); ok {
+		return op, nil
+	}
+
+	if op.Operand == "CS" || op.Operand == "cs" {
+		return &contentstream.ContentStreamOperation{Operand: op.Operand, Params: []core.PdfObject{core.MakeName("DeviceGray")}}, nil
+	}
+
+	rgb, err := cs.ColorToRGB(color)
+	if err != nil {
+		return nil, err
+	}
+	rgbColor, ok := rgb.(*model.PdfColorDeviceRGB)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert %s color to gray", op.Operand)
+	}
+
+	operand := "g"
+	if stroking {
+		operand = "G"
+	}
+	return &contentstream.ContentStreamOperation{Operand: operand, Params: []core.PdfObject{core.MakeFloat(rgbColor.ToGray().Val())}}, nil
+}
+
+// grayInlineImage converts the inline image of a BI operator to DeviceGray
+func grayInlineImage(op *contentstream.ContentStreamOperation, res *model.PdfPageResources) (*contentstream.ContentStreamOperation, error) {
+	if len(op.Params) != 1 {
+		return op, nil
+	}
+	iimg, ok := op.Params[0].(*contentstream.ContentStreamInlineImage)
+	if !ok {
+		return op, nil
+	}
+	if mask, err := iimg.IsMask(); err != nil || mask {
+		return op, err
+	}
+	cs, err := iimg.GetColorSpace(res)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := cs.(*model.PdfColorspaceDeviceGray); ok {
+		return op, nil
+	}
+
+	img, err := iimg.ToImage(res)
+	if err != nil {
+		if undecodable(err) {
+			return op, nil
+		}
+		return nil, err
+	}
+	encoder, err := iimg.GetEncoder()
+	if err != nil {
+		return nil, err
+	}
+	grayImg, err := imageToGray(cs, img)
+	if err != nil {
+		return nil, err
+	}
+
+	gray, err := contentstream.NewInlineImageFromImage(grayImg, grayEncoder(encoder, grayImg))
+	if err != nil {
+		return nil, err
+	}
+	return &contentstream.ContentStreamOperation{Operand: "BI", Params: []core.PdfObject{gray}}, nil
+}
+
+// convertXObjects replaces the image and form XObjects of res with grayscale ones
+func (g *grayscale) convertXObjects(res *model.PdfPageResources) error {
+	xobjs, ok := res.XObject.(*core.PdfObjectDictionary)
+	if !ok {
+		return nil
+	}
+
+	for _, name := range xobjs.Keys() {
+		stream, ok := core.TraceToDirectObject(xobjs.Get(name)).(*core.PdfObjectStream)
+		if !ok {
+			continue
+		}
+		gray, err := g.convertXObject(stream)
+		if err != nil {
+			return fmt.Errorf("XObject %s: %v", name, err)
+		}
+		xobjs.Set(name, gray)
+	}
+
+	return nil
+}
+
+// convertXObject returns a grayscale copy of the image or form XObject stream, or stream itself
+// if it is already gray or can't be converted
+func (g *grayscale) convertXObject(stream *core.PdfObjectStream) (*core.PdfObjectStream, error) {
+	if gray, ok := g.converted[stream]; ok {
+		return gray, nil
+	}
+	//forms drawing themselves are left as they are
+	g.converted[stream] = stream
+
+	var gray *core.PdfObjectStream
+	var err error
+	switch subtype, _ := core.TraceToDirectObject(stream.Get("Subtype")).(*core.PdfObjectName); {
+	case subtype == nil:
+		gray = stream
+	case *subtype == "Image":
+		gray, err = grayImageXObject(stream)
+	case *subtype == "Form":
+		gray, err = g.grayForm(stream)
+	default:
+		gray = stream
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	g.converted[stream] = gray
+	return gray, nil
+}
+
+// grayImageXObject converts an image XObject to DeviceGray, keeping its masks
+func grayImageXObject(stream *core.PdfObjectStream) (*core.PdfObjectStream, error) {
+	ximg, err := model.NewXObjectImageFromStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	if mask, ok := core.TraceToDirectObject(ximg.ImageMask).(*core.PdfObjectBool); ok && bool(*mask) || ximg.ColorSpace == nil {
+		return stream, nil
+	}
+	if _, ok := ximg.ColorSpace.(*model.PdfColorspaceDeviceGray); ok {
+		return stream, nil
+	}
+
+	img, err := ximg.ToImage()
+	if err != nil {
+		if undecodable(err) {
+			return stream, nil
+		}
+		return nil, err
+	}
+	grayImg, err := imageToGray(ximg.ColorSpace, img)
+	if err != nil {
+		return nil, err
+	}
+
+	gray, err := model.UpdateXObjectImageFromImage(ximg, &grayImg, model.NewPdfColorspaceDeviceGray(), grayEncoder(ximg.Filter, grayImg))
+	if err != nil {
+		return nil, err
+	}
+	gray.Interpolate = ximg.Interpolate
+	gray.Intent = ximg.Intent
+	gray.Metadata = ximg.Metadata
+
+	return gray.ToPdfObject().(*core.PdfObjectStream), nil
+}
+
+// imageToGray converts img, in color space cs, to DeviceGray
+func imageToGray(cs model.PdfColorspace, img *model.Image) (model.Image, error) {
+	rgb, err := cs.ImageToRGB(*img)
+	if err != nil {
+		return model.Image{}, err
+	}
+	return model.NewPdfColorspaceDeviceRGB().ImageToGray(rgb)
+}
+
+// grayEncoder returns the encoder for the grayscale version of an image encoded with encoder:
+// JPEG images stay JPEG, others are compressed with Flate
+func grayEncoder(encoder core.StreamEncoder, img model.Image) core.StreamEncoder {
+	if dct, ok := encoder.(*core.DCTEncoder); ok {
+		gray := core.NewDCTEncoder()
+		gray.ColorComponents = 1
+		gray.BitsPerComponent = int(img.BitsPerComponent)
+		gray.Width = int(img.Width)
+		gray.Height = int(img.Height)
+		gray.Quality = dct.Quality
+		return gray
+	}
+	return core.NewFlateEncoder()
+}
+
+// grayForm converts the content and resources of a form XObject to grayscale
+func (g *grayscale) grayForm(stream *core.PdfObjectStream) (*core.PdfObjectStream, error) {
+	form, err := model.NewXObjectFormFromStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	content, err := form.GetContentStream()
+	if err != nil {
+		return nil, err
+	}
+
+	res := copyResources(form.Resources)
+	grayContent, changed, err := g.convertContent(string(content), res)
+	if err != nil {
+		return nil, err
+	}
+
+	//copy the form dictionary, replacing its resources and content, which is kept as encoded in
+	//the input if unchanged
+	if !changed {
+		dict := copyDict(stream.PdfObjectDictionary).(*core.PdfObjectDictionary)
+		dict.Set("Resources", res.ToPdfObject())
+		return &core.PdfObjectStream{PdfObjectDictionary: dict, Stream: stream.Stream}, nil
+	}
+	encoder := core.NewFlateEncoder()
+	encoded, err := encoder.EncodeBytes([]byte(grayContent))
+	if err != nil {
+		return nil, err
+	}
+	dict := copyDict(stream.PdfObjectDictionary).(*core.PdfObjectDictionary)
+	dict.Set("Resources", res.ToPdfObject())
+	dict.Set("Filter", core.MakeName(encoder.GetFilterName()))
+	dict.Remove("DecodeParms")
+	dict.Set("Length", core.MakeInteger(int64(len(encoded))))
+
+	return &core.PdfObjectStream{PdfObjectDictionary: dict, Stream: encoded}, nil
+}
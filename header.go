@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// varFlags collects repeated -var flags
+type varFlags map[string]string
+
+// varName matches the names of -var variables, which templates refer to as "{name}"
+var varName = regexp.MustCompile(`^[a-z]+$`)
+
+func (v varFlags) String() string {
+	var defs []string
+	for k, value := range v {
+		defs = append(defs, k+"="+value)
+	}
+	return strings.Join(defs, " ")
+}
+
+func (v varFlags) Set(def string) error {
+	i := strings.Index(def, "=")
+	if i < 0 || !varName.MatchString(def[:i]) {
+		return fmt.Errorf("variable %q must be of the form name=value, with a lower case name", def)
+	}
+	v[def[:i]] = def[i+1:]
+	return nil
+}
+
+// headerStamper stamps header and footer lines built from the template variables of each output
+// on its pages
+type headerStamper struct {
+	header [3]nameTemplate //left, center and right
+	footer [3]nameTemplate
+	style  textStyle
+	vars   map[string]string //-var variables and {date}
+}
+
+// headerVars are the variables of header and footer templates besides the -name ones
+var headerVars = []string{"name", "date", "n", "total"}
+
+// newHeaderStamper returns a stamper for the header and footer templates, either of which may be
+// empty. A template is split at "|" into left, center and right parts: "a" is centered, "a|b" is
+// left and right aligned, and "a|b|c" uses all three.
+func newHeaderStamper(header, footer string, style textStyle, custom map[string]string) (*headerStamper, error) {
+	h := &headerStamper{style: style, vars: map[string]string{"date": time.Now().Format("2006-01-02")}}
+	for k, v := range custom {
+		h.vars[k] = v
+	}
+
+	extra := append([]string{}, headerVars...)
+	for k := range custom {
+		extra = append(extra, k)
+	}
+
+	for _, t := range []struct {
+		flag  string
+		tmpl  string
+		parts *[3]nameTemplate
+	}{{"-header", header, &h.header}, {"-footer", footer, &h.footer}} {
+		if t.tmpl == "" {
+			continue
+		}
+		parts := strings.Split(t.tmpl, "|")
+		switch len(parts) {
+		case 1:
+			t.parts[1].tmpl = parts[0]
+		case 2:
+			t.parts[0].tmpl, t.parts[2].tmpl = parts[0], parts[1]
+		case 3:
+			t.parts[0].tmpl, t.parts[1].tmpl, t.parts[2].tmpl = parts[0], parts[1], parts[2]
+		default:
+			return nil, fmt.Errorf("%s must have at most three parts separated by \"|\"", t.flag)
+		}
+		for _, part := range t.parts {
+			if err := part.check(extra...); err != nil {
+				return nil, fmt.Errorf("%s: %v", t.flag, err)
+			}
+		}
+	}
+
+	return h, nil
+}
+
+// apply stamps the header and footer on the pages of the output name with the given template
+// variables
+func (h *headerStamper) apply(name string, pages []*model.PdfPage, vars map[string]string) ([]*model.PdfPage, error) {
+	all := map[string]string{}
+	for k, v := range h.vars {
+		all[k] = v
+	}
+	for k, v := range vars {
+		all[k] = v
+	}
+	all["name"] = name
+	all["total"] = strconv.Itoa(len(pages))
+
+	alignments := [3]string{"left", "center", "right"}
+	stamped := make([]*model.PdfPage, len(pages))
+	for i, p := range pages {
+		all["n"] = strconv.Itoa(i + 1)
+
+		var texts []edgeText
+		for j := range alignments {
+			if text := h.header[j].expandRaw(all); text != "" {
+				texts = append(texts, edgeText{"top", alignments[j], text})
+			}
+			if text := h.footer[j].expandRaw(all); text != "" {
+				texts = append(texts, edgeText{"bottom", alignments[j], text})
+			}
+		}
+
+		if len(texts) == 0 {
+			stamped[i] = p
+			continue
+		}
+		var err error
+		if stamped[i], err = h.style.stamp(p, texts); err != nil {
+			return nil, err
+		}
+	}
+
+	return stamped, nil
+}
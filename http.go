@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HTTP inputs are fetched in blocks of httpBlockSize bytes, keeping up to httpCacheBlocks of them
+const (
+	httpBlockSize   = 64 << 10
+	httpCacheBlocks = 1024
+)
+
+// isURL reports whether the input fn is an HTTP or HTTPS URL rather than a file
+func isURL(fn string) bool {
+	return strings.HasPrefix(fn, "http://") || strings.HasPrefix(fn, "https://")
+}
+
+// openURL opens the PDF at url. If the server supports range requests, only the blocks the PDF
+// reader needs are fetched, e.g. the cross-reference table and the objects of the pages
+// extracted; otherwise the whole file is downloaded.
+func openURL(url string) (*inputFile, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", httpBlockSize-1))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		//no range support, or a file smaller than a block
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to download %s: %v", url, err)
+		}
+		return &inputFile{inputReader: bytes.NewReader(data), size: int64(len(data)), close: func() error { return nil }}, nil
+	default:
+		return nil, fmt.Errorf("unable to download %s: %s", url, resp.Status)
+	}
+
+	size, err := contentRangeSize(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %v", url, err)
+	}
+	r := &httpReader{url: url, size: size, blocks: map[int64][]byte{}}
+	//a changed file fails the next range request rather than mixing two versions
+	if r.validator = resp.Header.Get("ETag"); r.validator == "" || strings.HasPrefix(r.validator, "W/") {
+		r.validator = resp.Header.Get("Last-Modified")
+	}
+	first, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %v", url, err)
+	}
+	r.add(0, first)
+
+	return &inputFile{inputReader: io.NewSectionReader(r, 0, size), size: size, close: func() error { return nil }}, nil
+}
+
+// contentRangeSize returns the complete length in a Content-Range header, e.g. 1234 in
+// "bytes 0-99/1234"
+func contentRangeSize(header string) (int64, error) {
+	i := strings.LastIndex(header, "/")
+	if i < 0 {
+		return 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	size, err := strconv.ParseInt(header[i+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unknown file size in Content-Range %q", header)
+	}
+	return size, nil
+}
+
+// httpReader reads a remote file with range requests, caching the blocks fetched
+type httpReader struct {
+	url       string
+	size      int64
+	validator string //ETag or Last-Modified of the file, if any, sent as If-Range
+
+	mu     sync.Mutex
+	blocks map[int64][]byte //by block index
+	order  []int64          //cached block indexes, oldest first
+}
+
+// ReadAt reads len(p) bytes at off, fetching each run of blocks not cached with one request
+func (r *httpReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > r.size {
+		end = r.size
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for b := off / httpBlockSize; b*httpBlockSize < end; b++ {
+		if _, ok := r.blocks[b]; !ok {
+			//the run fetched is the newest in the cache, so it stays until copied
+			run := b
+			for (run+1)*httpBlockSize < end && r.blocks[run+1] == nil && run-b+1 < httpCacheBlocks {
+				run++
+			}
+			if err := r.fetch(b, run); err != nil {
+				return n, err
+			}
+		}
+
+		start := off + int64(n) - b*httpBlockSize
+		n += copy(p[n:end-off], r.blocks[b][start:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fetch fetches and caches the blocks first to last
+func (r *httpReader) fetch(first, last int64) error {
+	start, end := first*httpBlockSize, (last+1)*httpBlockSize-1
+	if end >= r.size {
+		end = r.size - 1
+	}
+	logger.Debug("Fetching", logField{"url", r.url}, logField{"range", fmt.Sprintf("%d-%d", start, end)})
+
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if r.validator != "" {
+		req.Header.Set("If-Range", r.validator)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return errors.New("remote file changed while reading it")
+	default:
+		return fmt.Errorf("unable to download %s: %s", r.url, resp.Status)
+	}
+
+	data := make([]byte, end-start+1)
+	if _, err = io.ReadFull(resp.Body, data); err != nil {
+		return fmt.Errorf("unable to download %s: %v", r.url, err)
+	}
+	for b := first; b <= last; b++ {
+		block := data[(b-first)*httpBlockSize:]
+		if len(block) > httpBlockSize {
+			block = block[:httpBlockSize]
+		}
+		r.add(b, block)
+	}
+	return nil
+}
+
+// add caches block b, dropping the oldest block if the cache is full
+func (r *httpReader) add(b int64, block []byte) {
+	if len(r.order) >= httpCacheBlocks {
+		delete(r.blocks, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.blocks[b] = block
+	r.order = append(r.order, b)
+}
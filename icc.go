@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// sRGB primaries and white point, adapted to the D50 profile connection space, as s15Fixed16
+// XYZ values in the order of the rXYZ, gXYZ, bXYZ and wtpt tags
+var srgbXYZ = [4][3]float64{
+	{0.4361, 0.2225, 0.0139},
+	{0.3851, 0.7169, 0.0971},
+	{0.1431, 0.0606, 0.7141},
+	{0.9642, 1.0, 0.8249},
+}
+
+// srgbCurvePoints is the number of entries of the sRGB tone curve table
+const srgbCurvePoints = 1024
+
+// srgbProfile returns an ICC version 2 display profile for sRGB (IEC 61966-2-1), as needed for
+// the output intent of a PDF/A file using device RGB colors
+func srgbProfile() []byte {
+	type tag struct {
+		sig  string
+		data []byte
+	}
+
+	desc := []byte("sRGB IEC61966-2.1\x00")
+	descTag := append(iccTypeHeader("desc"), iccUint32(uint32(len(desc)))...)
+	descTag = append(descTag, desc...)
+	descTag = append(descTag, make([]byte, 4+4+2+1+67)...) //no Unicode or ScriptCode description
+
+	cprtTag := append(iccTypeHeader("text"), "No copyright, use freely\x00"...)
+
+	xyz := func(v [3]float64) []byte {
+		b := iccTypeHeader("XYZ ")
+		for _, f := range v {
+			b = append(b, iccUint32(uint32(int32(math.Round(f*65536))))...)
+		}
+		return b
+	}
+
+	curve := append(iccTypeHeader("curv"), iccUint32(srgbCurvePoints)...)
+	for i := 0; i < srgbCurvePoints; i++ {
+		x := float64(i) / (srgbCurvePoints - 1)
+		y := x / 12.92
+		if x > 0.04045 {
+			y = math.Pow((x+0.055)/1.055, 2.4)
+		}
+		v := uint16(math.Round(y * 65535))
+		curve = append(curve, byte(v>>8), byte(v))
+	}
+
+	tags := []tag{
+		{"desc", descTag},
+		{"cprt", cprtTag},
+		{"rXYZ", xyz(srgbXYZ[0])},
+		{"gXYZ", xyz(srgbXYZ[1])},
+		{"bXYZ", xyz(srgbXYZ[2])},
+		{"wtpt", xyz(srgbXYZ[3])},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	//the tone curves share their data
+	table := iccUint32(uint32(len(tags)))
+	var data []byte
+	offsets := map[*byte]int{}
+	start := 128 + 4 + 12*len(tags)
+	for _, t := range tags {
+		offset, ok := offsets[&t.data[0]]
+		if !ok {
+			offset = start + len(data)
+			offsets[&t.data[0]] = offset
+			data = append(data, t.data...)
+			for len(data)%4 != 0 {
+				data = append(data, 0)
+			}
+		}
+		table = append(table, t.sig...)
+		table = append(table, iccUint32(uint32(offset))...)
+		table = append(table, iccUint32(uint32(len(t.data)))...)
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header, uint32(start+len(data)))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) //version 2.1
+	copy(header[12:], "mntrRGB XYZ ")
+	binary.BigEndian.PutUint16(header[24:], 2000) //creation date, 2000-01-01
+	binary.BigEndian.PutUint16(header[26:], 1)
+	binary.BigEndian.PutUint16(header[28:], 1)
+	copy(header[36:], "acsp")
+	for i, f := range srgbXYZ[3] {
+		binary.BigEndian.PutUint32(header[68+4*i:], uint32(int32(math.Round(f*65536))))
+	}
+
+	profile := append(header, table...)
+	return append(profile, data...)
+}
+
+// iccTypeHeader returns the type signature and reserved bytes starting an ICC tag
+func iccTypeHeader(sig string) []byte {
+	return append([]byte(sig), 0, 0, 0, 0)
+}
+
+// iccUint32 returns v big-endian
+func iccUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
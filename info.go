@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// runInfo prints the page count, document information and incremental revisions of a PDF
+func runInfo(args []string) {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	output := outputFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter info: input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("info", output)
+	defer finishOutput()
+
+	if fs.NArg() != 1 {
+		usage(fs)
+	}
+
+	in := fs.Arg(0)
+	f, err := openInput(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	pdf, err := readPDF(f, nil, 0)
+	if err != nil {
+		fatalInput(in, err)
+	}
+
+	res := infoResult{File: in, Info: map[string]string{}}
+	res.Encrypted, _ = pdf.IsEncrypted()
+	if numPages, err := pdf.GetNumPages(); err == nil {
+		res.Pages = &numPages
+		res.Info = docInfo(pdf)
+	}
+
+	//list the revisions, with their page counts
+	revs, err := findRevisions(f, f.size)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	for _, rev := range revs {
+		r := revisionResult{End: rev.end, Xref: rev.xref}
+		if rpdf, err := readPDF(io.NewSectionReader(f, 0, rev.end), nil, 0); err != nil {
+			r.Error = "unreadable"
+		} else if numPages, err := rpdf.GetNumPages(); err != nil {
+			r.Error = "user password needed"
+		} else {
+			r.Pages = &numPages
+		}
+		res.Revisions = append(res.Revisions, r)
+	}
+	if size := f.size; len(revs) > 0 && revs[len(revs)-1].end < size {
+		res.TrailingBytes = size - revs[len(revs)-1].end
+	}
+
+	if jsonReport != nil {
+		addResult(res)
+		return
+	}
+	res.print()
+}
+
+// infoResult is what info prints about a PDF
+type infoResult struct {
+	File          string            `json:"file"`
+	Encrypted     bool              `json:"encrypted"`
+	Pages         *int              `json:"pages"` //nil if a user password is needed
+	Info          map[string]string `json:"info"`
+	Revisions     []revisionResult  `json:"revisions"`
+	TrailingBytes int64             `json:"trailing_bytes,omitempty"`
+}
+
+// revisionResult is an incremental revision in an infoResult
+type revisionResult struct {
+	End   int64  `json:"end"`
+	Xref  int64  `json:"xref"`
+	Pages *int   `json:"pages,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// print prints the result as text
+func (res infoResult) print() {
+	fmt.Println("File:", res.File)
+	if res.Encrypted {
+		fmt.Println("Encrypted: yes")
+	}
+	if res.Pages == nil {
+		fmt.Println("Pages: unknown, user password needed")
+	} else {
+		fmt.Println("Pages:", *res.Pages)
+
+		keys := make([]string, 0, len(res.Info))
+		for key := range res.Info {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("%s: %s\n", key, res.Info[key])
+		}
+	}
+
+	fmt.Println("Revisions:", len(res.Revisions))
+	for i, rev := range res.Revisions {
+		pages := rev.Error
+		if rev.Pages != nil && *rev.Pages == 1 {
+			pages = "1 page"
+		} else if rev.Pages != nil {
+			pages = fmt.Sprintf("%d pages", *rev.Pages)
+		}
+		fmt.Printf("  %d: @%d, xref at %d, %s\n", i+1, rev.End, rev.Xref, pages)
+	}
+	if res.TrailingBytes > 0 {
+		fmt.Printf("  %d bytes after the last revision\n", res.TrailingBytes)
+	}
+}
+
+// readPDF parses the PDF rs. An encrypted PDF is decrypted if it has no user password,
+// as is common for PDFs that only restrict printing or copying; otherwise its pages can't be
+// read. If workers is over 1, the stream data of an encrypted PDF is decrypted up front on that
+// many goroutines, for reading all of it, as a split does; otherwise objects are decrypted as
+// they are read.
+// If warn is set, malformed objects are worked around, calling warn for each.
+func readPDF(rs io.ReadSeeker, warn func(core.Warning), workers int) (*model.PdfReader, error) {
+	pdf, err := model.NewPdfReaderWithOptions(rs, &model.ReaderOptions{Warn: warn})
+	if err != nil {
+		return nil, err
+	}
+
+	if encrypted, err := pdf.IsEncrypted(); err != nil {
+		return nil, err
+	} else if encrypted {
+		pdf.SetDecryptWorkers(workers)
+		if _, err = pdf.Decrypt(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return pdf, nil
+}
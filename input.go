@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// inputReader is the random access reading an input PDF needs
+type inputReader interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// inputFile is an input PDF opened for reading. Local files are memory-mapped where the OS
+// supports it, so the object lookups of a split jumping around a multi-GB input read straight
+// from the page cache rather than refilling a buffered reader at each seek.
+type inputFile struct {
+	inputReader
+	size  int64
+	close func() error
+}
+
+// openInput opens the PDF file fn, memory-mapped if possible, or the PDF at fn if it is an HTTP,
+// HTTPS or SFTP URL
+func openInput(fn string) (*inputFile, error) {
+	if isURL(fn) {
+		return openURL(fn)
+	}
+	if isSFTP(fn) {
+		return openSFTP(fn)
+	}
+
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	//fall back to reading the file, e.g. for pipes or if the OS has no mmap
+	data, err := mmapFile(f, fi.Size())
+	if err != nil {
+		return &inputFile{inputReader: f, size: fi.Size(), close: f.Close}, nil
+	}
+
+	//the mapping stays valid once the file is closed
+	f.Close()
+	return &inputFile{inputReader: bytes.NewReader(data), size: fi.Size(), close: func() error { return munmapFile(data) }}, nil
+}
+
+// Close unmaps or closes the file. Reading it afterwards fails, rather than faulting on
+// unmapped memory.
+func (f *inputFile) Close() error {
+	f.inputReader = closedInput{}
+	return f.close()
+}
+
+// closedInput is the reader of a closed inputFile
+type closedInput struct{}
+
+func (closedInput) Read([]byte) (int, error)          { return 0, os.ErrClosed }
+func (closedInput) ReadAt([]byte, int64) (int, error) { return 0, os.ErrClosed }
+func (closedInput) Seek(int64, int) (int64, error)    { return 0, os.ErrClosed }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+
+	"github.com/unidoc/unidoc/pdf/extractor"
+)
+
+// pageLayout is the text of a page as layout exports it: the text the content-based splits match,
+// and the words shown with their boxes and font sizes
+type pageLayout struct {
+	Page   int          `json:"page"`
+	Width  float64      `json:"width"` //in points, of the CropBox or MediaBox
+	Height float64      `json:"height"`
+	Text   string       `json:"text"` //as matched by -re, -separator and -max-tokens
+	Words  []layoutWord `json:"words"`
+}
+
+// layoutWord is a word on a page
+type layoutWord struct {
+	Text     string     `json:"text"`
+	Box      [4]float64 `json:"box"` //llx, lly, urx, ury in points, in the user space of the page
+	FontSize float64    `json:"font_size"`
+	Font     string     `json:"font,omitempty"`
+}
+
+// runLayout exports the extracted text of the pages of a PDF with the words shown, their
+// bounding boxes and font sizes, for layout analysis and to see what content-based splits match
+func runLayout(args []string) {
+	fs := flag.NewFlagSet("layout", flag.ExitOnError)
+	output := outputFlags(fs)
+	ranges := fs.String("pages", "", "pages and page `ranges` to export, e.g. \"1-3,9\" (default all)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter layout: [-pages ranges] [-output json] input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("layout", output)
+	defer finishOutput()
+
+	if fs.NArg() != 1 {
+		usage(fs)
+	}
+
+	in := fs.Arg(0)
+	pdf, f, err := openPDF(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	numbers := make([]int, len(pdf.PageList))
+	for i := range numbers {
+		numbers[i] = i + 1
+	}
+	if *ranges != "" {
+		if numbers, err = parseRanges(*ranges, len(pdf.PageList)); err != nil {
+			argError("Invalid -pages:", err)
+		}
+	}
+
+	for _, n := range numbers {
+		p := pdf.PageList[n-1]
+		res := pageLayout{Page: n, Words: []layoutWord{}}
+		if box, _, err := visibleBox(p); err != nil {
+			warning("Page %d: %v", n, err)
+		} else {
+			res.Width, res.Height = round2(box.Urx-box.Llx), round2(box.Ury-box.Lly)
+		}
+
+		//the words are exported even if the text can't be, and the other way round
+		if res.Text, err = pageText(p); err != nil {
+			warning("Unable to extract page %d text: %v", n, err)
+		}
+		ex, err := extractor.New(p)
+		if err != nil {
+			fatalf("Unable to read page %d: %v\n", n, err)
+		}
+		words, err := ex.ExtractWords()
+		if err != nil {
+			warning("Unable to extract page %d words: %v", n, err)
+		}
+		for _, w := range words {
+			res.Words = append(res.Words, layoutWord{
+				Text:     w.Text,
+				Box:      [4]float64{round2(w.Llx), round2(w.Lly), round2(w.Urx), round2(w.Ury)},
+				FontSize: round2(w.FontSize),
+				Font:     w.Font,
+			})
+		}
+
+		if jsonReport != nil {
+			addResult(res)
+		} else {
+			res.print()
+		}
+	}
+}
+
+// round2 rounds v to 2 decimal places, more than enough for positions in points
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// print prints the words of the page as text, one per line
+func (res pageLayout) print() {
+	fmt.Printf("Page %d: %gx%g pt, %d words\n", res.Page, res.Width, res.Height, len(res.Words))
+	for _, w := range res.Words {
+		fmt.Printf("  %q at %g,%g-%g,%g, %g pt %s\n", w.Text, w.Box[0], w.Box[1], w.Box[2], w.Box[3], w.FontSize, w.Font)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/unidoc/unidoc/common"
+)
+
+// log levels, set by -q, -v and -vv
+const (
+	levelQuiet   = iota //errors only
+	levelNormal         //also progress and warnings
+	levelVerbose        //also the errors and warnings UniDoc logs while parsing
+	levelDebug          //also UniDoc debug messages
+)
+
+// leveledLogger is what the splitter logs to: a message with optional fields, at one of four
+// levels. A program embedding the splitter can pass its own to setLogger, and gets the UniDoc
+// messages through it too, rather than setting the global common.Logger of UniDoc itself.
+type leveledLogger interface {
+	Debug(msg string, fields ...logField)
+	Info(msg string, fields ...logField)
+	Warn(msg string, fields ...logField)
+	Error(msg string, fields ...logField)
+}
+
+// logField is a key and value logged with a message, e.g. the source of a UniDoc message
+type logField struct {
+	key   string
+	value interface{}
+}
+
+// logger is the logger of the running command
+var logger leveledLogger = stderrLogger{levelNormal}
+
+// setLogger makes l the logger, passing it all UniDoc messages but its trace
+func setLogger(l leveledLogger) {
+	logger = l
+	common.SetLogger(unidocLogger{common.LogLevelDebug})
+}
+
+// setLogLevel logs to stderr at level, of UniDoc too
+func setLogLevel(level int) {
+	logger = stderrLogger{level}
+	switch level {
+	case levelVerbose:
+		common.SetLogger(unidocLogger{common.LogLevelWarning})
+	case levelDebug:
+		common.SetLogger(unidocLogger{common.LogLevelDebug})
+	default:
+		common.SetLogger(common.DummyLogger{})
+	}
+}
+
+// logInfo logs progress, like log.Println, unless -q is set
+func logInfo(args ...interface{}) {
+	logger.Info(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+// logInfof logs progress, like log.Printf, unless -q is set
+func logInfof(format string, args ...interface{}) {
+	logger.Info(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+// stderrLogger is the logger of the command line, logging to stderr through the log package
+// with the fields of a message after it as key=value
+type stderrLogger struct {
+	level int
+}
+
+func (l stderrLogger) Debug(msg string, fields ...logField) {
+	if l.level >= levelDebug {
+		l.output(msg, fields)
+	}
+}
+
+func (l stderrLogger) Info(msg string, fields ...logField) {
+	if l.level >= levelNormal {
+		l.output(msg, fields)
+	}
+}
+
+func (l stderrLogger) Warn(msg string, fields ...logField) {
+	if l.level >= levelNormal {
+		l.output(msg, fields)
+	}
+}
+
+func (l stderrLogger) Error(msg string, fields ...logField) {
+	l.output(msg, fields)
+}
+
+// output logs msg and its fields
+func (l stderrLogger) output(msg string, fields []logField) {
+	for _, f := range fields {
+		msg += fmt.Sprintf(" %s=%v", f.key, f.value)
+	}
+	log.Println(msg)
+}
+
+// unidocLogger is the common.Logger UniDoc logs to, passing its messages up to the given level on
+// to logger. This keeps them off stdout, where common.ConsoleLogger writes them, mixed with the
+// output of commands.
+type unidocLogger struct {
+	level common.LogLevel
+}
+
+// unidocSource is the field marking messages from UniDoc
+var unidocSource = logField{"source", "unidoc"}
+
+func (l unidocLogger) Error(format string, args ...interface{}) {
+	if l.level >= common.LogLevelError {
+		logger.Error(fmt.Sprintf(format, args...), unidocSource)
+	}
+}
+
+func (l unidocLogger) Warning(format string, args ...interface{}) {
+	if l.level >= common.LogLevelWarning {
+		logger.Warn(fmt.Sprintf(format, args...), unidocSource)
+	}
+}
+
+func (l unidocLogger) Notice(format string, args ...interface{}) {
+	if l.level >= common.LogLevelNotice {
+		logger.Info(fmt.Sprintf(format, args...), unidocSource)
+	}
+}
+
+func (l unidocLogger) Info(format string, args ...interface{}) {
+	if l.level >= common.LogLevelInfo {
+		logger.Info(fmt.Sprintf(format, args...), unidocSource)
+	}
+}
+
+func (l unidocLogger) Debug(format string, args ...interface{}) {
+	if l.level >= common.LogLevelDebug {
+		logger.Debug(fmt.Sprintf(format, args...), unidocSource)
+	}
+}
+
+func (l unidocLogger) Trace(format string, args ...interface{}) {
+	if l.level >= common.LogLevelTrace {
+		logger.Debug(fmt.Sprintf(format, args...), unidocSource)
+	}
+}
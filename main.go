@@ -1,117 +1,878 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
-	"path"
 	"regexp"
+	"runtime"
+	"strconv"
+	"time"
 
-	"github.com/unidoc/unidoc/pdf/extractor"
+	"github.com/unidoc/unidoc/pdf/core"
 	"github.com/unidoc/unidoc/pdf/model"
 )
 
+// commands are the subcommands run instead of splitting, e.g. "pdf-splitter diff a.pdf b.pdf"
+var commands = map[string]func(args []string){
+	"annotations": runAnnotations,
+	"bench":       runBench,
+	"diff":        runDiff,
+	"encrypt":     runEncrypt,
+	"extract":     runExtract,
+	"fonts":       runFonts,
+	"forms":       runForms,
+	"info":        runInfo,
+	"layout":      runLayout,
+	"merge":       runMerge,
+	"pages":       runPages,
+	"pipeline":    runPipeline,
+	"reorder":     runReorder,
+	"revisions":   runRevisions,
+	"validate":    runValidate,
+}
+
 func main() {
+	if runAskpass() {
+		return
+	}
+
+	//run subcommand
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
+	runSplit(os.Args[1:])
+}
+
+// runSplit splits the input PDF, the command run without a subcommand, with the flags args
+func runSplit(args []string) {
 	re := flag.String("re", "", "regular expression for value in PDF page content")
-	in := flag.String("in", "", "input PDF")
-	out := flag.String("out", "", "directory for outputing PDFs")
+	in := flag.String("in", "", "input PDF file, or HTTP(S) or SFTP URL")
+	out := flag.String("out", "", "directory for outputing PDFs, or SFTP URL of one")
 	debug := flag.Bool("debug", false, "output extracted text for each page")
-	flag.Parse()
+	var parts partFlags
+	flag.Var(&parts, "part", "output `name=ranges` built from the given input pages, e.g. \"report.pdf=1-10,25\" (may be repeated)")
+	dupes := flag.String("dupes", "", "duplicate page handling: \"report\" logs pages identical to an earlier page, \"drop\" skips pages identical to the previous page")
+	dupesIndexFile := flag.String("dupes-index", "", "`file` of the page hashes of earlier inputs of a batch, updated with those of -in, to also -dupes report or drop pages identical to a page of an earlier input")
+	overlayPDF := flag.String("overlay", "", "PDF whose pages are stamped over output pages")
+	underlayPDF := flag.String("underlay", "", "PDF whose pages are stamped under output pages, e.g. letterhead")
+	stampPages := flag.String("stamp-pages", stampAll, "output pages stamped with -overlay/-underlay: \"all\", \"first\" or \"alternate\"")
+	pageNumbers := flag.String("page-numbers", "", "stamp page numbers on output pages from this `template`, with {n} and {total}, e.g. \"Page {n} of {total}\"")
+	pageNumberMode := flag.String("page-number-mode", numberPerPart, "page numbering: \"part\" counts each output from 1, \"continue\" carries the count on from the previous output, with {total} the input page count")
+	pageNumberFont := flag.String("page-number-font", "Helvetica", "standard font of the page numbers, e.g. Helvetica, Times-Roman or Courier-Bold")
+	pageNumberSize := flag.Float64("page-number-size", 10, "font size of the page numbers")
+	pageNumberPos := flag.String("page-number-pos", "bottom-center", "position of the page numbers: top or bottom, \"-\" and left, center or right")
+	pageNumberMargin := flag.Float64("page-number-margin", 36, "distance of the page numbers from the page edges, in points")
+	header := flag.String("header", "", "header `template` stamped on output pages, with the -name variables plus {name}, {date}, {n}, {total} and -var ones; \"|\" separates left, center and right parts, e.g. \"{title}||{date}\"")
+	footer := flag.String("footer", "", "footer `template` stamped on output pages, like -header")
+	headerFont := flag.String("header-font", "Helvetica", "standard font of -header and -footer")
+	headerSize := flag.Float64("header-size", 9, "font size of -header and -footer")
+	headerMargin := flag.Float64("header-margin", 24, "distance of -header and -footer from the page edges, in points")
+	customVars := varFlags{}
+	flag.Var(customVars, "var", "template variable `name=value` for -header and -footer, e.g. \"client=ACME\" for {client} (may be repeated)")
+	qr := flag.Bool("qr", false, "stamp a QR code of the -qr-job ID, output index and a checksum of its pages on the first page of each output")
+	qrJob := flag.String("qr-job", "", "job ID in -qr codes (default the start time, e.g. \"20240701-093000\")")
+	qrPos := flag.String("qr-pos", "bottom-right", "position of -qr codes: top or bottom, \"-\" and left, center or right")
+	qrSize := flag.Float64("qr-size", 54, "width of -qr codes, in points")
+	qrMargin := flag.Float64("qr-margin", 18, "distance of -qr codes from the page edges, in points")
+	qrManifest := flag.String("qr-manifest", "", "CSV file to write output names, job IDs, indexes and checksums of -qr codes to")
+	autoRotateFlag := flag.Bool("auto-rotate", false, "set the rotation of output pages so their text reads upright, fixing sideways and upside down scans with a text layer")
+	splitSpreads := flag.Bool("split-spreads", false, "split spreads, output pages at least 1.2 times as wide as high such as book scans of two pages, into their left and right pages")
+	trimBorders := flag.Float64("trim-borders", 0, "crop the dark borders of scanned output pages, trimming at most `max` points from each edge (0 to keep them)")
+	attachments := flag.String("attachments", attachNone, "document-level attachments of the input outputs get: \"all\", \"pages\" for those their pages refer to, or \"none\"")
+	pageLayout := flag.String("page-layout", "", "page `layout` outputs open in, instead of that of the input: single, one-column, two-column-left, two-column-right, two-page-left or two-page-right")
+	pageMode := flag.String("page-mode", "", "`panel` outputs open with: none, outlines, thumbnails, attachments, layers or full-screen (default outlines for outputs with -outline, otherwise none)")
+	openFit := flag.String("open-fit", "", "`zoom` of the page outputs open at, the page of the input's open action or the first: page, width, height, visible, actual or a percentage such as 150 (default that of the input)")
+	grayscale := flag.Bool("grayscale", false, "convert output page colors and images to grayscale (DeviceGray)")
+	stripImages := flag.Bool("strip-images", false, "replace output images with empty placeholders, for lightweight text-only outputs")
+	importAnnotations := flag.String("import-annotations", "", "XFDF `file` of annotations, e.g. from annotations export, to add to the output pages of the input pages they are on")
+	subsetFonts := flag.Bool("subset-fonts", false, "subset the embedded TrueType fonts of Type 0 (e.g. CJK) fonts to the glyphs each output uses")
+	splitBookmarks := flag.Bool("bookmarks", false, "split at each top-level bookmark, naming outputs by bookmark title")
+	separator := flag.String("separator", "", "literal `text` marking separator sheets, e.g. \"== SEPARATOR ==\", each starting a new output")
+	dropSeparators := flag.Bool("drop-separators", false, "leave -separator sheets out of the outputs")
+	maxTokens := flag.Int("max-tokens", 0, "split into runs of whole pages with at most `n` tokens of text each, estimated for language model context limits")
+	rule := flag.String("rule", "", "`expression` starting a new output at each page it is true for, e.g. 'text ~ \"Invoice\" && page.size == A4 || blank(prev)'")
+	classifier := flag.String("classifier", "", "HTTP `endpoint` or command sent the text and scan thumbnail of each page as JSON, answering whether it starts a new output and a name for it, e.g. \"http://localhost:8000/classify\"")
+	field := flag.String("field", "", "form field `name` whose value starts a new output where it changes, naming outputs by {value}, e.g. \"recipient\"")
+	bookmarkLevel := flag.Int("bookmark-level", 1, "deepest outline level split at with -bookmarks")
+	outlineMode := flag.String("outline", "", "generate a fresh outline in each output: \"ranges\" adds an item for each run of input pages, \"bookmarks\" the input bookmarks pointing to its pages")
+	metadataOpts := addMetadataFlags(flag.CommandLine)
+	zipOut := flag.String("zip", "", "ZIP file to write the outputs to, instead of -out")
+	zipPassword := flag.String("zip-password", "", "password to AES-256 encrypt the -zip entries with")
+	encryptAlgo := flag.String("encrypt", encryptAES256, "encryption for password protected outputs: \"aes256\", \"aes128\" or \"rc4\"")
+	encryptMetadata := flag.Bool("encrypt-metadata", true, "encrypt the XMP metadata of password protected outputs (false needs aes128 or aes256)")
+	password := flag.String("password", "", "user password for the outputs")
+	ownerPassword := flag.String("owner-password", "", "owner password for password protected outputs (default random)")
+	passwordTmpl := flag.String("password-template", "", "per-output user password `template`, with the -name variables plus {name} and {match}, e.g. \"{match}\"")
+	passwordRe := flag.String("password-re", "", "regular expression for {match} in the first page text of each output")
+	passwordList := flag.String("passwords", "", "CSV file of output name, user password rows")
+	jobReportFile := flag.String("report", "", "CSV `file` to append a row to for each output: source, pages, output, page count, bytes, duration, status and error")
+	events := flag.String("events", "", "`URL` of a NATS subject, e.g. \"nats://localhost:4222/splits\", or a Kafka topic on a REST Proxy, e.g. \"kafka+http://localhost:8082/splits\", to publish job started, part written and job finished events to")
+	job := flag.String("job", "", "job ID in -events (default the start time, e.g. \"20240701-093000\")")
+	progressFile := flag.String("progress", "", "`file` to stream JSON lines of pages done and warnings to as the split runs, e.g. a pipe such as \"/dev/fd/3\"")
+	passwordReport := flag.String("password-report", "", "CSV file to write output names and user passwords to")
+	passwordSources := []*passwordFlags{
+		addPasswordFlags(flag.CommandLine, "password", password),
+		addPasswordFlags(flag.CommandLine, "owner-password", ownerPassword),
+		addPasswordFlags(flag.CommandLine, "zip-password", zipPassword),
+	}
+	sftpKey := flag.String("sftp-key", "", "private key `file` for SFTP -in and -out URLs (default the ssh defaults)")
+	sftpKnownHosts := flag.String("sftp-known-hosts", "", "known hosts `file` SFTP server host keys are checked against (default the ssh defaults)")
+	shard := flag.Int("shard", 0, "maximum outputs per numbered subdirectory of -out (0 to write all outputs to -out)")
+	nameTmpl := flag.String("name", "", "output name `template` for -re, -bookmarks, -field, -separator, -max-tokens, -rule and -classifier, e.g. \"{year}/{bookmark1}/{index}.pdf\" (default \"{value}.pdf\", \"{bookmark}.pdf\" or \"{index}.pdf\")")
+	nameOpts := addNameFlags(flag.CommandLine)
+	strictness := flag.String("strictness", strict, "handling of malformed objects: \"strict\" fails on them, \"lenient\" works around them, logging a warning for each")
+	preflightFile := flag.String("preflight", "", "JSON preflight `profile` of rules enforced on -in and the outputs, failing or warning on violations")
+	pdfa := flag.Bool("pdfa", false, "convert outputs to PDF/A-2b, as far as possible, reporting what can't be converted")
+	checkUA := flag.Bool("check-ua", false, "report outputs that don't meet the basic PDF/UA requirements: tagged content, a document title and a language")
+	maxMemory := flag.Int("max-memory", 0, "memory budget in `MB`: the split fails once its peak memory is over it, rather than being killed by the OS (0 for none)")
+	revisionSpec := flag.String("revision", "latest", "incremental revision of -in to split: a `revision` number from 1 for the original, \"@\" and the offset it ends at, as listed by the info command, or \"latest\"")
+	output := outputFlags(flag.CommandLine)
+	flag.CommandLine.Parse(args)
+	startOutput("split", output)
+	defer finishOutput()
 
 	//check -re
-	if *re == "" {
-		fmt.Println("-re must be set")
-		return
+	if *re == "" && len(parts) == 0 && !*splitBookmarks && *field == "" && *separator == "" && *maxTokens == 0 && *rule == "" && *classifier == "" {
+		argError("-re, -part, -bookmarks, -field, -separator, -max-tokens, -rule or -classifier must be set")
+	}
+	if *field != "" && (*re != "" || len(parts) > 0 || *splitBookmarks) {
+		argError("-field can't be combined with -re, -part or -bookmarks")
+	}
+	if *separator != "" && (*re != "" || len(parts) > 0 || *splitBookmarks || *field != "") {
+		argError("-separator can't be combined with -re, -part, -bookmarks or -field")
+	}
+	if *maxTokens < 0 {
+		argError("-max-tokens must not be negative")
+	}
+	if *maxTokens > 0 && (*re != "" || len(parts) > 0 || *splitBookmarks || *field != "" || *separator != "") {
+		argError("-max-tokens can't be combined with -re, -part, -bookmarks, -field or -separator")
+	}
+	if *rule != "" && (*re != "" || len(parts) > 0 || *splitBookmarks || *field != "" || *separator != "" || *maxTokens > 0) {
+		argError("-rule can't be combined with -re, -part, -bookmarks, -field, -separator or -max-tokens")
+	}
+	if *classifier != "" && (*re != "" || len(parts) > 0 || *splitBookmarks || *field != "" || *separator != "" || *maxTokens > 0 || *rule != "") {
+		argError("-classifier can't be combined with -re, -part, -bookmarks, -field, -separator, -max-tokens or -rule")
+	}
+	if *dropSeparators && *separator == "" {
+		argError("-drop-separators requires -separator")
 	}
 	matchRegexp, err := regexp.Compile(*re)
 	if err != nil {
-		fmt.Println("Invalid regexp:", err)
-		return
+		argError("Invalid regexp:", err)
+	}
+
+	//check -max-memory
+	if *maxMemory < 0 {
+		argError("-max-memory must not be negative")
+	}
+	if *maxMemory > 0 {
+		setMemoryBudget(*maxMemory)
+	}
+
+	//check -rule
+	var splitRule ruleExpr
+	if *rule != "" {
+		if splitRule, err = parseRule(*rule); err != nil {
+			argError("Invalid -rule:", err)
+		}
 	}
 
 	//check -in
 	if *in == "" {
-		fmt.Println("Must specify -in file")
-		return
+		argError("Must specify -in file")
 	}
 
 	//check -out
-	if *out == "" {
-		fmt.Println("Must specify -out directory")
-		return
+	if *out == "" && *zipOut == "" {
+		argError("Must specify -out directory or -zip file")
 	}
+	sftpConfig = sftpOptions{key: *sftpKey, knownHosts: *sftpKnownHosts}
 
-	//open file
-	f, err := os.Open(*in)
-	if err != nil {
-		log.Fatalln("Unable to open input PDF:", err)
+	//open the progress file first, so it gets every warning
+	if *progressFile != "" {
+		if err = openProgress(*progressFile); err != nil {
+			exitError(exitWrite, "Unable to open progress file:", err)
+		}
+	}
+
+	//outputs for an SFTP -out are written to a temporary directory, uploaded once all are written
+	var remote *sftpTarget
+	if isSFTP(*out) {
+		if remote, err = parseSFTP(*out); err != nil {
+			argError("Invalid -out:", err)
+		}
+		if *out, err = os.MkdirTemp("", "pdf-splitter-"); err != nil {
+			exitError(exitWrite, "Unable to create temporary directory:", err)
+		}
+		dir := *out
+		atExit(func() { os.RemoveAll(dir) })
+	}
+
+	//read passwords not given on the command line
+	if err = readPasswords(passwordSources...); err != nil {
+		argError(err)
+	}
+
+	//check -zip-password
+	if *zipPassword != "" && *zipOut == "" {
+		argError("-zip-password requires -zip")
+	}
+
+	//check -dupes
+	if *dupes != "" && *dupes != dupesReport && *dupes != dupesDrop {
+		argError("-dupes must be report or drop")
+	}
+	if *dupesIndexFile != "" && *dupes == "" {
+		argError("-dupes-index requires -dupes")
+	}
+
+	//check -stamp-pages
+	if *stampPages != stampAll && *stampPages != stampFirst && *stampPages != stampAlternate {
+		argError("-stamp-pages must be all, first or alternate")
+	}
+
+	//check -sanitize, -sanitize-re, -replace and -case
+	names := nameOpts.options()
+
+	//check -strictness
+	var warn func(core.Warning)
+	if *strictness == lenient {
+		warn = logWarning
+	} else if *strictness != strict {
+		argError("-strictness must be strict or lenient")
+	}
+
+	//check -name
+	tmpl := nameTemplate{tmpl: *nameTmpl, names: names}
+	if tmpl.tmpl == "" {
+		tmpl.tmpl = "{value}.pdf"
+		if *splitBookmarks {
+			tmpl.tmpl = "{bookmark}.pdf"
+		} else if *separator != "" || *maxTokens > 0 || *rule != "" {
+			tmpl.tmpl = "{index}.pdf"
+		}
+	}
+	if err = tmpl.check(); err != nil {
+		argError(err)
+	}
+
+	//check passwords
+	var enc *encryption
+	if *password != "" || *passwordTmpl != "" || *passwordList != "" {
+		if enc, err = newEncryption(*encryptAlgo, *ownerPassword); err != nil {
+			argError(err)
+		}
+		if !*encryptMetadata {
+			if *encryptAlgo == encryptRC4 {
+				argError("-encrypt-metadata=false needs -encrypt aes128 or aes256")
+			}
+			enc.plainMetadata = true
+		}
+		enc.password = *password
+		enc.template = nameTemplate{tmpl: *passwordTmpl}
+		if err = enc.template.check("name", "match"); err != nil {
+			argError(err)
+		}
+		if *passwordRe != "" {
+			if enc.match, err = regexp.Compile(*passwordRe); err != nil {
+				argError("Invalid -password-re regexp:", err)
+			}
+		}
+		if *passwordList != "" {
+			if enc.list, err = loadPasswords(*passwordList); err != nil {
+				argError("Unable to read -passwords:", err)
+			}
+		}
+	} else if *passwordReport != "" || *passwordRe != "" {
+		argError("-password-re and -password-report need -password, -password-template or -passwords")
+	}
+
+	//check -pdfa
+	if *pdfa && enc != nil {
+		argError("-pdfa outputs can't be password protected")
+	}
+
+	//check -bookmark-level
+	if *bookmarkLevel < 1 {
+		argError("-bookmark-level must be at least 1")
+	}
+
+	//check -outline
+	if *outlineMode != "" && *outlineMode != outlineRanges && *outlineMode != outlineBookmarks {
+		argError("-outline must be ranges or bookmarks")
+	}
+
+	//check -page-numbers
+	var numberer *pageNumberer
+	if *pageNumbers != "" {
+		style, err := newTextStyle(*pageNumberFont, *pageNumberSize, *pageNumberMargin)
+		if err != nil {
+			argError("Invalid -page-number-font or -page-number-size:", err)
+		}
+		if numberer, err = newPageNumberer(*pageNumbers, *pageNumberMode, style, *pageNumberPos); err != nil {
+			argError(err)
+		}
+	}
+
+	//check -header and -footer
+	var headers *headerStamper
+	if *header != "" || *footer != "" {
+		style, err := newTextStyle(*headerFont, *headerSize, *headerMargin)
+		if err != nil {
+			argError("Invalid -header-font or -header-size:", err)
+		}
+		if headers, err = newHeaderStamper(*header, *footer, style, customVars); err != nil {
+			argError(err)
+		}
+	}
+
+	//check -qr
+	var qrCodes *qrStamper
+	if *qr {
+		if *qrJob == "" {
+			*qrJob = time.Now().Format("20060102-150405")
+		}
+		if qrCodes, err = newQRStamper(*qrJob, *qrPos, *qrSize, *qrMargin); err != nil {
+			argError(err)
+		}
+	} else if *qrManifest != "" {
+		argError("-qr-manifest needs -qr")
+	}
+
+	//check -id, -producer, -creator, -info and -xmp
+	if *metadataOpts.xmpFile != "" && *pdfa {
+		argError("-xmp can't be combined with -pdfa, which writes its own XMP metadata")
+	}
+	metadata := metadataOpts.metadata()
+
+	//check -trim-borders
+	if *trimBorders < 0 {
+		argError("-trim-borders must not be negative")
+	}
+	if *attachments != attachAll && *attachments != attachPages && *attachments != attachNone {
+		argError("-attachments must be all, pages or none")
+	}
+	if _, ok := pageLayouts[*pageLayout]; *pageLayout != "" && !ok {
+		argError("-page-layout must be single, one-column, two-column-left, two-column-right, two-page-left or two-page-right")
+	}
+	if _, ok := pageModes[*pageMode]; *pageMode != "" && !ok {
+		argError("-page-mode must be none, outlines, thumbnails, attachments, layers or full-screen")
+	}
+	if _, ok := openView(*openFit); *openFit != "" && !ok {
+		argError("-open-fit must be page, width, height, visible, actual or a positive percentage")
+	}
+
+	//check -shard
+	if *shard < 0 {
+		argError("-shard must not be negative")
+	}
+
+	//load preflight profile
+	var preflight *preflightProfile
+	if *preflightFile != "" {
+		if preflight, err = loadPreflight(*preflightFile); err != nil {
+			argError("Invalid -preflight:", err)
+		}
+	}
+
+	ow := &outputWriter{dir: *out, spreads: *splitSpreads, shard: *shard, encrypt: enc, checkUA: *checkUA, preflight: preflight, headers: headers, qr: qrCodes, remote: remote, metadata: metadata}
+	ow.fromInput = *attachments != attachNone || *pageLayout != "" || *pageMode != "" || *openFit != "" || *outlineMode != ""
+	if *importAnnotations != "" {
+		annots, err := readXFDF(*importAnnotations)
+		if err == nil {
+			ow.annotations, err = newAnnotationImporter(annots)
+		}
+		if err != nil {
+			argError("Invalid -import-annotations:", err)
+		}
+	}
+	if *pdfa {
+		if ow.pdfa, err = newPDFAConverter(); err != nil {
+			fatal("Unable to create PDF/A output intent:", err)
+		}
+	}
+
+	//create password report
+	if *passwordReport != "" {
+		if err = enc.createReport(*passwordReport); err != nil {
+			exitError(exitWrite, "Unable to create password report:", err)
+		}
+	}
+
+	//open job report
+	if *jobReportFile != "" {
+		if ow.report, err = createJobReport(*jobReportFile); err != nil {
+			exitError(exitWrite, "Unable to open job report:", err)
+		}
+	}
+
+	//connect to the event broker, publishing job.finished with the exit status on failure
+	if *events != "" {
+		if *job == "" {
+			*job = time.Now().Format("20060102-150405")
+		}
+		if ow.events, err = newEventPublisher(*events, *job, *in); err != nil {
+			fatal("Unable to connect to -events:", err)
+		}
+		atExit(func() { ow.events.finish(exitStatus) })
+	}
+
+	//create QR code manifest
+	if *qrManifest != "" {
+		if err = qrCodes.createManifest(*qrManifest); err != nil {
+			exitError(exitWrite, "Unable to create QR code manifest:", err)
+		}
+	}
+
+	//create archive
+	if *zipOut != "" {
+		if ow.archive, err = createZip(*zipOut, *zipPassword); err != nil {
+			exitError(exitWrite, err)
+		}
+	}
+
+	//defer finish archive and report
+	defer func() {
+		if err := ow.close(); err != nil {
+			exitError(writeStatus(err), "Unable to finish output:", err)
+		}
+		if ow.failed > 0 {
+			exitError(exitPartial, ow.failed, "outputs failed preflight and were skipped")
+		}
+		if ow.events != nil {
+			ow.events.finish(0)
+		}
+	}()
+
+	//rotate and trim first, so stamps and page numbers are placed on the pages as shown
+	if *autoRotateFlag {
+		ow.transforms = append(ow.transforms, autoRotate)
+	}
+	if *trimBorders > 0 {
+		ow.transforms = append(ow.transforms, newBorderTrimmer(*trimBorders).apply)
+	}
+
+	//load overlays
+	if *underlayPDF != "" {
+		o, err := loadOverlay(*underlayPDF, true, *stampPages)
+		if err != nil {
+			fatalInput(*underlayPDF, err)
+		}
+		ow.transforms = append(ow.transforms, o.apply)
+	}
+	if *overlayPDF != "" {
+		o, err := loadOverlay(*overlayPDF, false, *stampPages)
+		if err != nil {
+			fatalInput(*overlayPDF, err)
+		}
+		ow.transforms = append(ow.transforms, o.apply)
+	}
+
+	//number after stamping, so overlays don't cover the numbers
+	if numberer != nil {
+		ow.transforms = append(ow.transforms, numberer.apply)
+	}
+
+	//strip images and convert to grayscale after stamping, so the stamps are converted too
+	if *stripImages {
+		ow.transforms = append(ow.transforms, newImageStripper().apply)
+	}
+	if *grayscale {
+		ow.transforms = append(ow.transforms, newGrayscale().apply)
+	}
+
+	//subset last, so the glyphs of stamps are kept
+	if *subsetFonts {
+		ow.transforms = append(ow.transforms, newFontSubsetter().apply)
+	}
+
+	//open file, unless a pipeline merged its inputs into one
+	f := pipelineInput
+	if f == nil {
+		if f, err = openInput(*in); err != nil {
+			fatalInput(*in, err)
+		}
 	}
 
 	//defer close file
 	defer func() {
 		if err = f.Close(); err != nil {
-			log.Fatalln("Unable to close input PDF:", err)
+			fatal("Unable to close input PDF:", err)
 		}
 	}()
 
-	//create PDF reader
-	pdf, err := model.NewPdfReader(f)
+	//select revision, reading the file only up to its end
+	var rs io.ReadSeeker = f
+	if *revisionSpec != "latest" {
+		revs, err := findRevisions(f, f.size)
+		if err != nil {
+			fatalInput(*in, err)
+		}
+		rev, err := selectRevision(revs, *revisionSpec)
+		if err != nil {
+			fatal("Invalid -revision:", err)
+		}
+		rs = io.NewSectionReader(f, 0, rev.end)
+	}
+
+	//a single one-page part doesn't need the whole document loaded
+	if len(parts) == 1 && *re == "" && !*splitBookmarks && *dupes == "" && !ow.needsFullWrite() {
+		done, err := writeSinglePagePart(rs, parts[0], *out, warn, *metadataOpts.inheritID)
+		var pe *partError
+		if errors.As(err, &pe) {
+			fatal("Invalid -part:", err)
+		}
+		if err != nil {
+			exitError(writeStatus(err), "Unable to write part:", err)
+		}
+		if done {
+			startProgress(1)
+			addProgress(1)
+			return
+		}
+		if _, err = rs.Seek(0, io.SeekStart); err != nil {
+			fatal("Unable to rewind input PDF:", err)
+		}
+	}
+
+	//create PDF reader, decrypting inputs without a user password, on all CPUs as the split reads all of it
+	pdf, err := loadPDF(rs, warn, runtime.NumCPU())
 	if err != nil {
-		log.Fatalln("Unable to create PDF reader:", err)
+		fatalInput(*in, err)
+	}
+	checkMemory("loading the input")
+
+	//metadata templates, the job report and events refer to the input pages
+	ow.setInput(*in, pdf)
+	if ow.events != nil {
+		ow.events.start(len(pdf.PageList))
+	}
+
+	//outputs open as the input does
+	if ow.viewer, err = newViewerSettings(pdf, *pageLayout, *pageMode, *openFit); err != nil {
+		fatal("Unable to read the initial view:", err)
+	}
+
+	//outputs get the attachments of the input by -attachments
+	if *attachments != attachNone {
+		if ow.attachments, err = newAttachmentPolicy(pdf, *attachments); err != nil {
+			fatal("Unable to read attachments:", err)
+		}
+	}
+
+	//outputs keep the identifier of the input
+	if *metadataOpts.inheritID {
+		if trailer, err := pdf.GetTrailer(); err == nil {
+			ow.originalID = firstID(trailer)
+		}
+	}
+
+	//enforce preflight input rules
+	if preflight != nil && preflight.applies(preflightInputs) {
+		if err = preflight.enforce(preflightInputs, *in, pdf); err != nil {
+			fatal(err)
+		}
+	}
+
+	//plan output outlines
+	if *outlineMode != "" {
+		if ow.outline, err = newOutlinePlan(*outlineMode, pdf); err != nil {
+			fatal("Unable to read bookmarks:", err)
+		}
+	}
+
+	//continued page numbers count up to the input page count
+	if numberer != nil {
+		numberer.total = len(pdf.PageList)
+	}
+
+	//hash pages for duplicate detection
+	var hashes []string
+	if *dupes != "" {
+		hashes = pageHashes(pdf.PageList)
+		if *dupes == dupesReport {
+			reportDuplicates(hashes)
+		}
+	}
+
+	//pages seen in earlier inputs of the batch, with the index updated once the split succeeds
+	var seen []string
+	if *dupesIndexFile != "" {
+		index, err := readDupesIndex(*dupesIndexFile)
+		if err != nil {
+			fatal("Unable to read -dupes-index:", err)
+		}
+		seen = index.match(hashes)
+		if *dupes == dupesReport {
+			for i, where := range seen {
+				if where != "" {
+					warning("Page %d is a duplicate of %s", i+1, where)
+				}
+			}
+		}
+		defer func() {
+			if err := index.add(*in, hashes); err != nil {
+				fatal("Unable to update -dupes-index:", err)
+			}
+		}()
+	}
+
+	info := docInfo(pdf)
+
+	if *splitBookmarks {
+		marks, err := bookmarks(pdf, *bookmarkLevel)
+		if err != nil {
+			fatal("Unable to read bookmarks:", err)
+		}
+		if len(marks) == 0 {
+			fatal("No bookmarks pointing to pages found")
+		}
+
+		//name each part from its bookmark
+		var pts []part
+		used := map[string]int{}
+		for i, bp := range bookmarkParts(marks, len(pdf.PageList)) {
+			vars := docVars(info)
+			bookmarkVars(vars, bp.bookmark)
+			vars["index"] = strconv.Itoa(i + 1)
+			vars["page"] = strconv.Itoa(bp.pages[0])
+			pts = append(pts, part{name: uniqueName(used, tmpl.expand(vars)), pages: bp.pages, vars: vars})
+		}
+
+		writeParts(pdf, pts, ow, hashes, seen, *dupes == dupesDrop)
+		return
+	}
+
+	if *field != "" {
+		fps := fieldParts(pdf, *field)
+		if len(fps) == 0 {
+			fatalf("No pages with a value of form field %q found", *field)
+		}
+
+		//name each part from its field value
+		var pts []part
+		used := map[string]int{}
+		for i, fp := range fps {
+			vars := docVars(info)
+			vars["value"] = fp.value
+			vars["index"] = strconv.Itoa(i + 1)
+			vars["page"] = strconv.Itoa(fp.pages[0])
+			pts = append(pts, part{name: uniqueName(used, tmpl.expand(vars)), pages: fp.pages, vars: vars})
+		}
+
+		writeParts(pdf, pts, ow, hashes, seen, *dupes == dupesDrop)
+		return
+	}
+
+	if *separator != "" {
+		sps, err := separatorParts(pdf, *separator, *dropSeparators, warn)
+		if err != nil {
+			fatal(err)
+		}
+		if len(sps) == 0 {
+			fatal("No pages left between separator sheets")
+		}
+
+		var pts []part
+		used := map[string]int{}
+		for i, pages := range sps {
+			vars := docVars(info)
+			vars["index"] = strconv.Itoa(i + 1)
+			vars["page"] = strconv.Itoa(pages[0])
+			pts = append(pts, part{name: uniqueName(used, tmpl.expand(vars)), pages: pages, vars: vars})
+		}
+
+		writeParts(pdf, pts, ow, hashes, seen, *dupes == dupesDrop)
+		return
+	}
+
+	if *maxTokens > 0 {
+		tps, err := tokenParts(pdf, *maxTokens, warn)
+		if err != nil {
+			fatal(err)
+		}
+
+		var pts []part
+		used := map[string]int{}
+		for i, pages := range tps {
+			vars := docVars(info)
+			vars["index"] = strconv.Itoa(i + 1)
+			vars["page"] = strconv.Itoa(pages[0])
+			pts = append(pts, part{name: uniqueName(used, tmpl.expand(vars)), pages: pages, vars: vars})
+		}
+
+		writeParts(pdf, pts, ow, hashes, seen, *dupes == dupesDrop)
+		return
+	}
+
+	if splitRule != nil {
+		rps, err := ruleParts(pdf, splitRule, warn)
+		if err != nil {
+			fatal(err)
+		}
+
+		var pts []part
+		used := map[string]int{}
+		for i, pages := range rps {
+			vars := docVars(info)
+			vars["index"] = strconv.Itoa(i + 1)
+			vars["page"] = strconv.Itoa(pages[0])
+			pts = append(pts, part{name: uniqueName(used, tmpl.expand(vars)), pages: pages, vars: vars})
+		}
+
+		writeParts(pdf, pts, ow, hashes, seen, *dupes == dupesDrop)
+		return
+	}
+
+	if *classifier != "" {
+		cps, err := classifierParts(pdf, newPageClassifier(*classifier), warn)
+		if err != nil {
+			fatal(err)
+		}
+
+		//name each part as suggested, or by its number if the classifier didn't
+		var pts []part
+		used := map[string]int{}
+		for i, cp := range cps {
+			vars := docVars(info)
+			vars["value"] = cp.name
+			vars["index"] = strconv.Itoa(i + 1)
+			if cp.name == "" {
+				vars["value"] = vars["index"]
+			}
+			vars["page"] = strconv.Itoa(cp.pages[0])
+			pts = append(pts, part{name: uniqueName(used, tmpl.expand(vars)), pages: cp.pages, vars: vars})
+		}
+
+		writeParts(pdf, pts, ow, hashes, seen, *dupes == dupesDrop)
+		return
+	}
+
+	if len(parts) > 0 {
+		numPages, err := pdf.GetNumPages()
+		if err != nil {
+			fatal("Unable to get page count:", err)
+		}
+
+		//parse all parts before writing anything
+		pts := make([]part, 0, len(parts))
+		for i, def := range parts {
+			pt, err := parsePart(def, numPages)
+			if err != nil {
+				fatal("Invalid -part:", err)
+			}
+			pt.vars = docVars(info)
+			pt.vars["index"] = strconv.Itoa(i + 1)
+			pt.vars["page"] = strconv.Itoa(pt.pages[0])
+			pts = append(pts, pt)
+		}
+
+		writeParts(pdf, pts, ow, hashes, seen, *dupes == dupesDrop)
+		return
 	}
 
 	var count int
 
 	//loop through each page
+	startProgress(len(pdf.PageList))
 	for i, p := range pdf.PageList {
-		ex, err := extractor.New(p)
-		if err != nil {
-			log.Fatalf("Unable to create PDF page %d extractor: %v\n", i, err)
+		if *dupes == dupesDrop && i > 0 && isDuplicate(hashes[i], hashes[i-1]) {
+			logInfof("Skipping page %d, duplicate of page %d\n", i+1, i)
+			addProgress(1)
+			continue
+		}
+		if *dupes == dupesDrop && seen != nil && seen[i] != "" {
+			logInfof("Skipping page %d, duplicate of %s\n", i+1, seen[i])
+			addProgress(1)
+			continue
 		}
 
 		//extract text
-		text, err := ex.ExtractText()
-		if err != nil {
-			log.Fatalf("Unable to extract PDF page %d text: %v\n", i, err)
+		text, err := pageText(p)
+		if err != nil && warn != nil {
+			obj, _ := p.GetContainingPdfObject().(*core.PdfIndirectObject)
+			var objNum int64
+			if obj != nil {
+				objNum = obj.ObjectNumber
+			}
+			warn(core.Warning{ObjectNumber: objNum, Offset: -1, Problem: err.Error(), Action: fmt.Sprintf("page %d skipped", i+1)})
+			addProgress(1)
+			continue
+		} else if err != nil {
+			fatalf("Unable to extract PDF page %d text: %v\n", i, err)
 		}
 
 		if *debug {
-			fmt.Printf("Page %d text:\n", i+1)
-			fmt.Println(text)
+			fmt.Fprintf(textOut, "Page %d text:\n", i+1)
+			fmt.Fprintln(textOut, text)
 		}
 
 		//find regexp
 		matches := matchRegexp.FindStringSubmatch(text)
 		if len(matches) != 2 {
-			log.Fatalln("Unable to locate identifier in PDF text")
+			fatal("Unable to locate identifier in PDF text")
 		}
 
-		username := matches[1]
+		vars := docVars(info)
+		vars["value"] = matches[1]
+		vars["index"] = strconv.Itoa(count + 1)
+		vars["page"] = strconv.Itoa(i + 1)
 
-		//create PDF writer for page
-		w := model.NewPdfWriter()
-		if err = w.AddPage(p); err != nil {
-			log.Fatalln("Unable to add page to writer:", err)
+		//write PDF page
+		if err = ow.write(tmpl.expand(vars), []*model.PdfPage{p}, vars); err != nil {
+			exitError(writeStatus(err), err)
 		}
 
-		fn := path.Join(*out, fmt.Sprintf("%s.pdf", username))
+		count++
+		addProgress(1)
+	}
 
-		//open output file
-		wf, err := os.Create(fn)
-		if err != nil {
-			log.Fatalln("Unable to open new PDF file", fn, "for writing:", err)
-		}
+	logInfo("Wrote", count, "pages.")
+}
 
-		log.Println("Writing", fn)
+// writeParts writes each part to its own PDF.
+// If drop is set, pages with the same hash as the previous page in the part are skipped, and so
+// are pages seen in an earlier input, if seen is set.
+func writeParts(pdf *model.PdfReader, parts []part, ow *outputWriter, hashes, seen []string, drop bool) {
+	total := 0
+	for _, pt := range parts {
+		total += len(pt.pages)
+	}
+	startProgress(total)
 
-		//write PDF page
-		if err = w.Write(wf); err != nil {
-			log.Fatalf("Unable to write PDF file %s: %v\n", fn, err)
+	written := 0
+	for _, pt := range parts {
+		pages := make([]*model.PdfPage, 0, len(pt.pages))
+		for i, n := range pt.pages {
+			if drop && i > 0 && isDuplicate(hashes[n-1], hashes[pt.pages[i-1]-1]) {
+				logInfof("Skipping page %d in %s, duplicate of page %d\n", n, pt.name, pt.pages[i-1])
+				continue
+			}
+			if drop && seen != nil && seen[n-1] != "" {
+				logInfof("Skipping page %d in %s, duplicate of %s\n", n, pt.name, seen[n-1])
+				continue
+			}
+			pages = append(pages, pdf.PageList[n-1])
+		}
+		if len(pages) == 0 {
+			logInfof("Skipping %s, all its pages are duplicates of earlier inputs\n", pt.name)
+			addProgress(len(pt.pages))
+			continue
 		}
 
-		count = i + 1
+		if err := ow.write(pt.name, pages, pt.vars); err != nil {
+			exitError(writeStatus(err), err)
+		}
+		written++
+		addProgress(len(pt.pages))
 	}
 
-	log.Println("Wrote", count, "pages.")
+	logInfo("Wrote", written, "parts.")
 }
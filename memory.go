@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// memoryBudget is the -max-memory budget in bytes, 0 for none
+var memoryBudget int64
+
+// setMemoryBudget sets the -max-memory budget to mb megabytes. The Go runtime collects garbage
+// more often as its memory nears the budget, and checkMemory fails the command once the peak
+// resident set size is over it, before the OS kills the process for running out of memory.
+func setMemoryBudget(mb int) {
+	memoryBudget = int64(mb) << 20
+	debug.SetMemoryLimit(memoryBudget)
+	if peakRSS() == 0 {
+		warning("Peak memory is unknown on this OS, so -max-memory only limits the Go heap")
+	}
+}
+
+// checkMemory fails the command if the peak resident set size is over -max-memory. after
+// describes the step just done, e.g. "loading the input".
+func checkMemory(after string) {
+	if rss := peakRSS(); memoryBudget > 0 && rss > memoryBudget {
+		exitError(exitMemory, fmt.Sprintf("Peak memory of %.0f MB after %s is over -max-memory %d MB", float64(rss)/(1<<20), after, memoryBudget>>20))
+	}
+}
+
+// peakMemoryMB returns the peak resident set size of the process so far in MB, or nil if it is
+// unknown on this OS
+func peakMemoryMB() *float64 {
+	rss := peakRSS()
+	if rss == 0 {
+		return nil
+	}
+	mb := float64(rss) / (1 << 20)
+	return &mb
+}
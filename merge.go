@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// runMerge writes the pages of several PDFs, in order, to a single PDF
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := outputFlags(fs)
+	out := fs.String("out", "", "output PDF")
+	collate := fs.Bool("collate", false, "interleave a fronts PDF with a backs PDF scanned in reverse order (A1, B_last, A2, B_last-1, ...)")
+	toc := fs.String("toc", "", "add contents pages linking to each input, with a line from this `template`, e.g. \"{index}. {title}\"; variables are the document information ones plus {name}, {index}, {page} and {pages}")
+	tocHeading := fs.String("toc-heading", "Contents", "heading of the -toc pages (empty for none)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter merge: -out merged.pdf [flags] input.pdf...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("merge", output)
+	defer finishOutput()
+
+	if *out == "" || fs.NArg() == 0 {
+		usage(fs)
+	}
+
+	if *collate && fs.NArg() != 2 {
+		argError("-collate needs exactly two inputs: fronts.pdf backs.pdf")
+	}
+
+	tocTmpl := nameTemplate{tmpl: *toc}
+	if *toc != "" {
+		if *collate {
+			argError("-toc can't be combined with -collate, which interleaves the inputs")
+		}
+		if err := tocTmpl.check("name", "pages"); err != nil {
+			argError(err)
+		}
+	}
+
+	inputs := make([]*model.PdfReader, 0, fs.NArg())
+	for _, fn := range fs.Args() {
+		pdf, f, err := openPDF(fn)
+		if err != nil {
+			fatalInput(fn, err)
+		}
+		defer f.Close()
+		inputs = append(inputs, pdf)
+	}
+
+	var pages []*model.PdfPage
+	if *collate {
+		var err error
+		if pages, err = collatePages(inputs[0].PageList, inputs[1].PageList); err != nil {
+			fatal("Unable to collate:", err)
+		}
+	} else {
+		for _, pdf := range inputs {
+			pages = append(pages, pdf.PageList...)
+		}
+	}
+
+	//the contents pages come first, so the page numbers they list depend on their own count
+	if *toc != "" {
+		layout := newTOCLayout(*tocHeading, pages)
+		entries := tocEntries(tocTmpl, fs.Args(), inputs, layout.pageCount(len(inputs)))
+		tocPages, err := layout.tocPages(entries, pages)
+		if err != nil {
+			fatal("Unable to create contents pages:", err)
+		}
+		pages = append(tocPages, pages...)
+	}
+
+	logInfo("Writing", *out)
+
+	if _, err := writePDF(*out, pdfPart{pages: pages}); err != nil {
+		exitError(writeStatus(err), err)
+	}
+
+	addResult(fileResult{File: *out, Pages: len(pages)})
+	logInfo("Wrote", len(pages), "pages.")
+}
+
+// collatePages interleaves fronts with backs, where backs were scanned in reverse order.
+// This is the fix-up for duplex documents scanned with a single-sided feeder.
+func collatePages(fronts, backs []*model.PdfPage) ([]*model.PdfPage, error) {
+	if len(fronts) != len(backs) {
+		return nil, fmt.Errorf("fronts have %d pages but backs have %d", len(fronts), len(backs))
+	}
+
+	pages := make([]*model.PdfPage, 0, len(fronts)+len(backs))
+	for i := range fronts {
+		pages = append(pages, fronts[i], backs[len(backs)-1-i])
+	}
+
+	return pages, nil
+}
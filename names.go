@@ -0,0 +1,370 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// file name sanitization policies for -sanitize
+const (
+	sanitizePOSIX   = "posix"   //only "/" and NUL are replaced
+	sanitizeWindows = "windows" //also safe for SMB shares and SharePoint
+	sanitizeS3      = "s3"      //only the characters S3 documents as safe for object keys
+)
+
+// name case rules for -case
+const (
+	caseLower = "lower"
+	caseUpper = "upper"
+)
+
+// nameOptions controls how values taken from the PDF (regexp matches, bookmark titles)
+// are turned into output file names
+type nameOptions struct {
+	translit bool           //transliterate to ASCII where a mapping is known
+	slug     bool           //lower case, with runs of other characters replaced by "-"
+	nameCase string         //caseLower, caseUpper or "" to keep
+	policy   string         //sanitize* policy
+	unsafe   *regexp.Regexp //optional extra characters to replace
+	replace  string         //replacement for each run of unsafe characters
+	maxLen   int            //maximum length in bytes, 0 for no limit
+}
+
+// nameFlags are the flags controlling output names
+type nameFlags struct {
+	translit   *bool
+	slug       *bool
+	sanitize   *string
+	sanitizeRe *string
+	replace    *string
+	maxName    *int
+	nameCase   *string
+}
+
+// addNameFlags adds the flags controlling output names to fs
+func addNameFlags(fs *flag.FlagSet) *nameFlags {
+	return &nameFlags{
+		translit:   fs.Bool("translit", false, "transliterate accented, Greek and Cyrillic letters in output names to ASCII"),
+		slug:       fs.Bool("slug", false, "lower case output names and replace spaces and punctuation with \"-\""),
+		sanitize:   fs.String("sanitize", sanitizePOSIX, "characters replaced in output names: \"posix\" (\"/\" only), \"windows\" (also Windows/SharePoint reserved characters and names) or \"s3\" (all but S3 safe key characters)"),
+		sanitizeRe: fs.String("sanitize-re", "", "regular expression for further characters replaced in output names"),
+		replace:    fs.String("replace", "_", "replacement for each run of characters removed from output names"),
+		maxName:    fs.Int("max-name", 200, "maximum output name length in bytes, without extension (0 for no limit)"),
+		nameCase:   fs.String("case", "", "output name case: \"lower\" or \"upper\""),
+	}
+}
+
+// options returns the name options set by the flags, exiting if they are invalid
+func (f *nameFlags) options() nameOptions {
+	if *f.sanitize != sanitizePOSIX && *f.sanitize != sanitizeWindows && *f.sanitize != sanitizeS3 {
+		argError("-sanitize must be posix, windows or s3")
+	}
+	var unsafeRegexp *regexp.Regexp
+	if *f.sanitizeRe != "" {
+		var err error
+		if unsafeRegexp, err = regexp.Compile(*f.sanitizeRe); err != nil {
+			argError("Invalid -sanitize-re regexp:", err)
+		}
+	}
+	if strings.ContainsAny(*f.replace, "/\x00") {
+		argError("-replace must not contain \"/\"")
+	}
+	if *f.nameCase != "" && *f.nameCase != caseLower && *f.nameCase != caseUpper {
+		argError("-case must be lower or upper")
+	}
+
+	return nameOptions{
+		translit: *f.translit,
+		slug:     *f.slug,
+		nameCase: *f.nameCase,
+		policy:   *f.sanitize,
+		unsafe:   unsafeRegexp,
+		replace:  *f.replace,
+		maxLen:   *f.maxName,
+	}
+}
+
+// windowsReserved are device names Windows won't create files for, with any extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// fileName returns the file name, without extension, for value
+func (o nameOptions) fileName(value string) string {
+	if o.translit {
+		value = transliterate(value)
+	}
+	if o.slug {
+		value = slugify(value)
+	}
+
+	switch o.nameCase {
+	case caseLower:
+		value = strings.ToLower(value)
+	case caseUpper:
+		value = strings.ToUpper(value)
+	}
+
+	//never let a value escape the output directory, whatever the policy
+	value = replaceRuns(value, o.replace, func(r rune) bool {
+		return r == '/' || r == 0 || !o.safe(r)
+	})
+	if o.unsafe != nil {
+		value = o.unsafe.ReplaceAllLiteralString(value, o.replace)
+	}
+
+	if o.maxLen > 0 {
+		value = truncate(value, o.maxLen)
+	}
+
+	//an empty -replace can't make a name valid
+	prefix := o.replace
+	if prefix == "" {
+		prefix = "_"
+	}
+
+	if o.policy == sanitizeWindows {
+		value = strings.TrimRight(value, ". ")
+		base := value
+		if i := strings.Index(base, "."); i >= 0 {
+			base = base[:i]
+		}
+		if windowsReserved[strings.ToUpper(strings.TrimSpace(base))] {
+			value = prefix + value
+		}
+	}
+
+	if value == "" || value == "." || value == ".." {
+		value = prefix + value
+	}
+
+	return value
+}
+
+// safe reports whether the policy allows r in a file name
+func (o nameOptions) safe(r rune) bool {
+	switch o.policy {
+	case sanitizeWindows:
+		return r >= 0x20 && !strings.ContainsRune(`<>:"\|?*`, r)
+	case sanitizeS3:
+		return r < 0x80 && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("!-_.*'() ", r))
+	}
+	return true
+}
+
+// replaceRuns replaces each run of runes for which unsafe returns true with replace
+func replaceRuns(s, replace string, unsafe func(r rune) bool) string {
+	var b strings.Builder
+	run := false
+	for _, r := range s {
+		if unsafe(r) {
+			if !run {
+				b.WriteString(replace)
+			}
+			run = true
+			continue
+		}
+		run = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// decodeTextString decodes a PDF text string (7.9.2.2), such as a bookmark title.
+// Text strings are UTF-16BE with a byte order mark, UTF-8 with a byte order mark (PDF 2.0),
+// or PDFDocEncoding.
+func decodeTextString(s string) string {
+	b := []byte(s)
+
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		b = b[2:]
+		units := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	if len(b) >= 3 && b[0] == 0xef && b[1] == 0xbb && b[2] == 0xbf && utf8.Valid(b[3:]) {
+		return string(b[3:])
+	}
+
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		if r, ok := pdfDocEncoding[c]; ok {
+			runes[i] = r
+		} else {
+			runes[i] = rune(c)
+		}
+	}
+	return string(runes)
+}
+
+// encodeTextString encodes s as a PDF text string: printable ASCII as is, anything else as
+// UTF-16BE with a byte order mark
+func encodeTextString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r < 0x20 || r >= 0x7f {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return s
+	}
+
+	b := []byte{0xfe, 0xff}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return string(b)
+}
+
+// pdfDocEncoding maps the PDFDocEncoding bytes that differ from ISO Latin-1 (Annex D.2)
+var pdfDocEncoding = map[byte]rune{
+	0x18: '˘', 0x19: 'ˇ', 0x1a: 'ˆ', 0x1b: '˙', 0x1c: '˝', 0x1d: '˛', 0x1e: '˚', 0x1f: '˜',
+	0x80: '•', 0x81: '†', 0x82: '‡', 0x83: '…', 0x84: '—', 0x85: '–', 0x86: 'ƒ', 0x87: '⁄',
+	0x88: '‹', 0x89: '›', 0x8a: '−', 0x8b: '‰', 0x8c: '„', 0x8d: '“', 0x8e: '”', 0x8f: '‘',
+	0x90: '’', 0x91: '‚', 0x92: '™', 0x93: 'ﬁ', 0x94: 'ﬂ', 0x95: 'Ł', 0x96: 'Œ', 0x97: 'Š',
+	0x98: 'Ÿ', 0x99: 'Ž', 0x9a: 'ı', 0x9b: 'ł', 0x9c: 'œ', 0x9d: 'š', 0x9e: 'ž', 0xa0: '€',
+}
+
+// transliterate replaces accented Latin, Greek and Cyrillic letters and common punctuation
+// with ASCII. Characters without a mapping, e.g. CJK, are kept.
+func transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+			continue
+		}
+		if t, ok := translitRune(r); ok {
+			b.WriteString(t)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// translitRune returns the ASCII for r from translitTable or, for letters with diacritics it
+// doesn't list, such as polytonic Greek and Vietnamese, from the entry of their base letter
+func translitRune(r rune) (string, bool) {
+	if t, ok := translitTable[r]; ok {
+		return t, true
+	}
+
+	decomposed := norm.NFD.String(string(r))
+	base, size := utf8.DecodeRuneInString(decomposed)
+	if size == len(decomposed) {
+		return "", false
+	}
+	for _, mark := range decomposed[size:] {
+		if !unicode.Is(unicode.Mn, mark) {
+			return "", false
+		}
+	}
+	if base < utf8.RuneSelf {
+		return string(base), true
+	}
+	t, ok := translitTable[base]
+	return t, ok
+}
+
+// slugify lower cases s and replaces each run of characters other than letters and
+// digits with a single "-"
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+var translitTable = map[rune]string{
+	//Latin-1 Supplement
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "Ae", 'Å': "A", 'Æ': "AE", 'Ç': "C",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I",
+	'Ð': "D", 'Ñ': "N", 'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "Oe", 'Ø': "O",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "Ue", 'Ý': "Y", 'Þ': "Th", 'ß': "ss",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "ae", 'å': "a", 'æ': "ae", 'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ð': "d", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "oe", 'ø': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "ue", 'ý': "y", 'þ': "th", 'ÿ': "y",
+	'×': "x", '÷': "-", '«': "\"", '»': "\"", '°': "deg", '€': "EUR", '£': "GBP",
+
+	//Latin Extended-A
+	'Ā': "A", 'ā': "a", 'Ă': "A", 'ă': "a", 'Ą': "A", 'ą': "a", 'Ć': "C", 'ć': "c",
+	'Ĉ': "C", 'ĉ': "c", 'Ċ': "C", 'ċ': "c", 'Č': "C", 'č': "c", 'Ď': "D", 'ď': "d",
+	'Đ': "D", 'đ': "d", 'Ē': "E", 'ē': "e", 'Ĕ': "E", 'ĕ': "e", 'Ė': "E", 'ė': "e",
+	'Ę': "E", 'ę': "e", 'Ě': "E", 'ě': "e", 'Ĝ': "G", 'ĝ': "g", 'Ğ': "G", 'ğ': "g",
+	'Ġ': "G", 'ġ': "g", 'Ģ': "G", 'ģ': "g", 'Ĥ': "H", 'ĥ': "h", 'Ħ': "H", 'ħ': "h",
+	'Ĩ': "I", 'ĩ': "i", 'Ī': "I", 'ī': "i", 'Ĭ': "I", 'ĭ': "i", 'Į': "I", 'į': "i",
+	'İ': "I", 'ı': "i", 'Ĳ': "IJ", 'ĳ': "ij", 'Ĵ': "J", 'ĵ': "j", 'Ķ': "K", 'ķ': "k",
+	'Ĺ': "L", 'ĺ': "l", 'Ļ': "L", 'ļ': "l", 'Ľ': "L", 'ľ': "l", 'Ŀ': "L", 'ŀ': "l",
+	'Ł': "L", 'ł': "l", 'Ń': "N", 'ń': "n", 'Ņ': "N", 'ņ': "n", 'Ň': "N", 'ň': "n",
+	'Ō': "O", 'ō': "o", 'Ŏ': "O", 'ŏ': "o", 'Ő': "O", 'ő': "o", 'Œ': "OE", 'œ': "oe",
+	'Ŕ': "R", 'ŕ': "r", 'Ŗ': "R", 'ŗ': "r", 'Ř': "R", 'ř': "r", 'Ś': "S", 'ś': "s",
+	'Ŝ': "S", 'ŝ': "s", 'Ş': "S", 'ş': "s", 'Š': "S", 'š': "s", 'Ţ': "T", 'ţ': "t",
+	'Ť': "T", 'ť': "t", 'Ŧ': "T", 'ŧ': "t", 'Ũ': "U", 'ũ': "u", 'Ū': "U", 'ū': "u",
+	'Ŭ': "U", 'ŭ': "u", 'Ů': "U", 'ů': "u", 'Ű': "U", 'ű': "u", 'Ų': "U", 'ų': "u",
+	'Ŵ': "W", 'ŵ': "w", 'Ŷ': "Y", 'ŷ': "y", 'Ÿ': "Y", 'Ź': "Z", 'ź': "z", 'Ż': "Z",
+	'ż': "z", 'Ž': "Z", 'ž': "z", 'Ș': "S", 'ș': "s", 'Ț': "T", 'ț': "t",
+
+	//Greek
+	'Α': "A", 'Β': "V", 'Γ': "G", 'Δ': "D", 'Ε': "E", 'Ζ': "Z", 'Η': "I", 'Θ': "Th",
+	'Ι': "I", 'Κ': "K", 'Λ': "L", 'Μ': "M", 'Ν': "N", 'Ξ': "X", 'Ο': "O", 'Π': "P",
+	'Ρ': "R", 'Σ': "S", 'Τ': "T", 'Υ': "Y", 'Φ': "F", 'Χ': "Ch", 'Ψ': "Ps", 'Ω': "O",
+	'Ά': "A", 'Έ': "E", 'Ή': "I", 'Ί': "I", 'Ό': "O", 'Ύ': "Y", 'Ώ': "O",
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o", 'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+
+	//Cyrillic
+	'А': "A", 'Б': "B", 'В': "V", 'Г': "G", 'Д': "D", 'Е': "E", 'Ё': "Yo", 'Ж': "Zh",
+	'З': "Z", 'И': "I", 'Й': "Y", 'К': "K", 'Л': "L", 'М': "M", 'Н': "N", 'О': "O",
+	'П': "P", 'Р': "R", 'С': "S", 'Т': "T", 'У': "U", 'Ф': "F", 'Х': "Kh", 'Ц': "Ts",
+	'Ч': "Ch", 'Ш': "Sh", 'Щ': "Shch", 'Ъ': "", 'Ы': "Y", 'Ь': "", 'Э': "E", 'Ю': "Yu",
+	'Я': "Ya", 'Є': "Ye", 'І': "I", 'Ї': "Yi", 'Ґ': "G", 'Ў': "U",
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+
+	//punctuation
+	'‘': "'", '’': "'", '‚': "'", '“': "\"", '”': "\"", '„': "\"", '–': "-", '—': "-",
+	'−': "-", '…': "...", '•': "-", ' ': " ", '™': "TM", '©': "(c)", '®': "(R)",
+}
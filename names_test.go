@@ -0,0 +1,122 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+// Test that -translit turns accented Latin, Greek and Cyrillic titles into ASCII, keeping
+// characters it has no mapping for, and that -slug applies after it.
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		title    string
+		slug     bool
+		expected string
+	}{
+		{"Straße über Äpfel", false, "Strasse ueber Aepfel"},
+		{"Œuvres complètes — Tome 1", false, "OEuvres completes - Tome 1"},
+		{"Łódź, Kraków", false, "Lodz, Krakow"},
+		{"Ελληνικά κείμενα", false, "Ellinika keimena"},
+		{"Москва: Глава 1", false, "Moskva: Glava 1"},
+		{"Щука и ёж", false, "Shchuka i yozh"},
+		{"Підсумок", false, "Pidsumok"},
+		{"東京 報告", false, "東京 報告"},
+		{"Глава 1: Введение", true, "glava-1-vvedenie"},
+		{"“Quoted” … – dashes", true, "quoted-dashes"},
+		{"Ἀθῆναι, Ὅμηρος", false, "Athinai, Omiros"},
+		{"Tiếng Việt", false, "Tieng Viet"},
+		{"Ǆ ﬁ", false, "Ǆ ﬁ"},
+	}
+	for _, test := range tests {
+		o := nameOptions{translit: true, slug: test.slug, policy: sanitizePOSIX, replace: "_"}
+		if got := o.fileName(test.title); got != test.expected {
+			t.Errorf("%q: %q, expected %q", test.title, got, test.expected)
+		}
+	}
+}
+
+// Test that bookmark titles decode from UTF-16BE, UTF-8 and PDFDocEncoding text strings.
+func TestDecodeTextString(t *testing.T) {
+	tests := []struct {
+		s, expected string
+	}{
+		{"Chapter 1", "Chapter 1"},
+		{"\xfe\xff\x04\x13\x04\x3b\x04\x30\x04\x32\x04\x30", "Глава"},
+		{"\xfe\xff\xd8\x3d\xde\x00", "😀"},
+		{"\xef\xbb\xbfΕλληνικά", "Ελληνικά"},
+		{"Caf\xe9 \x84 \x92", "Café — ™"},
+	}
+	for _, test := range tests {
+		if got := decodeTextString(test.s); got != test.expected {
+			t.Errorf("%q: %q, expected %q", test.s, got, test.expected)
+		}
+	}
+}
+
+// Test that each -sanitize policy replaces the characters and names it doesn't allow, and that
+// no policy lets a name contain "/" or be "." or "..".
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		policy, value, expected string
+	}{
+		{sanitizePOSIX, "Q1/Q2 report", "Q1_Q2 report"},
+		{sanitizePOSIX, "../../etc/passwd", ".._.._etc_passwd"},
+		{sanitizePOSIX, "a\x00b", "a_b"},
+		{sanitizePOSIX, `a<b>:"c"|d?*\e`, `a<b>:"c"|d?*\e`},
+		{sanitizePOSIX, "CON", "CON"},
+		{sanitizePOSIX, "trailing. ", "trailing. "},
+		{sanitizePOSIX, "..", "_.."},
+		{sanitizePOSIX, ".", "_."},
+		{sanitizePOSIX, "", "_"},
+		{sanitizeWindows, `a<b>:"c"|d?*\e`, "a_b_c_d_e"},
+		{sanitizeWindows, "tab\there", "tab_here"},
+		{sanitizeWindows, "CON", "_CON"},
+		{sanitizeWindows, "con.txt", "_con.txt"},
+		{sanitizeWindows, "Lpt9", "_Lpt9"},
+		{sanitizeWindows, "COM10", "COM10"},
+		{sanitizeWindows, "CONSOLE", "CONSOLE"},
+		{sanitizeWindows, "aux. ", "_aux"},
+		{sanitizeWindows, "trailing. . ", "trailing"},
+		{sanitizeWindows, "...", "_"},
+		{sanitizeWindows, "a/b", "a_b"},
+		{sanitizeWindows, "Café", "Café"},
+		{sanitizeS3, "Report (final)!.v2's", "Report (final)!.v2's"},
+		{sanitizeS3, "a&b$c@d=e;f:g+h,i?j", "a_b_c_d_e_f_g_h_i_j"},
+		{sanitizeS3, "a&&b", "a_b"},
+		{sanitizeS3, "Café 東京", "Caf_ _"},
+		{sanitizeS3, "a/b", "a_b"},
+		{sanitizeS3, "..", "_.."},
+	}
+	for _, test := range tests {
+		o := nameOptions{policy: test.policy, replace: "_"}
+		if got := o.fileName(test.value); got != test.expected {
+			t.Errorf("%s %q: %q, expected %q", test.policy, test.value, got, test.expected)
+		}
+	}
+}
+
+// Test that -max-name truncates without splitting characters, before Windows trims trailing
+// dots, and that -sanitize-re and -replace apply on top of the policy.
+func TestSanitizeOptions(t *testing.T) {
+	tests := []struct {
+		o               nameOptions
+		value, expected string
+	}{
+		{nameOptions{policy: sanitizePOSIX, replace: "_", maxLen: 5}, "abcdefgh", "abcde"},
+		{nameOptions{policy: sanitizePOSIX, replace: "_", maxLen: 5}, "abcdé", "abcd"},
+		{nameOptions{policy: sanitizeWindows, replace: "_", maxLen: 5}, "abcd.efgh", "abcd"},
+		{nameOptions{policy: sanitizePOSIX, replace: "-"}, "a/b", "a-b"},
+		{nameOptions{policy: sanitizePOSIX, replace: ""}, "a/b", "ab"},
+		{nameOptions{policy: sanitizePOSIX, replace: ""}, "//", "_"},
+		{nameOptions{policy: sanitizePOSIX, replace: ""}, "..", "_.."},
+		{nameOptions{policy: sanitizeWindows, replace: ""}, "nul.pdf", "_nul.pdf"},
+		{nameOptions{policy: sanitizePOSIX, replace: "_", unsafe: regexp.MustCompile(`[#%]+`)}, "50% #1", "50_ _1"},
+		{nameOptions{policy: sanitizePOSIX, replace: "_", nameCase: caseUpper}, "con", "CON"},
+		{nameOptions{policy: sanitizeWindows, replace: "_", nameCase: caseUpper}, "con", "_CON"},
+	}
+	for _, test := range tests {
+		if got := test.o.fileName(test.value); got != test.expected {
+			t.Errorf("%+v %q: %q, expected %q", test.o, test.value, got, test.expected)
+		}
+	}
+}
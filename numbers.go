@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+	"github.com/unidoc/unidoc/pdf/model/fonts"
+)
+
+// page numbering modes for -page-number-mode
+const (
+	numberPerPart  = "part"     //each output counts from 1
+	numberContinue = "continue" //outputs continue the count of the one before
+)
+
+// standardFont is one of the standard Type 1 fonts, which viewers have without embedding
+type standardFont interface {
+	glyphFont
+	ToPdfObject() core.PdfObject
+}
+
+// standardFonts are the text fonts of the standard 14, by -page-number-font name
+var standardFonts = map[string]func() standardFont{
+	"Helvetica":             func() standardFont { return fonts.NewFontHelvetica() },
+	"Helvetica-Bold":        func() standardFont { return fonts.NewFontHelveticaBold() },
+	"Helvetica-Oblique":     func() standardFont { return fonts.NewFontHelveticaOblique() },
+	"Helvetica-BoldOblique": func() standardFont { return fonts.NewFontHelveticaBoldOblique() },
+	"Times-Roman":           func() standardFont { return fonts.NewFontTimesRoman() },
+	"Times-Bold":            func() standardFont { return fonts.NewFontTimesBold() },
+	"Times-Italic":          func() standardFont { return fonts.NewFontTimesItalic() },
+	"Times-BoldItalic":      func() standardFont { return fonts.NewFontTimesBoldItalic() },
+	"Courier":               func() standardFont { return fonts.NewFontCourier() },
+	"Courier-Bold":          func() standardFont { return fonts.NewFontCourierBold() },
+	"Courier-Oblique":       func() standardFont { return fonts.NewFontCourierOblique() },
+	"Courier-BoldOblique":   func() standardFont { return fonts.NewFontCourierBoldOblique() },
+}
+
+// textStyle is the font and distance from the page edges of text stamped on pages
+type textStyle struct {
+	font    standardFont
+	fontObj core.PdfObject
+	size    float64
+	margin  float64
+}
+
+// newTextStyle returns the style for text in the standard font of that name
+func newTextStyle(font string, size, margin float64) (textStyle, error) {
+	newFont, ok := standardFonts[font]
+	if !ok {
+		return textStyle{}, fmt.Errorf("unknown font %s, e.g. Helvetica, Times-Roman or Courier", font)
+	}
+	if size <= 0 {
+		return textStyle{}, errors.New("font size must be positive")
+	}
+
+	s := textStyle{font: newFont(), size: size, margin: margin}
+	s.fontObj = s.font.ToPdfObject()
+	return s, nil
+}
+
+// edgeText is text stamped at the top or bottom edge of a page, to the left, center or right
+type edgeText struct {
+	vertical  string
+	alignment string
+	text      string
+}
+
+// parsePosition returns the edge and alignment of a position such as "bottom-center"
+func parsePosition(pos string) (vertical, alignment string, err error) {
+	if p := strings.SplitN(pos, "-", 2); len(p) == 2 {
+		vertical, alignment = p[0], p[1]
+	}
+	if (vertical != "top" && vertical != "bottom") || (alignment != "left" && alignment != "center" && alignment != "right") {
+		return "", "", errors.New("position must be top or bottom, \"-\" and left, center or right, e.g. bottom-center")
+	}
+	return vertical, alignment, nil
+}
+
+// stamp returns a copy of p with the texts drawn over its content, placed on the page as it is
+// shown
+func (s textStyle) stamp(p *model.PdfPage, texts []edgeText) (*model.PdfPage, error) {
+	view, err := newPageView(p)
+	if err != nil {
+		return nil, err
+	}
+
+	dup, err := copyPage(p)
+	if err != nil {
+		return nil, err
+	}
+	name := uniqueResourceName(dup.Resources.Font, "PdfSplitterText")
+	if err = dup.Resources.SetFontByName(name, s.fontObj); err != nil {
+		return nil, err
+	}
+
+	var draw strings.Builder
+	for _, t := range texts {
+		x, y := view.place(t.vertical, t.alignment, textWidth(s.font, t.text, s.size), s.size, s.margin)
+		fmt.Fprintf(&draw, "q %s0 g BT /%s %.2f Tf %.2f %.2f Td %s Tj ET Q\n", view.matrix, name, s.size, x, y, winAnsiString(t.text))
+	}
+	stampContents(dup, "", draw.String())
+
+	return dup, nil
+}
+
+// pageView is a page as it is shown, in its crop box and rotated
+type pageView struct {
+	width, height float64
+	matrix        string //operators mapping the shown page to default user space
+}
+
+// newPageView returns the view of p
+func newPageView(p *model.PdfPage) (pageView, error) {
+	box, rotate, err := visibleBox(p)
+	if err != nil {
+		return pageView{}, err
+	}
+	width, height := box.Urx-box.Llx, box.Ury-box.Lly
+
+	v := pageView{width: width, height: height, matrix: fmt.Sprintf("1 0 0 1 %.2f %.2f cm ", box.Llx, box.Lly)}
+	switch rotate {
+	case 90:
+		v.matrix += fmt.Sprintf("0 1 -1 0 %.2f 0 cm ", width)
+		v.width, v.height = height, width
+	case 180:
+		v.matrix += fmt.Sprintf("-1 0 0 -1 %.2f %.2f cm ", width, height)
+	case 270:
+		v.matrix += fmt.Sprintf("0 -1 1 0 0 %.2f cm ", height)
+		v.width, v.height = height, width
+	}
+	return v, nil
+}
+
+// place returns the lower left corner of a box of the given size at the top or bottom edge,
+// aligned left, center or right, margin from the edges
+func (v pageView) place(vertical, alignment string, width, height, margin float64) (x, y float64) {
+	x = margin
+	switch alignment {
+	case "center":
+		x = (v.width - width) / 2
+	case "right":
+		x = v.width - margin - width
+	}
+	y = margin
+	if vertical == "top" {
+		y = v.height - margin - height
+	}
+	return x, y
+}
+
+// pageNumberer stamps page numbers such as "Page 3 of 10" on output pages
+type pageNumberer struct {
+	tmpl      string
+	mode      string
+	style     textStyle
+	vertical  string //"top" or "bottom"
+	alignment string //"left", "center" or "right"
+	total     int    //page count for numberContinue
+	next      int    //number of the first page of the next output, for numberContinue
+}
+
+// newPageNumberer returns a numberer for tmpl, which has the variables {n} (the page number) and
+// {total} (the page count of the output, or of the input for numberContinue, which must be set
+// in total). pos is where the number goes, e.g. "bottom-center".
+func newPageNumberer(tmpl, mode string, style textStyle, pos string) (*pageNumberer, error) {
+	for _, m := range templateVar.FindAllStringSubmatch(tmpl, -1) {
+		if m[0] != "{n}" && m[0] != "{total}" {
+			return nil, fmt.Errorf("unknown variable %s in page number template %q", m[0], tmpl)
+		}
+	}
+	if mode != numberPerPart && mode != numberContinue {
+		return nil, fmt.Errorf("page number mode must be %s or %s", numberPerPart, numberContinue)
+	}
+
+	n := &pageNumberer{tmpl: tmpl, mode: mode, style: style, next: 1}
+	var err error
+	if n.vertical, n.alignment, err = parsePosition(pos); err != nil {
+		return nil, fmt.Errorf("page number %v", err)
+	}
+
+	return n, nil
+}
+
+// apply is a pageTransform stamping the number on each page
+func (n *pageNumberer) apply(pages []*model.PdfPage) ([]*model.PdfPage, error) {
+	first, total := 1, len(pages)
+	if n.mode == numberContinue {
+		first, total = n.next, n.total
+		n.next += len(pages)
+	}
+
+	stamped := make([]*model.PdfPage, len(pages))
+	for i, p := range pages {
+		text := templateVar.ReplaceAllStringFunc(n.tmpl, func(v string) string {
+			if v == "{n}" {
+				return strconv.Itoa(first + i)
+			}
+			return strconv.Itoa(total)
+		})
+
+		var err error
+		if stamped[i], err = n.style.stamp(p, []edgeText{{n.vertical, n.alignment, text}}); err != nil {
+			return nil, err
+		}
+	}
+
+	return stamped, nil
+}
+
+// visibleBox returns the crop box of p, or its media box if it has none, and its rotation in
+// degrees clockwise, one of 0, 90, 180 or 270. Both may be inherited from the page tree.
+func visibleBox(p *model.PdfPage) (*model.PdfRectangle, int64, error) {
+	box := p.CropBox
+	if box == nil {
+		if arr, ok := core.TraceToDirectObject(inheritedAttribute(p, "CropBox")).(*core.PdfObjectArray); ok {
+			box, _ = model.NewPdfRectangle(*arr)
+		}
+	}
+	if box == nil {
+		var err error
+		if box, err = p.GetMediaBox(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var rotate int64
+	if p.Rotate != nil {
+		rotate = *p.Rotate
+	} else if r, ok := core.TraceToDirectObject(inheritedAttribute(p, "Rotate")).(*core.PdfObjectInteger); ok {
+		rotate = int64(*r)
+	}
+	rotate = (rotate%360 + 360) % 360
+
+	return box, rotate, nil
+}
+
+// inheritedAttribute returns the value of key in the nearest ancestor of p in the page tree that
+// has it, or nil
+func inheritedAttribute(p *model.PdfPage, key core.PdfObjectName) core.PdfObject {
+	for node := p.Parent; node != nil; {
+		dict, ok := core.TraceToDirectObject(node).(*core.PdfObjectDictionary)
+		if !ok {
+			return nil
+		}
+		if obj := dict.Get(key); obj != nil {
+			return obj
+		}
+		node = dict.Get("Parent")
+	}
+	return nil
+}
+
+// uniqueResourceName returns name, or name with a number added, whichever isn't a key of the
+// resource dictionary res yet
+func uniqueResourceName(res core.PdfObject, name string) core.PdfObjectName {
+	dict, ok := core.TraceToDirectObject(res).(*core.PdfObjectDictionary)
+	if !ok || dict.Get(core.PdfObjectName(name)) == nil {
+		return core.PdfObjectName(name)
+	}
+	for i := 2; ; i++ {
+		if candidate := core.PdfObjectName(name + strconv.Itoa(i)); dict.Get(candidate) == nil {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// outline modes for -outline
+const (
+	outlineRanges    = "ranges"    //an item for each run of consecutive input pages
+	outlineBookmarks = "bookmarks" //the input bookmarks pointing to pages in the output
+)
+
+// maxOutlineLevel is the deepest input outline level copied by outlineBookmarks
+const maxOutlineLevel = 32
+
+// outlineEntry is an item of an outline generated for an output
+type outlineEntry struct {
+	title string
+	level int //1 for top-level items
+	page  int //0-based index of the output page it points to
+}
+
+// outlinePlan generates a fresh outline for each output from the input pages it holds, so
+// outputs of inputs without an outline, or split from within one, can still be navigated
+type outlinePlan struct {
+	mode  string
+	pages map[*model.PdfPage]int //1-based input page numbers
+	marks []bookmark             //input bookmarks, for outlineBookmarks
+}
+
+// newOutlinePlan returns the outline plan for the pages of pdf in the given mode
+func newOutlinePlan(mode string, pdf *model.PdfReader) (*outlinePlan, error) {
+	o := &outlinePlan{mode: mode, pages: map[*model.PdfPage]int{}}
+	for i, p := range pdf.PageList {
+		o.pages[p] = i + 1
+	}
+
+	if mode == outlineBookmarks {
+		var err error
+		if o.marks, err = bookmarks(pdf, maxOutlineLevel); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// entries returns the outline of an output holding the input pages, before any transform
+func (o *outlinePlan) entries(pages []*model.PdfPage) []outlineEntry {
+	var entries []outlineEntry
+
+	if o.mode == outlineRanges {
+		for i := 0; i < len(pages); {
+			start := i
+			for i++; i < len(pages) && o.pages[pages[i]] == o.pages[pages[i-1]]+1; i++ {
+			}
+			title := fmt.Sprintf("Page %d", o.pages[pages[start]])
+			if i-start > 1 {
+				title = fmt.Sprintf("Pages %d-%d", o.pages[pages[start]], o.pages[pages[i-1]])
+			}
+			entries = append(entries, outlineEntry{title: title, level: 1, page: start})
+		}
+		return entries
+	}
+
+	//each bookmark points to the first output page holding its input page
+	index := map[int]int{}
+	for i := len(pages) - 1; i >= 0; i-- {
+		index[o.pages[pages[i]]] = i
+	}
+	for _, m := range o.marks {
+		if i, ok := index[m.page]; ok {
+			entries = append(entries, outlineEntry{title: m.title(), level: len(m.titles), page: i})
+		}
+	}
+	return entries
+}
+
+// copyOutline adds the outline of pdf, an output read back, to w, which writes its pages again
+func copyOutline(w *model.PdfWriter, pdf *model.PdfReader) error {
+	marks, err := bookmarks(pdf, maxOutlineLevel)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]outlineEntry, len(marks))
+	for i, m := range marks {
+		entries[i] = outlineEntry{title: m.title(), level: len(m.titles), page: m.page - 1}
+	}
+	return addOutline(w, pdf.PageList, entries)
+}
+
+// addOutline adds an outline of entries pointing to pages to the document written by w, and has
+// viewers show it when the document is opened. An entry deeper than the one before it is nested
+// under it.
+func addOutline(w *model.PdfWriter, pages []*model.PdfPage, entries []outlineEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	root := core.MakeDict()
+	root.Set("Type", core.MakeName("Outlines"))
+	rootObj := core.MakeIndirectObject(root)
+
+	type node struct {
+		dict  *core.PdfObjectDictionary
+		obj   *core.PdfIndirectObject
+		level int
+		last  *core.PdfIndirectObject //last child
+	}
+	stack := []*node{{dict: root, obj: rootObj}}
+
+	for _, e := range entries {
+		for len(stack) > 1 && stack[len(stack)-1].level >= e.level {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+
+		item := core.MakeDict()
+		item.Set("Title", core.MakeString(encodeTextString(e.title)))
+		item.Set("Parent", parent.obj)
+		item.Set("Dest", core.MakeArray(pages[e.page].GetPageAsIndirectObject(), core.MakeName("Fit")))
+		itemObj := core.MakeIndirectObject(item)
+
+		if parent.last == nil {
+			parent.dict.Set("First", itemObj)
+		} else {
+			parent.last.PdfObject.(*core.PdfObjectDictionary).Set("Next", itemObj)
+			item.Set("Prev", parent.last)
+		}
+		parent.dict.Set("Last", itemObj)
+		parent.last = itemObj
+
+		stack = append(stack, &node{dict: item, obj: itemObj, level: e.level})
+	}
+
+	//the root counts its top-level items, which are shown, and items count their children
+	//negatively, as they are shown closed
+	var setCounts func(dict *core.PdfObjectDictionary)
+	setCounts = func(dict *core.PdfObjectDictionary) {
+		var n int64
+		for child, _ := core.TraceToDirectObject(dict.Get("First")).(*core.PdfObjectDictionary); child != nil; child, _ = core.TraceToDirectObject(child.Get("Next")).(*core.PdfObjectDictionary) {
+			n++
+			setCounts(child)
+		}
+		if n > 0 {
+			if dict == root {
+				dict.Set("Count", core.MakeInteger(n))
+			} else {
+				dict.Set("Count", core.MakeInteger(-n))
+			}
+		}
+	}
+	setCounts(root)
+
+	if err := w.SetCatalogEntry("Outlines", rootObj); err != nil {
+		return err
+	}
+	return w.SetCatalogEntry("PageMode", core.MakeName("UseOutlines"))
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// pageTransform modifies the pages of an output before it is written.
+// Transforms must not modify the input pages in place, as they may be shared by several outputs.
+type pageTransform func(pages []*model.PdfPage) ([]*model.PdfPage, error)
+
+// outputWriter writes output PDFs to a directory, or a directory in a ZIP archive
+type outputWriter struct {
+	dir         string
+	transforms  []pageTransform
+	annotations *annotationImporter    //if set, its annotations are added to the pages of their input pages
+	attachments *attachmentPolicy      //if set, outputs get attachments of the input by it
+	viewer      *viewerSettings        //if set, the initial view of outputs
+	fromInput   bool                   //if set, outputs get settings read from the input once it is loaded: its initial view, attachments or outline
+	spreads     bool                   //if set, spreads are split into single pages before the transforms
+	shard       int                    //if set, outputs are spread over numbered subdirectories of this many files
+	count       int                    //outputs written
+	failed      int                    //outputs skipped as they failed preflight
+	archive     *zipArchive            //if set, outputs are added to the archive instead of written to files
+	encrypt     *encryption            //if set, outputs are password protected
+	checkUA     bool                   //if set, outputs are checked for the basic PDF/UA requirements
+	preflight   *preflightProfile      //if set, outputs failing its output rules are skipped
+	pdfa        *pdfaConverter         //if set, outputs are converted to PDF/A-2b
+	outline     *outlinePlan           //if set, outputs get a fresh outline
+	headers     *headerStamper         //if set, output pages get a header and footer
+	qr          *qrStamper             //if set, the first page of outputs gets a tracking QR code
+	remote      *sftpTarget            //if set, dir is a temporary directory uploaded to it on close
+	originalID  string                 //if set, the first file identifier of outputs, inherited from the input
+	metadata    *docMetadata           //if set, sets the file identifier and document information of outputs
+	report      *jobReport             //if set, a row is written to it for each output
+	events      *eventPublisher        //if set, an event is published for each output
+	source      string                 //input file
+	inputPages  map[*model.PdfPage]int //1-based input page numbers, once the input is loaded
+}
+
+// setInput sets the input the outputs are split from, fn, read into pdf
+func (w *outputWriter) setInput(fn string, pdf *model.PdfReader) {
+	w.source = fn
+	w.inputPages = map[*model.PdfPage]int{}
+	for i, p := range pdf.PageList {
+		w.inputPages[p] = i + 1
+	}
+}
+
+// write applies the transforms to pages and writes them to name in the output directory.
+// vars are the template variables of the output, if any.
+func (w *outputWriter) write(name string, pages []*model.PdfPage, vars map[string]string) error {
+	start := time.Now()
+
+	//ranges of the input pages, before the transforms replace them
+	var pageRanges string
+	if w.inputPages != nil {
+		pageRanges = formatRanges(w.inputPages, pages)
+	}
+
+	//warnings logged from here on are those of this output
+	warned := 0
+	if jsonReport != nil {
+		warned = len(jsonReport.Warnings)
+	}
+
+	st, err := w.writeOutput(name, pages, pageRanges, vars)
+	if err == nil && st.skipped == nil && jsonReport != nil {
+		res := partResult{File: st.file, Pages: len(pages), Warnings: jsonReport.Warnings[warned:len(jsonReport.Warnings):len(jsonReport.Warnings)], PeakMemoryMB: peakMemoryMB()}
+		if w.inputPages != nil {
+			res.SourcePages = make([]int, len(pages))
+			for i, p := range pages {
+				res.SourcePages[i] = w.inputPages[p]
+			}
+		}
+		if w.attachments != nil {
+			names := attachmentNames(st.attachments)
+			res.Attachments = &names
+		}
+		addResult(res)
+	}
+
+	rec := outputRecord{source: w.source, pageRanges: pageRanges, pages: len(pages), status: st, err: err, duration: time.Since(start)}
+	if w.report != nil {
+		if rerr := w.report.add(rec); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	if w.events != nil {
+		w.events.output(rec)
+	}
+	checkMemory("writing " + name)
+	return err
+}
+
+// partResult is an output written by split, with the input page number of each of its pages, so
+// callers can build cross-references, and the warnings logged while building it
+type partResult struct {
+	File        string    `json:"file"`
+	Pages       int       `json:"pages"`
+	SourcePages []int     `json:"source_pages,omitempty"` //input page of output page i+1
+	Attachments *[]string `json:"attachments,omitempty"`  //names of the attachments given to it, if -attachments is set
+	Warnings    []string  `json:"warnings,omitempty"`
+
+	PeakMemoryMB *float64 `json:"peak_memory_mb,omitempty"` //peak resident set size of the split up to the output, if known
+}
+
+// outputRecord is what the job report and events record of an output
+type outputRecord struct {
+	source     string
+	pageRanges string //input pages, e.g. "1-3,7"
+	pages      int
+	status     outputStatus
+	err        error //if set, the output failed
+	duration   time.Duration
+}
+
+// outputStatus is how writing an output went
+type outputStatus struct {
+	file        string //where the output ends up, once known
+	size        int64
+	skipped     error          //if set, why the output was skipped
+	attachments []embeddedFile //the attachments given to the output
+}
+
+// writeOutput writes an output holding the input pageRanges for write, returning its status
+func (w *outputWriter) writeOutput(name string, pages []*model.PdfPage, pageRanges string, vars map[string]string) (outputStatus, error) {
+	var st outputStatus
+	var password string
+	var err error
+	if w.encrypt != nil {
+		if password, err = w.encrypt.userPassword(name, pages, vars); err != nil {
+			return st, err
+		}
+	}
+
+	//plan the outline before the transforms replace the input pages, which keep their order
+	var entries []outlineEntry
+	if w.outline != nil {
+		entries = w.outline.entries(pages)
+	}
+
+	//label outputs by the input pages, as the transforms change them
+	var label qrLabel
+	if w.qr != nil {
+		label = w.qr.label(pages, vars)
+	}
+
+	//add imported annotations while the pages are the input pages they were exported from
+	if w.annotations != nil && w.inputPages != nil {
+		numbers := make([]int, len(pages))
+		for i, p := range pages {
+			numbers[i] = w.inputPages[p]
+		}
+		pages = w.annotations.apply(pages, numbers)
+	}
+
+	//attachments referred to by the input pages
+	if w.attachments != nil {
+		st.attachments = w.attachments.forPages(pages)
+	}
+
+	//find the page to open at, as for the outline
+	open := -1
+	if w.viewer != nil {
+		open = w.viewer.openIndex(pages, w.inputPages)
+	}
+
+	//split spreads before the transforms, so they see single pages, moving the outline to them
+	if w.spreads {
+		var index []int
+		if pages, index, err = splitSpreads(pages); err != nil {
+			return st, err
+		}
+		for i := range entries {
+			entries[i].page = index[entries[i].page]
+		}
+		if open >= 0 {
+			open = index[open]
+		}
+	}
+
+	for _, t := range w.transforms {
+		if pages, err = t(pages); err != nil {
+			return st, err
+		}
+	}
+
+	//headers need the variables of the output, so they are stamped over what the transforms drew
+	if w.headers != nil {
+		if pages, err = w.headers.apply(name, pages, vars); err != nil {
+			return st, err
+		}
+	}
+	if w.qr != nil {
+		if pages, err = w.qr.stamp(name, pages, label); err != nil {
+			return st, err
+		}
+	}
+
+	setup := func(pw *model.PdfWriter) error {
+		pw.SetOriginalID(w.originalID)
+		if w.viewer != nil {
+			if err := w.viewer.apply(pw, pages, open); err != nil {
+				return err
+			}
+		}
+		if w.attachments != nil {
+			if err := w.attachments.apply(pw, st.attachments); err != nil {
+				return err
+			}
+		}
+		if w.metadata != nil {
+			return w.metadata.apply(pw, name, w.source, pageRanges, vars)
+		}
+		return nil
+	}
+
+	//entries of the archive are named relative to the output directory
+	rel := name
+	if w.shard > 0 {
+		rel = path.Join(fmt.Sprintf("%04d", w.count/w.shard+1), name)
+	}
+	fn := path.Join(w.dir, rel)
+	w.count++
+	st.file = w.location(fn)
+
+	logInfo("Writing", fn)
+
+	if w.archive == nil {
+		//names may include directories
+		if err = os.MkdirAll(path.Dir(fn), 0755); err != nil {
+			return st, fmt.Errorf("unable to create output directory: %w", err)
+		}
+	}
+
+	//outputs that aren't checked or converted once written are streamed to their file or entry
+	part := pdfPart{pages: pages, entries: entries, setup: setup}
+	if !w.buffered() {
+		if w.archive != nil {
+			st.size, err = w.archive.add(rel, part)
+		} else {
+			st.size, err = writePDF(fn, part)
+		}
+		return st, err
+	}
+
+	var buf bytes.Buffer
+	if _, err = part.WriteTo(&buf); err != nil {
+		return st, fmt.Errorf("unable to write PDF %s: %v", fn, err)
+	}
+	data := buf.Bytes()
+
+	if w.pdfa != nil {
+		var issues []string
+		if data, issues, err = w.pdfa.convert(data); err != nil {
+			return st, fmt.Errorf("unable to convert PDF %s to PDF/A: %v", fn, err)
+		}
+		for _, issue := range issues {
+			warning("PDF/A: %s: %s", fn, issue)
+		}
+	}
+
+	if w.checkUA {
+		reportUA(fn, data)
+	}
+
+	if w.encrypt != nil {
+		if data, err = w.encrypt.encrypt(name, data, password); err != nil {
+			return st, fmt.Errorf("unable to encrypt PDF %s: %v", fn, err)
+		}
+	}
+
+	//an output failing preflight is skipped, the others still written
+	if w.preflight != nil && w.preflight.applies(preflightOutputs) {
+		err = w.preflight.preflightOutput(fn, data, password)
+		if errors.Is(err, errFailsPreflight) {
+			logInfo("Skipping", fn+":", err)
+			w.failed++
+			st.skipped = err
+			return st, nil
+		} else if err != nil {
+			return st, err
+		}
+	}
+
+	if w.archive != nil {
+		_, err = w.archive.add(rel, bytes.NewReader(data))
+	} else if err = os.WriteFile(fn, data, 0644); err != nil {
+		err = fmt.Errorf("unable to write PDF file %s: %w", fn, err)
+	}
+	if err != nil {
+		return st, err
+	}
+	st.size = int64(len(data))
+	return st, nil
+}
+
+// buffered reports whether outputs are written to memory first, to be converted, checked or
+// encrypted, rather than streamed to their file or entry
+func (w *outputWriter) buffered() bool {
+	return w.encrypt != nil || w.checkUA || w.preflight != nil || w.pdfa != nil
+}
+
+// needsFullWrite reports whether outputs need more than input pages copied as they are to a file
+// in the output directory, which writeSinglePagePart does without loading the whole input.
+// Options that change, move or record outputs must be added here.
+func (w *outputWriter) needsFullWrite() bool {
+	return w.buffered() || len(w.transforms) > 0 || w.annotations != nil || w.fromInput || w.spreads || w.shard > 0 || w.archive != nil ||
+		w.outline != nil || w.headers != nil || w.qr != nil || w.remote != nil || w.metadata != nil || w.report != nil || w.events != nil
+}
+
+// location returns where the output fn ends up: fn, or its URL on the SFTP server
+func (w *outputWriter) location(fn string) string {
+	if w.remote == nil {
+		return fn
+	}
+	rel, _ := filepath.Rel(w.dir, fn)
+	return w.remote.url(filepath.ToSlash(rel))
+}
+
+// close finishes the ZIP archive, password report, QR code manifest and job report, if any, and
+// uploads the outputs to the SFTP server
+func (w *outputWriter) close() error {
+	if w.encrypt != nil {
+		if err := w.encrypt.close(); err != nil {
+			return err
+		}
+	}
+	if w.qr != nil {
+		if err := w.qr.close(); err != nil {
+			return err
+		}
+	}
+	if w.report != nil {
+		if err := w.report.close(); err != nil {
+			return err
+		}
+	}
+	if w.archive != nil {
+		if err := w.archive.Close(); err != nil {
+			return err
+		}
+	}
+	if w.remote != nil {
+		defer os.RemoveAll(w.dir)
+		if err := w.remote.upload(w.dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
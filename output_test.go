@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// readOutput reads the output fn, decrypting it with password if it is encrypted, and returns it
+// with its catalog
+func readOutput(t *testing.T, fn, password string) (*model.PdfReader, *core.PdfObjectDictionary) {
+	t.Helper()
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf, err := model.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encrypted, _ := pdf.IsEncrypted(); encrypted {
+		if ok, err := pdf.Decrypt([]byte(password)); !ok || err != nil {
+			t.Fatalf("%s: unable to decrypt: %v", fn, err)
+		}
+	}
+	trailer, err := pdf.GetTrailer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, ok := resolve(pdf, trailer.Get("Root")).(*core.PdfObjectDictionary)
+	if !ok {
+		t.Fatalf("%s: no catalog", fn)
+	}
+	return pdf, catalog
+}
+
+// rewritingWriters returns output writers to dir that rewrite outputs once they are written:
+// with a password, "x", or converting them to PDF/A, which can't be combined
+func rewritingWriters(t *testing.T) map[string]*outputWriter {
+	t.Helper()
+	enc, err := newEncryption(encryptAES256, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc.password = "x"
+	pdfa, err := newPDFAConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]*outputWriter{
+		"password": {dir: t.TempDir(), encrypt: enc},
+		"pdfa":     {dir: t.TempDir(), pdfa: pdfa},
+	}
+}
+
+// Test that outputs rewritten with -password or -pdfa keep the initial view of -page-layout and
+// -open-fit.
+func TestRewriteKeepsInitialView(t *testing.T) {
+	pdf, err := loadPDF(bytes.NewReader(testPDF(t, 4, nil)), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewer, err := newViewerSettings(pdf, "two-page-left", "", "width")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ow := range rewritingWriters(t) {
+		ow.viewer = viewer
+		ow.setInput("input.pdf", pdf)
+		if err = ow.write("out.pdf", pdf.PageList[1:3], nil); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		out, catalog := readOutput(t, path.Join(ow.dir, "out.pdf"), "x")
+		if layout, ok := resolve(out, catalog.Get("PageLayout")).(*core.PdfObjectName); !ok || *layout != "TwoPageLeft" {
+			t.Errorf("%s: PageLayout %v, expected TwoPageLeft", name, catalog.Get("PageLayout"))
+		}
+		dest, ok := resolve(out, catalog.Get("OpenAction")).(*core.PdfObjectArray)
+		if !ok || len(*dest) != 3 {
+			t.Errorf("%s: OpenAction %v, expected the first page fit to its width", name, catalog.Get("OpenAction"))
+			continue
+		}
+		if page := resolve(out, (*dest)[0]); page != out.PageList[0].GetPageAsIndirectObject().PdfObject {
+			t.Errorf("%s: OpenAction opens %v, not the first page", name, (*dest)[0])
+		}
+		if fit, ok := resolve(out, (*dest)[1]).(*core.PdfObjectName); !ok || *fit != "FitH" {
+			t.Errorf("%s: OpenAction fit %v, expected FitH", name, (*dest)[1])
+		}
+	}
+}
+
+// Test that outputs rewritten with -password or -pdfa keep the -page-mode panel rather than the
+// outline panel their outline opens with.
+func TestRewriteKeepsPageMode(t *testing.T) {
+	pdf, err := loadPDF(bytes.NewReader(testPDF(t, 4, nil)), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outline, err := newOutlinePlan(outlineRanges, pdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []string{"thumbnails", "full-screen"} {
+		viewer, err := newViewerSettings(pdf, "", mode, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, ow := range rewritingWriters(t) {
+			ow.viewer = viewer
+			ow.outline = outline
+			ow.setInput("input.pdf", pdf)
+			if err = ow.write("out.pdf", pdf.PageList, nil); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+
+			out, catalog := readOutput(t, path.Join(ow.dir, "out.pdf"), "x")
+			if catalog.Get("Outlines") == nil {
+				t.Errorf("%s: no outline", name)
+			}
+			if pageMode, ok := resolve(out, catalog.Get("PageMode")).(*core.PdfObjectName); !ok || *pageMode != pageModes[mode] {
+				t.Errorf("%s: PageMode %v, expected %s", name, catalog.Get("PageMode"), pageModes[mode])
+			}
+		}
+	}
+}
+
+// testAttachment returns the file specification of an attachment holding data
+func testAttachment(t *testing.T, name string, data []byte) core.PdfObject {
+	t.Helper()
+	file, err := core.MakeStream(data, core.NewFlateEncoder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Set("Type", core.MakeName("EmbeddedFile"))
+	ef := core.MakeDict()
+	ef.Set("F", file)
+	spec := core.MakeDict()
+	spec.Set("Type", core.MakeName("Filespec"))
+	spec.Set("F", core.MakeString(name))
+	spec.Set("EF", ef)
+	return core.MakeIndirectObject(spec)
+}
+
+// setAttachments returns a setup giving a PDF the attachments files, by name
+func setAttachments(files map[string]core.PdfObject) func(*model.PdfWriter) error {
+	return func(w *model.PdfWriter) error {
+		var embedded []embeddedFile
+		for name, spec := range files {
+			embedded = append(embedded, embeddedFile{name: name, spec: spec})
+		}
+		return (&attachmentPolicy{}).apply(w, embedded)
+	}
+}
+
+// attachmentData returns the data of the attachments of the PDF out, by name
+func attachmentData(t *testing.T, out *model.PdfReader, catalog *core.PdfObjectDictionary) map[string]string {
+	t.Helper()
+	data := map[string]string{}
+	names, _ := resolve(out, catalog.Get("Names")).(*core.PdfObjectDictionary)
+	if names == nil {
+		return data
+	}
+	tree, _ := resolve(out, names.Get("EmbeddedFiles")).(*core.PdfObjectDictionary)
+	if tree == nil {
+		return data
+	}
+	entries, _ := resolve(out, tree.Get("Names")).(*core.PdfObjectArray)
+	for i := 0; entries != nil && i+1 < len(*entries); i += 2 {
+		name, _ := resolve(out, (*entries)[i]).(*core.PdfObjectString)
+		spec, _ := resolve(out, (*entries)[i+1]).(*core.PdfObjectDictionary)
+		if name == nil || spec == nil {
+			continue
+		}
+		ef, _ := resolve(out, spec.Get("EF")).(*core.PdfObjectDictionary)
+		if ef == nil {
+			continue
+		}
+		if file, ok := resolve(out, ef.Get("F")).(*core.PdfObjectStream); ok {
+			decoded, err := core.DecodeStream(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data[string(*name)] = string(decoded)
+		}
+	}
+	return data
+}
+
+// Test that outputs rewritten with -password or -pdfa keep the attachments -attachments gives
+// them.
+func TestRewriteKeepsAttachments(t *testing.T) {
+	input := testPDF(t, 2, setAttachments(map[string]core.PdfObject{
+		"a.txt": testAttachment(t, "a.txt", []byte("first attachment")),
+		"b.txt": testAttachment(t, "b.txt", []byte("second attachment")),
+	}))
+	pdf, err := loadPDF(bytes.NewReader(input), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := newAttachmentPolicy(pdf, attachAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ow := range rewritingWriters(t) {
+		ow.attachments = policy
+		ow.setInput("input.pdf", pdf)
+		if err = ow.write("out.pdf", pdf.PageList[:1], nil); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		out, catalog := readOutput(t, path.Join(ow.dir, "out.pdf"), "x")
+		got := attachmentData(t, out, catalog)
+		for file, data := range map[string]string{"a.txt": "first attachment", "b.txt": "second attachment"} {
+			if got[file] != data {
+				t.Errorf("%s: attachment %s: %q, expected %q", name, file, got[file], data)
+			}
+		}
+	}
+}
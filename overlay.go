@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// overlay page cycling modes for -stamp-pages
+const (
+	stampAll       = "all"
+	stampFirst     = "first"
+	stampAlternate = "alternate"
+)
+
+// overlay stamps the pages of another PDF over or under output pages
+type overlay struct {
+	name  core.PdfObjectName //XObject resource name
+	forms []*model.XObjectForm
+	under bool
+	mode  string
+}
+
+// loadOverlay converts each page of the PDF file fn into a form XObject
+func loadOverlay(fn string, under bool, mode string) (*overlay, error) {
+	pdf, f, err := openPDF(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	o := &overlay{name: "PdfSplitterOverlay", under: under, mode: mode}
+	if under {
+		o.name = "PdfSplitterUnderlay"
+	}
+
+	for i, p := range pdf.PageList {
+		form, err := pageToForm(p)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %v", i+1, err)
+		}
+		o.forms = append(o.forms, form)
+	}
+
+	if len(o.forms) == 0 {
+		return nil, fmt.Errorf("%s has no pages", fn)
+	}
+
+	return o, nil
+}
+
+// pageToForm converts a page into a form XObject drawing the same content
+func pageToForm(p *model.PdfPage) (*model.XObjectForm, error) {
+	contents, err := p.GetAllContentStreams()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := pageResources(p)
+	if err != nil {
+		return nil, err
+	}
+
+	mbox, err := p.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+
+	form := model.NewXObjectForm()
+	form.Filter = core.NewFlateEncoder()
+	form.Resources = res
+	form.BBox = mbox.ToPdfObject()
+	if err = form.SetContentStream([]byte(contents), nil); err != nil {
+		return nil, err
+	}
+
+	return form, nil
+}
+
+// form returns the overlay form for the page at index i of an output, or nil if that page isn't stamped
+func (o *overlay) form(i int) *model.XObjectForm {
+	switch o.mode {
+	case stampFirst:
+		if i > 0 {
+			return nil
+		}
+	case stampAlternate:
+		if i%2 == 1 {
+			return nil
+		}
+		i /= 2
+	}
+
+	return o.forms[i%len(o.forms)]
+}
+
+// apply is a pageTransform stamping the overlay on pages
+func (o *overlay) apply(pages []*model.PdfPage) ([]*model.PdfPage, error) {
+	stamped := make([]*model.PdfPage, len(pages))
+
+	for i, p := range pages {
+		form := o.form(i)
+		if form == nil {
+			stamped[i] = p
+			continue
+		}
+
+		dup, err := copyPage(p)
+		if err != nil {
+			return nil, err
+		}
+		if err = dup.Resources.SetXObjectFormByName(o.name, form); err != nil {
+			return nil, err
+		}
+
+		draw := fmt.Sprintf("q /%s Do Q\n", o.name)
+		if o.under {
+			stampContents(dup, draw, "")
+		} else {
+			stampContents(dup, "", draw)
+		}
+
+		stamped[i] = dup
+	}
+
+	return stamped, nil
+}
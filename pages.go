@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/unidoc/unidoc/pdf/core"
+)
+
+// pagesCacheLimit is the number of objects pages keeps parsed, bounding its memory use however
+// many pages the input has
+const pagesCacheLimit = 1024
+
+// runPages lists the pages of a PDF with their size and rotation, walking the page tree one page
+// at a time rather than loading it all, so inputs of 100,000s of pages are listed in constant memory
+func runPages(args []string) {
+	fs := flag.NewFlagSet("pages", flag.ExitOnError)
+	output := outputFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter pages: input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("pages", output)
+	defer finishOutput()
+
+	if fs.NArg() != 1 {
+		usage(fs)
+	}
+
+	in := fs.Arg(0)
+	f, err := openInput(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	it, err := pageIterator(f)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	for {
+		page, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fatalInput(in, err)
+		}
+
+		res := pageResult{Page: it.Number(), Object: page.ObjectNumber}
+		if box, err := it.pageSize(); err != nil {
+			warning("Page %d: %v", res.Page, err)
+		} else {
+			res.Width, res.Height = box[2]-box[0], box[3]-box[1]
+		}
+		if rotate, ok := core.TraceToDirectObject(it.Inherited("Rotate")).(*core.PdfObjectInteger); ok {
+			res.Rotate = int(*rotate)
+		}
+
+		if jsonReport != nil {
+			addResult(res)
+		} else {
+			res.print()
+		}
+	}
+}
+
+// lazyPages iterates over the pages of a PDF with a bounded object cache
+type lazyPages struct {
+	*core.PageIterator
+	parser *core.PdfParser
+}
+
+// pageIterator returns an iterator over the pages of the PDF rs. An encrypted PDF is decrypted if
+// it has no user password, like readPDF, with objects decrypted as they are looked up.
+func pageIterator(rs io.ReadSeeker) (*lazyPages, error) {
+	parser, err := core.NewParser(rs)
+	if err != nil {
+		return nil, err
+	}
+	parser.SetObjectCacheLimit(pagesCacheLimit)
+
+	encrypted, err := parser.IsEncrypted()
+	if err != nil {
+		return nil, err
+	}
+	if encrypted {
+		if ok, err := parser.Decrypt([]byte("")); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, errPasswordNeeded
+		}
+	}
+
+	it, err := parser.Pages()
+	if err != nil {
+		return nil, err
+	}
+	return &lazyPages{PageIterator: it, parser: parser}, nil
+}
+
+// pageSize returns the CropBox of the current page, or its MediaBox if it has none, as
+// llx, lly, urx, ury
+func (it *lazyPages) pageSize() ([]float64, error) {
+	obj := it.Inherited("CropBox")
+	if obj == nil {
+		obj = it.Inherited("MediaBox")
+	}
+	obj, err := it.parser.Trace(obj)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := obj.(*core.PdfObjectArray)
+	if !ok {
+		return nil, errors.New("no MediaBox")
+	}
+	box, err := arr.ToFloat64Array()
+	if err != nil || len(box) != 4 {
+		return nil, fmt.Errorf("invalid page box %s", arr)
+	}
+	return box, nil
+}
+
+// pageResult is a page listed by pages
+type pageResult struct {
+	Page   int     `json:"page"`
+	Object int64   `json:"object"`
+	Width  float64 `json:"width"` //in points, of the CropBox or MediaBox
+	Height float64 `json:"height"`
+	Rotate int     `json:"rotate,omitempty"`
+}
+
+// print prints the result as text
+func (res pageResult) print() {
+	line := fmt.Sprintf("%d: %gx%g pt", res.Page, res.Width, res.Height)
+	if res.Rotate != 0 {
+		line += fmt.Sprintf(", rotated %d", res.Rotate)
+	}
+	fmt.Printf("%s (object %d)\n", line, res.Object)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// part is a single output PDF built from one or more input pages
+type part struct {
+	name  string
+	pages []int             //1-based input page numbers, in output order
+	vars  map[string]string //template variables, for password templates
+}
+
+// partFlags collects repeated -part flags
+type partFlags []string
+
+func (p *partFlags) String() string {
+	return strings.Join(*p, " ")
+}
+
+func (p *partFlags) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// parsePart parses a "name=ranges" part definition, e.g. "report.pdf=1-10,25"
+func parsePart(def string, numPages int) (part, error) {
+	i := strings.LastIndex(def, "=")
+	if i < 1 {
+		return part{}, fmt.Errorf("part %q must be of the form name=ranges", def)
+	}
+
+	pages, err := parseRanges(def[i+1:], numPages)
+	if err != nil {
+		return part{}, fmt.Errorf("part %q: %v", def, err)
+	}
+
+	return part{name: def[:i], pages: pages}, nil
+}
+
+// parseRanges parses a comma separated list of pages and page ranges, e.g. "1-10,25".
+// An open ended range ("20-") runs to the last page.
+func parseRanges(s string, numPages int) ([]int, error) {
+	var pages []int
+
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			return nil, fmt.Errorf("empty page range in %q", s)
+		}
+
+		from, to := r, r
+		if i := strings.Index(r, "-"); i >= 0 {
+			from, to = r[:i], r[i+1:]
+			if to == "" {
+				to = strconv.Itoa(numPages)
+			}
+		}
+
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page %q", from)
+		}
+		end, err := strconv.Atoi(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page %q", to)
+		}
+
+		if start < 1 || end > numPages || start > end {
+			return nil, fmt.Errorf("page range %q out of bounds (document has %d pages)", r, numPages)
+		}
+
+		for n := start; n <= end; n++ {
+			pages = append(pages, n)
+		}
+	}
+
+	return pages, nil
+}
+
+// formatRanges returns the input page numbers of pages, given by numbers, as ranges such as
+// "1-3,7"
+func formatRanges(numbers map[*model.PdfPage]int, pages []*model.PdfPage) string {
+	var ranges []string
+	for i := 0; i < len(pages); {
+		start := i
+		for i++; i < len(pages) && numbers[pages[i]] == numbers[pages[i-1]]+1; i++ {
+		}
+		r := strconv.Itoa(numbers[pages[start]])
+		if i-start > 1 {
+			r += "-" + strconv.Itoa(numbers[pages[i-1]])
+		}
+		ranges = append(ranges, r)
+	}
+	return strings.Join(ranges, ",")
+}
+
+// newPageWriter returns a PDF writer holding the given pages
+func newPageWriter(pages []*model.PdfPage) (*model.PdfWriter, error) {
+	w := model.NewPdfWriter()
+	for _, p := range pages {
+		if err := w.AddPage(p); err != nil {
+			return nil, fmt.Errorf("unable to add page to writer: %v", err)
+		}
+	}
+
+	return &w, nil
+}
+
+// pdfPart is an output PDF of the given pages, with an outline of entries, if any. setup, if set,
+// is called with the writer first, e.g. to set the document information. It implements
+// io.WriterTo, writing the PDF as it is built, so it is streamed to a file or archive entry
+// without a temporary copy.
+type pdfPart struct {
+	pages   []*model.PdfPage
+	entries []outlineEntry
+	setup   func(*model.PdfWriter) error
+}
+
+// WriteTo writes the PDF to w, returning the number of bytes written
+func (p pdfPart) WriteTo(w io.Writer) (int64, error) {
+	pw, err := newPageWriter(p.pages)
+	if err != nil {
+		return 0, err
+	}
+	if err = addOutline(pw, p.pages, p.entries); err != nil {
+		return 0, fmt.Errorf("unable to add outline: %v", err)
+	}
+	//after the outline, so a page mode set up replaces the one it opens with
+	if p.setup != nil {
+		if err = p.setup(pw); err != nil {
+			return 0, fmt.Errorf("unable to set metadata: %v", err)
+		}
+	}
+
+	ow := &offsetWriter{w: w}
+	err = pw.Write(ow)
+	return ow.n, err
+}
+
+// offsetWriter streams the PDF writer to w. The PDF writer only seeks to find its current
+// offset, so it needs no more than a count of the bytes written.
+type offsetWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.Write(p)
+	o.n += int64(n)
+	return n, err
+}
+
+func (o *offsetWriter) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekCurrent {
+		return 0, errors.New("offsetWriter only reports the current offset")
+	}
+	return o.n, nil
+}
+
+// writePDF writes the PDF part to a new file fn, returning its size
+func writePDF(fn string, part io.WriterTo) (int64, error) {
+	f, err := os.Create(fn)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open new PDF file %s for writing: %w", fn, err)
+	}
+
+	n, err := part.WriteTo(f)
+	if err != nil {
+		f.Close()
+		return n, fmt.Errorf("unable to write PDF file %s: %w", fn, err)
+	}
+	return n, f.Close()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test that -part definitions parse into their name and pages, and that malformed ranges and
+// pages outside the document are rejected.
+func TestParsePart(t *testing.T) {
+	tests := []struct {
+		def   string
+		name  string
+		pages []int
+	}{
+		{"report.pdf=1-3,5", "report.pdf", []int{1, 2, 3, 5}},
+		{"a=b.pdf=2", "a=b.pdf", []int{2}},
+		{"tail.pdf=8-", "tail.pdf", []int{8, 9, 10}},
+		{"all.pdf=1-10", "all.pdf", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{"spaced.pdf= 4 , 2-3 ", "spaced.pdf", []int{4, 2, 3}},
+		{"repeat.pdf=1,1", "repeat.pdf", []int{1, 1}},
+		{"dir/one.pdf=10", "dir/one.pdf", []int{10}},
+	}
+	for _, test := range tests {
+		pt, err := parsePart(test.def, 10)
+		if err != nil {
+			t.Errorf("%s: %v", test.def, err)
+		} else if pt.name != test.name || !reflect.DeepEqual(pt.pages, test.pages) {
+			t.Errorf("%s: %s=%v, expected %s=%v", test.def, pt.name, pt.pages, test.name, test.pages)
+		}
+	}
+
+	for _, def := range []string{
+		"report.pdf",
+		"=1-3",
+		"report.pdf=",
+		"report.pdf=1,,2",
+		"report.pdf=1,",
+		"report.pdf=0",
+		"report.pdf=11",
+		"report.pdf=9-11",
+		"report.pdf=3-2",
+		"report.pdf=-3",
+		"report.pdf=one",
+		"report.pdf=1-two",
+		"report.pdf=1-2-3",
+		"report.pdf=1.5",
+	} {
+		if pt, err := parsePart(def, 10); err == nil {
+			t.Errorf("%s: parsed as %s=%v", def, pt.name, pt.pages)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// passwordFlags are the ways to give the password of a flag such as -password other than on the
+// command line, where it would show in shell history and process listings: -password-stdin,
+// -password-file and -password-from
+type passwordFlags struct {
+	name     string
+	password *string
+	stdin    *bool
+	file     *string
+	from     *string
+}
+
+// addPasswordFlags adds the -stdin, -file and -from flags of the password flag name, setting
+// password
+func addPasswordFlags(fs *flag.FlagSet, name string, password *string) *passwordFlags {
+	return &passwordFlags{
+		name:     name,
+		password: password,
+		stdin:    fs.Bool(name+"-stdin", false, "read -"+name+" from the first line of stdin, or prompt for it without echo if stdin is a terminal"),
+		file:     fs.String(name+"-file", "", "read -"+name+" from the first line of this `file`"),
+		from:     fs.String(name+"-from", "", "fetch -"+name+" from a credential `source`: \"env:VARIABLE\", \"keychain:service[/account]\" or \"helper:command\""),
+	}
+}
+
+// sources returns how many ways the password is given
+func (pf *passwordFlags) sources() int {
+	n := 0
+	for _, set := range []bool{*pf.password != "", *pf.stdin, *pf.file != "", *pf.from != ""} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// readPasswords reads the passwords given on stdin, in files or by credential providers, failing
+// if one is given twice or more than one on stdin
+func readPasswords(pfs ...*passwordFlags) error {
+	stdin := ""
+	for _, pf := range pfs {
+		if pf.sources() > 1 {
+			return fmt.Errorf("only one of -%s, -%[1]s-stdin, -%[1]s-file and -%[1]s-from may be set", pf.name)
+		}
+		if !*pf.stdin {
+			continue
+		}
+		if stdin != "" {
+			return fmt.Errorf("-%s-stdin and -%s-stdin can't both read stdin", stdin, pf.name)
+		}
+		stdin = pf.name
+	}
+
+	for _, pf := range pfs {
+		if err := pf.read(); err != nil {
+			return fmt.Errorf("unable to read -%s: %w", pf.name, err)
+		}
+	}
+	return nil
+}
+
+// read sets the password from stdin, the file or the credential source, if any is set
+func (pf *passwordFlags) read() error {
+	if *pf.password != "" || pf.sources() == 0 {
+		return nil
+	}
+
+	var err error
+	switch {
+	case *pf.from != "":
+		*pf.password, err = fetchCredential(*pf.from)
+	case *pf.file != "":
+		*pf.password, err = readPasswordFile(*pf.file)
+	case isTerminal(os.Stdin):
+		*pf.password, err = promptPassword(strings.ToUpper(pf.name[:1]) + strings.ReplaceAll(pf.name[1:], "-", " "))
+	default:
+		*pf.password, err = readPasswordLine(os.Stdin)
+	}
+	if err == nil && *pf.password == "" {
+		err = errors.New("empty password")
+	}
+	return err
+}
+
+// readPasswordFile reads a password from the first line of the file fn
+func readPasswordFile(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return readPasswordLine(f)
+}
+
+// readPasswordLine reads a password from the first line of r, without its line ending
+func readPasswordLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// promptPassword prompts for the password label on the terminal, twice, as a typo in the password
+// of an output would lock it
+func promptPassword(label string) (string, error) {
+	fmt.Fprint(os.Stderr, label+": ")
+	password, err := readNoEcho(os.Stdin)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprint(os.Stderr, "Repeat "+strings.ToLower(label)+": ")
+	repeated, err := readNoEcho(os.Stdin)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if repeated != password {
+		return "", errors.New("passwords don't match")
+	}
+	return password, nil
+}
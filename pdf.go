@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/extractor"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// openPDF opens and parses the PDF file fn, like loadPDF.
+// The returned file must be closed once the reader is no longer used.
+func openPDF(fn string) (*model.PdfReader, io.Closer, error) {
+	f, err := openInput(fn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pdf, err := loadPDF(f, nil, 0)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return pdf, f, nil
+}
+
+// errPasswordNeeded is returned for inputs whose pages can't be read without a user password
+var errPasswordNeeded = errors.New("user password needed")
+
+// loadPDF parses the PDF rs like readPDF, returning errPasswordNeeded if it has a user password
+func loadPDF(rs io.ReadSeeker, warn func(core.Warning), workers int) (*model.PdfReader, error) {
+	pdf, err := readPDF(rs, warn, workers)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = pdf.GetNumPages(); err != nil {
+		//only the pages of an encrypted PDF the empty password didn't authenticate are unreadable
+		if encrypted, _ := pdf.IsEncrypted(); encrypted {
+			return nil, errPasswordNeeded
+		}
+		return nil, err
+	}
+	return pdf, nil
+}
+
+// strictness levels for -strictness
+const (
+	strict  = "strict"
+	lenient = "lenient"
+)
+
+// logWarning logs a malformed object worked around in lenient mode
+func logWarning(w core.Warning) {
+	warning("Warning: %v", w)
+}
+
+// pageText extracts the text of a page
+func pageText(p *model.PdfPage) (string, error) {
+	ex, err := extractor.New(p)
+	if err != nil {
+		return "", err
+	}
+
+	return ex.ExtractText()
+}
+
+// resolve follows references and indirect objects in pdf to a direct object. Without pdf, as
+// for objects parsed from other files, references resolve to nil.
+func resolve(pdf *model.PdfReader, obj core.PdfObject) core.PdfObject {
+	for depth := 0; depth < core.TraceMaxDepth; depth++ {
+		switch o := obj.(type) {
+		case *core.PdfObjectReference:
+			if pdf == nil {
+				return nil
+			}
+			target, err := pdf.GetIndirectObjectByNumber(int(o.ObjectNumber))
+			if err != nil {
+				return nil
+			}
+			obj = target
+		case *core.PdfIndirectObject:
+			obj = o.PdfObject
+		default:
+			return obj
+		}
+	}
+	return nil
+}
+
+// docInfo returns the text entries of the document information dictionary, e.g. "Title",
+// decoded to UTF-8
+func docInfo(pdf *model.PdfReader) map[string]string {
+	info := map[string]string{}
+
+	trailer, err := pdf.GetTrailer()
+	if err != nil {
+		return info
+	}
+	dict, ok := resolve(pdf, trailer.Get("Info")).(*core.PdfObjectDictionary)
+	if !ok {
+		return info
+	}
+
+	for _, key := range dict.Keys() {
+		if s, ok := resolve(pdf, dict.Get(key)).(*core.PdfObjectString); ok {
+			info[string(key)] = decodeTextString(string(*s))
+		}
+	}
+
+	return info
+}
+
+// securityHandlers are product names of common security handlers, by their /Filter name
+var securityHandlers = map[string]string{
+	"FOPN_foweb":           "FileOpen DRM",
+	"FOPN_fLock":           "FileOpen DRM",
+	"Adobe.APS":            "Adobe LiveCycle Rights Management",
+	"Adobe.PubSec":         "public-key (certificate) security",
+	"MicrosoftIRMServices": "Microsoft Information Rights Management",
+}
+
+// checkEncryption exits with exitEncryption if err is due to fn being protected by a security
+// handler other than the standard password handler, or by an encryption algorithm it doesn't
+// support
+func checkEncryption(fn string, err error) {
+	if errors.Is(err, core.ErrUnsupportedEncryption) {
+		exitError(exitEncryption, fn, "is encrypted in a way this tool can't open:", err)
+	}
+
+	var handlerErr *core.SecurityHandlerError
+	if !errors.As(err, &handlerErr) {
+		return
+	}
+
+	handler := handlerErr.Filter
+	if name, ok := securityHandlers[handler]; ok {
+		handler = name + " (" + handler + ")"
+	}
+	exitError(exitEncryption, fn, "is protected by the", handler, "security handler, which needs its own software to open")
+}
+
+// fatalInput logs that the input fn can't be read and exits with the status for err:
+// exitEncryption, exitPassword or exitUnreadable
+func fatalInput(fn string, err error) {
+	checkEncryption(fn, err)
+	code := exitUnreadable
+	if errors.Is(err, errPasswordNeeded) {
+		code = exitPassword
+	}
+	exitError(code, "Unable to read", fn+":", err)
+}
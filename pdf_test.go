@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// testPDF returns a PDF of n blank Letter pages, written with setup if it is set
+func testPDF(t *testing.T, n int, setup func(*model.PdfWriter) error) []byte {
+	t.Helper()
+	pages := make([]*model.PdfPage, n)
+	for i := range pages {
+		pages[i] = model.NewPdfPage()
+		pages[i].MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	}
+	w, err := newPageWriter(pages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setup != nil {
+		if err := setup(w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf seekBuffer
+	if err := w.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Test that only inputs needing a user password fail with errPasswordNeeded, which exits with
+// exitPassword, and unreadable ones with their own error.
+func TestLoadPDFErrors(t *testing.T) {
+	data := testPDF(t, 3, nil)
+	if _, err := loadPDF(bytes.NewReader(data), nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{len(data) / 4, len(data) / 2} {
+		_, err := loadPDF(bytes.NewReader(data[:size]), nil, 0)
+		if err == nil {
+			t.Errorf("truncated to %d bytes: no error", size)
+		} else if errors.Is(err, errPasswordNeeded) {
+			t.Errorf("truncated to %d bytes: %v", size, err)
+		}
+	}
+
+	encrypted := testPDF(t, 3, func(pw *model.PdfWriter) error {
+		return pw.Encrypt([]byte("user"), []byte("owner"), nil)
+	})
+	if _, err := loadPDF(bytes.NewReader(encrypted), nil, 0); !errors.Is(err, errPasswordNeeded) {
+		t.Errorf("encrypted: %v, expected %v", err, errPasswordNeeded)
+	}
+}
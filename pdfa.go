@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// pdfaActions are the action types PDF/A-2 (ISO 19005-2, 6.6.1) prohibits
+var pdfaActions = map[core.PdfObjectName]bool{
+	"Launch": true, "Sound": true, "Movie": true, "ResetForm": true, "ImportData": true,
+	"Hide": true, "SetOCGState": true, "Rendition": true, "Trans": true, "GoTo3DView": true,
+	"JavaScript": true,
+}
+
+// pdfaAnnotations are the annotation types PDF/A-2 prohibits (6.3.1), or only allows with
+// content this tool can't check, such as PDF/A attachments
+var pdfaAnnotations = map[core.PdfObjectName]bool{
+	"3D": true, "Sound": true, "Screen": true, "Movie": true, "FileAttachment": true,
+}
+
+// annotation flags (PDF 32000-1, 12.5.3)
+const (
+	annotInvisible    = 1
+	annotHidden       = 2
+	annotPrint        = 4
+	annotNoView       = 32
+	annotToggleNoView = 256
+)
+
+// cmykContent matches the DeviceCMYK color operators and color space in content
+var cmykContent = regexp.MustCompile(`(^|\s)[kK](\s|$)|/DeviceCMYK\b`)
+
+// pdfaConverter converts outputs to PDF/A-2b, as far as possible: it adds an sRGB output
+// intent and XMP metadata identifying the file as PDF/A-2b, and removes prohibited actions,
+// annotations and keys. Constructs it can't convert, such as fonts that aren't embedded, are
+// reported so the output can be fixed at its source.
+type pdfaConverter struct {
+	profile *core.PdfObjectStream //sRGB ICC profile of the output intent
+}
+
+func newPDFAConverter() (*pdfaConverter, error) {
+	profile, err := core.MakeStream(srgbProfile(), core.NewFlateEncoder())
+	if err != nil {
+		return nil, err
+	}
+	profile.Set("N", core.MakeInteger(3))
+
+	return &pdfaConverter{profile: profile}, nil
+}
+
+// pdfaFixer fixes the objects of one output, collecting what it changed or couldn't convert
+type pdfaFixer struct {
+	visited  map[core.PdfObject]bool
+	reported map[string]bool
+	issues   []string
+}
+
+// report adds an issue, once
+func (f *pdfaFixer) report(format string, args ...interface{}) {
+	issue := fmt.Sprintf(format, args...)
+	if !f.reported[issue] {
+		f.reported[issue] = true
+		f.issues = append(f.issues, issue)
+	}
+}
+
+// convert returns data, an unencrypted PDF, converted to PDF/A-2b, and a description of each
+// construct removed or left unconverted.
+// The writer changes objects in place, so the PDF is read back first rather than changing page
+// objects shared with other outputs.
+func (c *pdfaConverter) convert(data []byte) ([]byte, []string, error) {
+	pdf, err := model.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := &pdfaFixer{visited: map[core.PdfObject]bool{}, reported: map[string]bool{}}
+	for i, p := range pdf.PageList {
+		content, err := p.GetAllContentStreams()
+		if err != nil {
+			return nil, nil, fmt.Errorf("page %d: %v", i+1, err)
+		}
+		if cmykContent.MatchString(content) {
+			f.report("DeviceCMYK colors, which need a CMYK output intent (page %d)", i+1)
+		}
+
+		//annotations are fixed in the page dictionary, rather than rewritten from the model
+		p.AA = nil
+		p.Annotations = nil
+		dict := p.GetPageDict()
+		dict.Remove("AA")
+		f.fixAnnotations(dict, i+1)
+		f.fix(dict, i+1)
+	}
+
+	w, err := newPageWriter(pdf.PageList)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = copyOutline(w, pdf); err != nil {
+		return nil, nil, err
+	}
+	if err = keepCatalog(w, pdf); err != nil {
+		return nil, nil, err
+	}
+	w.SetVersion(1, 7)
+	keepIdentity(w, pdf, data)
+
+	//PDF/A needs the document information to match the XMP metadata
+	now := time.Now().UTC()
+	info := w.GetInfo()
+	info.Set("CreationDate", core.MakeString(now.Format("D:20060102150405Z")))
+	info.Set("ModDate", core.MakeString(now.Format("D:20060102150405Z")))
+	xmp := core.MakeDict()
+	xmp.Set("Type", core.MakeName("Metadata"))
+	xmp.Set("Subtype", core.MakeName("XML"))
+	packet := pdfaXMP(info, now)
+	xmp.Set("Length", core.MakeInteger(int64(len(packet))))
+	if err = w.SetCatalogEntry("Metadata", &core.PdfObjectStream{PdfObjectDictionary: xmp, Stream: packet}); err != nil {
+		return nil, nil, err
+	}
+
+	intent := core.MakeDict()
+	intent.Set("Type", core.MakeName("OutputIntent"))
+	intent.Set("S", core.MakeName("GTS_PDFA1"))
+	intent.Set("OutputConditionIdentifier", core.MakeString("sRGB IEC61966-2.1"))
+	intent.Set("Info", core.MakeString("sRGB IEC61966-2.1"))
+	intent.Set("DestOutputProfile", c.profile)
+	if err = w.SetCatalogEntry("OutputIntents", core.MakeArray(intent)); err != nil {
+		return nil, nil, err
+	}
+
+	var buf seekBuffer
+	if err = w.Write(&buf); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), f.issues, nil
+}
+
+// fixAnnotations removes the prohibited annotations of the page dictionary dict and makes the
+// others printable and visible, as PDF/A requires
+func (f *pdfaFixer) fixAnnotations(dict *core.PdfObjectDictionary, page int) {
+	annots, ok := core.TraceToDirectObject(dict.Get("Annots")).(*core.PdfObjectArray)
+	if !ok {
+		return
+	}
+
+	var kept core.PdfObjectArray
+	for _, obj := range *annots {
+		annot, ok := core.TraceToDirectObject(obj).(*core.PdfObjectDictionary)
+		if !ok {
+			continue
+		}
+		subtype, _ := core.TraceToDirectObject(annot.Get("Subtype")).(*core.PdfObjectName)
+		if subtype != nil && pdfaAnnotations[*subtype] {
+			f.report("removed %s annotation (page %d)", *subtype, page)
+			continue
+		}
+
+		var flags int64
+		if i, ok := core.TraceToDirectObject(annot.Get("F")).(*core.PdfObjectInteger); ok {
+			flags = int64(*i)
+		}
+		flags = flags&^(annotInvisible|annotHidden|annotNoView|annotToggleNoView) | annotPrint
+		annot.Set("F", core.MakeInteger(flags))
+
+		//only the normal appearance is allowed
+		if ap, ok := core.TraceToDirectObject(annot.Get("AP")).(*core.PdfObjectDictionary); ok {
+			ap.Remove("R")
+			ap.Remove("D")
+		} else if subtype == nil || *subtype != "Popup" && *subtype != "Link" {
+			name := "untyped"
+			if subtype != nil {
+				name = string(*subtype)
+			}
+			f.report("%s annotation without an appearance stream (page %d)", name, page)
+		}
+
+		kept = append(kept, obj)
+	}
+
+	dict.Set("Annots", &kept)
+}
+
+// fix removes the prohibited actions and keys of obj and the objects it refers to, other than
+// its parent, and re-encodes LZW streams with Flate
+func (f *pdfaFixer) fix(obj core.PdfObject, page int) {
+	obj = core.TraceToDirectObject(obj)
+	if obj == nil || f.visited[obj] {
+		return
+	}
+	f.visited[obj] = true
+
+	switch o := obj.(type) {
+	case *core.PdfObjectArray:
+		for _, elem := range *o {
+			f.fix(elem, page)
+		}
+	case *core.PdfObjectStream:
+		f.fixStream(o, page)
+		f.fixDict(o.PdfObjectDictionary, page)
+	case *core.PdfObjectDictionary:
+		f.fixDict(o, page)
+	}
+}
+
+// fixDict fixes the entries of dict, and the objects they refer to
+func (f *pdfaFixer) fixDict(dict *core.PdfObjectDictionary, page int) {
+	dict.Remove("AA")
+	for _, key := range []core.PdfObjectName{"A", "Next"} {
+		if action, ok := core.TraceToDirectObject(dict.Get(key)).(*core.PdfObjectDictionary); ok {
+			if s, ok := core.TraceToDirectObject(action.Get("S")).(*core.PdfObjectName); ok && pdfaActions[*s] {
+				dict.Remove(key)
+				f.report("removed %s action (page %d)", *s, page)
+			}
+		}
+	}
+
+	//resource dictionaries
+	if gstates, ok := core.TraceToDirectObject(dict.Get("ExtGState")).(*core.PdfObjectDictionary); ok {
+		for _, name := range gstates.Keys() {
+			if gs, ok := core.TraceToDirectObject(gstates.Get(name)).(*core.PdfObjectDictionary); ok {
+				gs.Remove("TR")
+				if tr2, ok := core.TraceToDirectObject(gs.Get("TR2")).(*core.PdfObjectName); gs.Get("TR2") != nil && (!ok || *tr2 != "Default") {
+					gs.Set("TR2", core.MakeName("Default"))
+				}
+			}
+		}
+	}
+	if fonts, ok := core.TraceToDirectObject(dict.Get("Font")).(*core.PdfObjectDictionary); ok {
+		for _, name := range fonts.Keys() {
+			font, ok := core.TraceToDirectObject(fonts.Get(name)).(*core.PdfObjectDictionary)
+			if !ok || fontEmbedded(font) {
+				continue
+			}
+			baseFont := string(name)
+			if bf, ok := core.TraceToDirectObject(font.Get("BaseFont")).(*core.PdfObjectName); ok {
+				baseFont = string(*bf)
+			}
+			f.report("font %s not embedded (page %d)", baseFont, page)
+		}
+	}
+	if colorSpaces, ok := core.TraceToDirectObject(dict.Get("ColorSpace")).(*core.PdfObjectDictionary); ok {
+		for _, name := range colorSpaces.Keys() {
+			if cs, ok := core.TraceToDirectObject(colorSpaces.Get(name)).(*core.PdfObjectName); ok && *cs == "DeviceCMYK" {
+				f.report("DeviceCMYK colors, which need a CMYK output intent (page %d)", page)
+			}
+		}
+	}
+
+	for _, key := range dict.Keys() {
+		//don't climb back up to the page tree or from annotations to their page
+		if key != "Parent" && key != "P" {
+			f.fix(dict.Get(key), page)
+		}
+	}
+}
+
+// fixStream fixes the prohibited keys of image and form XObjects, and re-encodes LZW streams,
+// which PDF/A prohibits, with Flate
+func (f *pdfaFixer) fixStream(stream *core.PdfObjectStream, page int) {
+	switch subtype, _ := core.TraceToDirectObject(stream.Get("Subtype")).(*core.PdfObjectName); {
+	case subtype == nil:
+	case *subtype == "Image":
+		stream.Remove("Alternates")
+		stream.Remove("OPI")
+		if interpolate, ok := core.TraceToDirectObject(stream.Get("Interpolate")).(*core.PdfObjectBool); ok && bool(*interpolate) {
+			stream.Set("Interpolate", core.MakeBool(false))
+		}
+		if cs, ok := core.TraceToDirectObject(stream.Get("ColorSpace")).(*core.PdfObjectName); ok && *cs == "DeviceCMYK" {
+			f.report("DeviceCMYK image, which needs a CMYK output intent (page %d)", page)
+		}
+	case *subtype == "Form":
+		stream.Remove("OPI")
+		stream.Remove("PS")
+		stream.Remove("Ref")
+		if subtype2, ok := core.TraceToDirectObject(stream.Get("Subtype2")).(*core.PdfObjectName); ok && *subtype2 == "PS" {
+			f.report("PostScript form XObject (page %d)", page)
+		}
+	case *subtype == "PS":
+		f.report("PostScript XObject (page %d)", page)
+	}
+
+	if !usesLZW(stream) {
+		return
+	}
+	data, err := core.DecodeStream(stream)
+	if err != nil {
+		f.report("undecodable LZW stream (page %d)", page)
+		return
+	}
+	encoder := core.NewFlateEncoder()
+	encoded, err := encoder.EncodeBytes(data)
+	if err != nil {
+		f.report("unable to re-encode LZW stream (page %d)", page)
+		return
+	}
+	stream.Stream = encoded
+	stream.Set("Filter", core.MakeName(encoder.GetFilterName()))
+	stream.Remove("DecodeParms")
+	stream.Set("Length", core.MakeInteger(int64(len(encoded))))
+}
+
+// usesLZW reports whether any filter of stream is LZWDecode
+func usesLZW(stream *core.PdfObjectStream) bool {
+	switch filter := core.TraceToDirectObject(stream.Get("Filter")).(type) {
+	case *core.PdfObjectName:
+		return *filter == "LZWDecode"
+	case *core.PdfObjectArray:
+		for _, obj := range *filter {
+			if name, ok := core.TraceToDirectObject(obj).(*core.PdfObjectName); ok && *name == "LZWDecode" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pdfaInfoXMP maps document information keys to the XMP properties PDF/A requires them to match,
+// with the XML elements wrapping their value
+var pdfaInfoXMP = map[string][2]string{
+	"Title":    {"<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">", "</rdf:li></rdf:Alt></dc:title>"},
+	"Author":   {"<dc:creator><rdf:Seq><rdf:li>", "</rdf:li></rdf:Seq></dc:creator>"},
+	"Subject":  {"<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">", "</rdf:li></rdf:Alt></dc:description>"},
+	"Keywords": {"<pdf:Keywords>", "</pdf:Keywords>"},
+	"Creator":  {"<xmp:CreatorTool>", "</xmp:CreatorTool>"},
+	"Producer": {"<pdf:Producer>", "</pdf:Producer>"},
+}
+
+// pdfaXMP returns an XMP packet identifying a file as PDF/A-2b, with the entries of the document
+// information dictionary info and the creation and modification date now
+func pdfaXMP(info *core.PdfObjectDictionary, now time.Time) []byte {
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:pdfaid=\"http://www.aiim.org/pdfa/ns/id/\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\">\n")
+	b.WriteString("<pdfaid:part>2</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>\n")
+
+	date := now.Format(time.RFC3339)
+	fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>\n<xmp:ModifyDate>%s</xmp:ModifyDate>\n<xmp:MetadataDate>%s</xmp:MetadataDate>\n", date, date, date)
+
+	var keys []string
+	for _, key := range info.Keys() {
+		if _, ok := pdfaInfoXMP[string(key)]; ok {
+			keys = append(keys, string(key))
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s, ok := core.TraceToDirectObject(info.Get(core.PdfObjectName(key))).(*core.PdfObjectString)
+		if !ok {
+			continue
+		}
+		var value bytes.Buffer
+		xml.EscapeText(&value, []byte(decodeTextString(string(*s))))
+		fmt.Fprintf(&b, "%s%s%s\n", pdfaInfoXMP[key][0], value.String(), pdfaInfoXMP[key][1])
+	}
+
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return []byte(b.String())
+}
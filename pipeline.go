@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// pipelineSpec is a JSON pipeline: inputs merged in order, transforms applied to the output
+// pages and a split, run as a single split of the merged pages. transform and split map flag
+// names of the split command, without "-", to values: strings, numbers, booleans, or arrays of
+// them for repeated flags such as -part.
+type pipelineSpec struct {
+	In        []string                   `json:"in"` //files, URLs or directories of PDFs
+	Collate   bool                       `json:"collate"`
+	Transform map[string]json.RawMessage `json:"transform"`
+	Split     map[string]json.RawMessage `json:"split"`
+}
+
+// transformFlags are the flags of the split command changing output pages, the transform step
+var transformFlags = map[string]bool{
+	"overlay": true, "underlay": true, "stamp-pages": true,
+	"page-numbers": true, "page-number-mode": true, "page-number-font": true, "page-number-size": true, "page-number-pos": true, "page-number-margin": true,
+	"header": true, "footer": true, "header-font": true, "header-size": true, "header-margin": true, "var": true,
+	"qr": true, "qr-job": true, "qr-pos": true, "qr-size": true, "qr-margin": true, "qr-manifest": true,
+	"auto-rotate": true, "split-spreads": true, "trim-borders": true,
+	"grayscale": true, "strip-images": true, "import-annotations": true, "subset-fonts": true,
+}
+
+// pipelineInput is the merged input of a pipeline, which the split reads instead of -in
+var pipelineInput *inputFile
+
+// runPipeline runs a pipeline spec in one pass: a single input is split as it is, several are
+// merged in memory rather than through an intermediate file
+func runPipeline(args []string) {
+	fs := flag.NewFlagSet("pipeline", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter pipeline: spec.json")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage(fs)
+	}
+
+	specFile := fs.Arg(0)
+	spec, err := readPipeline(specFile)
+	if err != nil {
+		argError("Invalid pipeline:", err)
+	}
+	splitArgs, err := spec.splitArgs()
+	if err != nil {
+		argError("Invalid pipeline:", err)
+	}
+	inputs, err := pipelineInputs(spec.In)
+	if err != nil {
+		argError("Invalid pipeline:", err)
+	}
+	if spec.Collate && len(inputs) != 2 {
+		argError("Invalid pipeline: collate needs exactly two inputs, fronts and backs")
+	}
+
+	if len(inputs) == 1 {
+		runSplit(append(splitArgs, "-in", inputs[0]))
+		return
+	}
+
+	//merge, then split the merged PDF as read from memory
+	readers := make([]*model.PdfReader, 0, len(inputs))
+	for _, fn := range inputs {
+		pdf, f, err := openPDF(fn)
+		if err != nil {
+			fatalInput(fn, err)
+		}
+		defer f.Close()
+		readers = append(readers, pdf)
+	}
+	var pages []*model.PdfPage
+	if spec.Collate {
+		if pages, err = collatePages(readers[0].PageList, readers[1].PageList); err != nil {
+			fatal("Unable to collate:", err)
+		}
+	} else {
+		for _, pdf := range readers {
+			pages = append(pages, pdf.PageList...)
+		}
+	}
+	logInfof("Merging %d inputs, %d pages\n", len(inputs), len(pages))
+	var merged bytes.Buffer
+	if _, err = (pdfPart{pages: pages}).WriteTo(&merged); err != nil {
+		fatal("Unable to merge inputs:", err)
+	}
+	data := merged.Bytes()
+	pipelineInput = &inputFile{inputReader: bytes.NewReader(data), size: int64(len(data)), close: func() error { return nil }}
+
+	//the spec names the merged input in reports and events
+	runSplit(append(splitArgs, "-in", specFile))
+}
+
+// readPipeline reads the pipeline spec in fn
+func readPipeline(fn string) (*pipelineSpec, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var spec pipelineSpec
+	if err = dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	if len(spec.In) == 0 {
+		return nil, fmt.Errorf("%s: no inputs in \"in\"", fn)
+	}
+	return &spec, nil
+}
+
+// splitArgs returns the flags of the transform and split steps, as split command arguments
+func (spec *pipelineSpec) splitArgs() ([]string, error) {
+	var args []string
+	add := func(step string, flags map[string]json.RawMessage, transform bool) error {
+		names := make([]string, 0, len(flags))
+		for name := range flags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			switch {
+			case name == "in":
+				return fmt.Errorf("%s: -in is set by \"in\"", step)
+			case transformFlags[name] && !transform:
+				return fmt.Errorf("%s: -%s is a transform, set under \"transform\"", step, name)
+			case !transformFlags[name] && transform:
+				return fmt.Errorf("%s: -%s isn't a transform", step, name)
+			}
+
+			var values []interface{}
+			if err := json.Unmarshal(flags[name], &values); err != nil {
+				values = make([]interface{}, 1)
+				if err = json.Unmarshal(flags[name], &values[0]); err != nil {
+					return fmt.Errorf("%s: -%s: %v", step, name, err)
+				}
+			}
+			for _, v := range values {
+				switch v := v.(type) {
+				case string:
+					args = append(args, "-"+name+"="+v)
+				case float64:
+					args = append(args, "-"+name+"="+strconv.FormatFloat(v, 'f', -1, 64))
+				case bool:
+					args = append(args, "-"+name+"="+strconv.FormatBool(v))
+				default:
+					return fmt.Errorf("%s: -%s must be a string, number or boolean, or an array of them", step, name)
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := add("transform", spec.Transform, true); err != nil {
+		return nil, err
+	}
+	if err := add("split", spec.Split, false); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// pipelineInputs returns the inputs of a pipeline, with directories replaced by the PDF files in
+// them in name order
+func pipelineInputs(in []string) ([]string, error) {
+	var inputs []string
+	for _, fn := range in {
+		if isURL(fn) || isSFTP(fn) {
+			inputs = append(inputs, fn)
+			continue
+		}
+		fi, err := os.Stat(fn)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			inputs = append(inputs, fn)
+			continue
+		}
+
+		entries, err := os.ReadDir(fn)
+		if err != nil {
+			return nil, err
+		}
+		count := len(inputs)
+		for _, e := range entries {
+			if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".pdf") {
+				inputs = append(inputs, filepath.Join(fn, e.Name()))
+			}
+		}
+		if len(inputs) == count {
+			return nil, fmt.Errorf("no PDF files in %s", fn)
+		}
+	}
+	return inputs, nil
+}
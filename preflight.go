@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/unidoc/unidoc/pdf/contentstream"
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// where preflight rules are enforced
+const (
+	preflightInputs  = "inputs"
+	preflightOutputs = "outputs"
+	preflightBoth    = "both"
+)
+
+// what a violated preflight rule does
+const (
+	preflightFail = "fail"
+	preflightWarn = "warn"
+)
+
+// preflightProfile is a set of rules declared in a JSON file for -preflight, e.g.
+//
+//	{"rules": [
+//		{"check": "max-version", "version": "1.7"},
+//		{"check": "embedded-fonts", "apply": "outputs", "action": "warn"}
+//	]}
+type preflightProfile struct {
+	Rules []preflightRule `json:"rules"`
+}
+
+// preflightRule is a check of a preflight profile, with where and how it is enforced
+type preflightRule struct {
+	Check   string  `json:"check"`   //a key of preflightChecks
+	Apply   string  `json:"apply"`   //"inputs", "outputs" or "both" (default)
+	Action  string  `json:"action"`  //"fail" (default) or "warn"
+	Version string  `json:"version"` //max-version: the latest PDF version allowed, e.g. "1.7"
+	MinDPI  float64 `json:"min_dpi"` //image-resolution: the lowest resolution allowed, 0 for none
+	MaxDPI  float64 `json:"max_dpi"` //image-resolution: the highest resolution allowed, 0 for none
+}
+
+// preflightCheck is a check of a preflight rule. It returns a description of each violation of
+// rule by pdf.
+type preflightCheck struct {
+	check    func(pdf *model.PdfReader, rule preflightRule) []string
+	validate func(rule preflightRule) error //if set, checks the parameters of rule
+}
+
+// preflightChecks are the checks rules can use, by name
+var preflightChecks = map[string]preflightCheck{
+	"max-version":      {check: checkMaxVersion, validate: validateMaxVersion},
+	"no-encryption":    {check: checkNoEncryption},
+	"embedded-fonts":   {check: checkEmbeddedFonts},
+	"image-resolution": {check: checkImageResolution, validate: validateImageResolution},
+}
+
+// loadPreflight reads and checks the preflight profile fn
+func loadPreflight(fn string) (*preflightProfile, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile preflightProfile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&profile); err != nil {
+		return nil, err
+	}
+
+	for i := range profile.Rules {
+		rule := &profile.Rules[i]
+		check, ok := preflightChecks[rule.Check]
+		if !ok {
+			return nil, fmt.Errorf("rule %d: unknown check %q", i+1, rule.Check)
+		}
+		if rule.Apply == "" {
+			rule.Apply = preflightBoth
+		}
+		if rule.Apply != preflightInputs && rule.Apply != preflightOutputs && rule.Apply != preflightBoth {
+			return nil, fmt.Errorf("rule %d: apply must be inputs, outputs or both", i+1)
+		}
+		if rule.Action == "" {
+			rule.Action = preflightFail
+		}
+		if rule.Action != preflightFail && rule.Action != preflightWarn {
+			return nil, fmt.Errorf("rule %d: action must be fail or warn", i+1)
+		}
+		if check.validate != nil {
+			if err = check.validate(*rule); err != nil {
+				return nil, fmt.Errorf("rule %d: %v", i+1, err)
+			}
+		}
+	}
+
+	return &profile, nil
+}
+
+// applies reports whether the profile has rules for target, preflightInputs or preflightOutputs
+func (p *preflightProfile) applies(target string) bool {
+	for _, rule := range p.Rules {
+		if rule.Apply == target || rule.Apply == preflightBoth {
+			return true
+		}
+	}
+	return false
+}
+
+// errFailsPreflight is returned for a file violating a rule whose action is fail
+var errFailsPreflight = errors.New("fails preflight")
+
+// enforce runs the rules for target, preflightInputs or preflightOutputs, on pdf, the file fn,
+// logging each violation. It returns an error if a rule whose action is fail is violated.
+func (p *preflightProfile) enforce(target, fn string, pdf *model.PdfReader) error {
+	var failed bool
+	for _, rule := range p.Rules {
+		if rule.Apply != target && rule.Apply != preflightBoth {
+			continue
+		}
+
+		for _, v := range preflightChecks[rule.Check].check(pdf, rule) {
+			warning("Preflight %s: %s: %s: %s", rule.Action, fn, rule.Check, v)
+			if rule.Action == preflightFail {
+				failed = true
+			}
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("%s %w", fn, errFailsPreflight)
+	}
+	return nil
+}
+
+// preflightOutput reads back the output fn, with content data and user password, if any, and
+// enforces the output rules on it
+func (p *preflightProfile) preflightOutput(fn string, data []byte, password string) error {
+	pdf, err := model.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unable to read %s for preflight: %v", fn, err)
+	}
+	if encrypted, _ := pdf.IsEncrypted(); encrypted {
+		if ok, err := pdf.Decrypt([]byte(password)); err != nil || !ok {
+			return fmt.Errorf("unable to decrypt %s for preflight: %v", fn, err)
+		}
+	}
+
+	return p.enforce(preflightOutputs, fn, pdf)
+}
+
+// validateMaxVersion checks the version of a max-version rule
+func validateMaxVersion(rule preflightRule) error {
+	if _, _, err := parseVersion(rule.Version); err != nil {
+		return fmt.Errorf("max-version needs a version such as \"1.7\": %v", err)
+	}
+	return nil
+}
+
+// parseVersion parses a PDF version such as "1.7"
+func parseVersion(s string) (int, int, error) {
+	var major, minor int
+	if _, err := fmt.Sscanf(s, "%d.%d", &major, &minor); err != nil {
+		return 0, 0, err
+	}
+	return major, minor, nil
+}
+
+// checkMaxVersion finds a PDF version later than that of the rule
+func checkMaxVersion(pdf *model.PdfReader, rule preflightRule) []string {
+	maxMajor, maxMinor, _ := parseVersion(rule.Version)
+	major, minor := pdf.PdfVersion()
+	if major > maxMajor || major == maxMajor && minor > maxMinor {
+		return []string{fmt.Sprintf("PDF %d.%d is later than %s", major, minor, rule.Version)}
+	}
+	return nil
+}
+
+// checkNoEncryption finds encryption
+func checkNoEncryption(pdf *model.PdfReader, rule preflightRule) []string {
+	if encrypted, _ := pdf.IsEncrypted(); encrypted {
+		return []string{"encrypted"}
+	}
+	return nil
+}
+
+// checkEmbeddedFonts finds fonts used by pages, or forms on them, whose font program isn't
+// embedded. Each font is reported once, with the first page using it.
+func checkEmbeddedFonts(pdf *model.PdfReader, rule preflightRule) []string {
+	var violations []string
+	reported := map[string]bool{}
+	visited := map[*core.PdfObjectStream]bool{}
+
+	var checkResources func(res *core.PdfObjectDictionary, page int)
+	checkResources = func(res *core.PdfObjectDictionary, page int) {
+		if res == nil {
+			return
+		}
+		if fonts, ok := core.TraceToDirectObject(res.Get("Font")).(*core.PdfObjectDictionary); ok {
+			for _, name := range fonts.Keys() {
+				font, ok := core.TraceToDirectObject(fonts.Get(name)).(*core.PdfObjectDictionary)
+				if !ok || fontEmbedded(font) {
+					continue
+				}
+				baseFont := string(name)
+				if bf, ok := core.TraceToDirectObject(font.Get("BaseFont")).(*core.PdfObjectName); ok {
+					baseFont = string(*bf)
+				}
+				if !reported[baseFont] {
+					reported[baseFont] = true
+					violations = append(violations, fmt.Sprintf("font %s not embedded (page %d)", baseFont, page))
+				}
+			}
+		}
+		if xobjs, ok := core.TraceToDirectObject(res.Get("XObject")).(*core.PdfObjectDictionary); ok {
+			for _, name := range xobjs.Keys() {
+				form, ok := core.TraceToDirectObject(xobjs.Get(name)).(*core.PdfObjectStream)
+				if !ok || visited[form] || !isForm(form) {
+					continue
+				}
+				visited[form] = true
+				formRes, _ := core.TraceToDirectObject(form.Get("Resources")).(*core.PdfObjectDictionary)
+				checkResources(formRes, page)
+			}
+		}
+	}
+
+	for i, p := range pdf.PageList {
+		res, err := pageResources(p)
+		if err != nil || res == nil {
+			continue
+		}
+		checkResources(resourcesDict(res), i+1)
+	}
+
+	return violations
+}
+
+// resourcesDict returns the fonts and XObjects of res as a resource dictionary, like those of forms
+func resourcesDict(res *model.PdfPageResources) *core.PdfObjectDictionary {
+	dict := core.MakeDict()
+	if res.Font != nil {
+		dict.Set("Font", res.Font)
+	}
+	if res.XObject != nil {
+		dict.Set("XObject", res.XObject)
+	}
+	return dict
+}
+
+// fontEmbedded reports whether the font program of font is embedded. Type 3 fonts are defined
+// by content streams, so are always embedded.
+func fontEmbedded(font *core.PdfObjectDictionary) bool {
+	if subtype, ok := core.TraceToDirectObject(font.Get("Subtype")).(*core.PdfObjectName); ok && *subtype == "Type3" {
+		return true
+	}
+	_, stream := fontFile(font)
+	return stream != nil
+}
+
+// fontFile returns the descriptor key ("FontFile", "FontFile2" or "FontFile3") and stream of the
+// embedded font program of font, or of the descendant font of a Type 0 font, or nil if it has none
+func fontFile(font *core.PdfObjectDictionary) (core.PdfObjectName, *core.PdfObjectStream) {
+	if subtype, ok := core.TraceToDirectObject(font.Get("Subtype")).(*core.PdfObjectName); ok && *subtype == "Type0" {
+		if descendants, ok := core.TraceToDirectObject(font.Get("DescendantFonts")).(*core.PdfObjectArray); ok && len(*descendants) > 0 {
+			if cidFont, ok := core.TraceToDirectObject((*descendants)[0]).(*core.PdfObjectDictionary); ok {
+				font = cidFont
+			}
+		}
+	}
+
+	descriptor, ok := core.TraceToDirectObject(font.Get("FontDescriptor")).(*core.PdfObjectDictionary)
+	if !ok {
+		return "", nil
+	}
+	for _, key := range []core.PdfObjectName{"FontFile", "FontFile2", "FontFile3"} {
+		if stream, ok := core.TraceToDirectObject(descriptor.Get(key)).(*core.PdfObjectStream); ok {
+			return key, stream
+		}
+	}
+	return "", nil
+}
+
+// isForm reports whether the XObject stream is a form
+func isForm(stream *core.PdfObjectStream) bool {
+	subtype, ok := core.TraceToDirectObject(stream.Get("Subtype")).(*core.PdfObjectName)
+	return ok && *subtype == "Form"
+}
+
+// validateImageResolution checks the bounds of an image-resolution rule
+func validateImageResolution(rule preflightRule) error {
+	if rule.MinDPI < 0 || rule.MaxDPI < 0 || rule.MinDPI == 0 && rule.MaxDPI == 0 {
+		return fmt.Errorf("image-resolution needs a positive min_dpi, max_dpi or both")
+	}
+	if rule.MaxDPI > 0 && rule.MinDPI > rule.MaxDPI {
+		return fmt.Errorf("image-resolution min_dpi is above max_dpi")
+	}
+	return nil
+}
+
+// checkImageResolution finds images drawn by pages, or forms on them, at a resolution outside the
+// bounds of the rule. The resolution is the image's pixels over the size it is drawn at, taking
+// the lower of its horizontal and vertical resolution.
+func checkImageResolution(pdf *model.PdfReader, rule preflightRule) []string {
+	var violations []string
+
+	for i, p := range pdf.PageList {
+		content, err := p.GetAllContentStreams()
+		if err != nil {
+			continue
+		}
+		res, err := pageResources(p)
+		if err != nil || res == nil {
+			continue
+		}
+		page := i + 1
+		imageDPI(content, resourcesDict(res), identity, 0, func(name string, dpi float64) {
+			if rule.MinDPI > 0 && dpi < rule.MinDPI {
+				violations = append(violations, fmt.Sprintf("image %s on page %d at %.0f dpi, below %g", name, page, dpi, rule.MinDPI))
+			} else if rule.MaxDPI > 0 && dpi > rule.MaxDPI {
+				violations = append(violations, fmt.Sprintf("image %s on page %d at %.0f dpi, above %g", name, page, dpi, rule.MaxDPI))
+			}
+		})
+	}
+
+	return violations
+}
+
+// matrix is a PDF transformation matrix [a b c d e f] (8.3.3)
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// mul returns the transformation m followed by n
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// toMatrix converts the 6 numbers of a cm operator or form Matrix
+func toMatrix(objs []core.PdfObject) (matrix, bool) {
+	var m matrix
+	if len(objs) != 6 {
+		return m, false
+	}
+	for i, obj := range objs {
+		switch n := core.TraceToDirectObject(obj).(type) {
+		case *core.PdfObjectInteger:
+			m[i] = float64(*n)
+		case *core.PdfObjectFloat:
+			m[i] = float64(*n)
+		default:
+			return m, false
+		}
+	}
+	return m, true
+}
+
+// maxFormDepth limits the nesting of forms followed by imageDPI, in case forms draw each other
+const maxFormDepth = 10
+
+// imageDPI calls f with the name and resolution of each image drawn by content, with resources
+// res, at the transformation ctm, following forms
+func imageDPI(content string, res *core.PdfObjectDictionary, ctm matrix, depth int, f func(name string, dpi float64)) {
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil || res == nil {
+		return
+	}
+	xobjs, _ := core.TraceToDirectObject(res.Get("XObject")).(*core.PdfObjectDictionary)
+
+	var stack []matrix
+	for _, op := range *ops {
+		switch op.Operand {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := toMatrix(op.Params); ok {
+				ctm = m.mul(ctm)
+			}
+		case "Do":
+			if len(op.Params) != 1 || xobjs == nil {
+				continue
+			}
+			name, ok := op.Params[0].(*core.PdfObjectName)
+			if !ok {
+				continue
+			}
+			stream, ok := core.TraceToDirectObject(xobjs.Get(*name)).(*core.PdfObjectStream)
+			if !ok {
+				continue
+			}
+
+			if isForm(stream) {
+				if depth >= maxFormDepth {
+					continue
+				}
+				formCTM := ctm
+				if arr, ok := core.TraceToDirectObject(stream.Get("Matrix")).(*core.PdfObjectArray); ok {
+					if m, ok := toMatrix(*arr); ok {
+						formCTM = m.mul(ctm)
+					}
+				}
+				formContent, err := core.DecodeStream(stream)
+				if err != nil {
+					continue
+				}
+				formRes, _ := core.TraceToDirectObject(stream.Get("Resources")).(*core.PdfObjectDictionary)
+				imageDPI(string(formContent), formRes, formCTM, depth+1, f)
+				continue
+			}
+
+			width, wok := core.TraceToDirectObject(stream.Get("Width")).(*core.PdfObjectInteger)
+			height, hok := core.TraceToDirectObject(stream.Get("Height")).(*core.PdfObjectInteger)
+			if !wok || !hok {
+				continue
+			}
+			//the unit square the image is drawn in maps to these lengths, in points
+			w := math.Hypot(ctm[0], ctm[1])
+			h := math.Hypot(ctm[2], ctm[3])
+			if w == 0 || h == 0 {
+				continue
+			}
+			dpi := math.Min(float64(*width)/(w/72), float64(*height)/(h/72))
+			f(string(*name), dpi)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// progressLine is a JSON line written to -progress: how many of the input pages are done, or a
+// warning as it is logged
+type progressLine struct {
+	Type    string `json:"type"` //"progress" or "warning"
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+	Message string `json:"message,omitempty"`
+}
+
+// progressWriter streams the progress of a split and its warnings to a file, typically a pipe a
+// program running the splitter reads, so it can show live status rather than wait for the end.
+// Each line is written as soon as it is known.
+type progressWriter struct {
+	f     *os.File
+	enc   *json.Encoder
+	done  int
+	total int
+}
+
+// progress is where the running command writes its progress, if -progress is set
+var progress *progressWriter
+
+// openProgress opens the progress file fn, e.g. "/dev/fd/3", creating it if needed
+func openProgress(fn string) error {
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	progress = &progressWriter{f: f, enc: json.NewEncoder(f)}
+	return nil
+}
+
+// startProgress sets the number of pages to be done, writing the first progress line, if
+// -progress is set
+func startProgress(total int) {
+	if progress != nil {
+		progress.total = total
+		progress.write(progressLine{Type: "progress", Total: total})
+	}
+}
+
+// addProgress marks n more pages done, if -progress is set
+func addProgress(n int) {
+	if progress != nil {
+		progress.done += n
+		progress.write(progressLine{Type: "progress", Done: progress.done, Total: progress.total})
+	}
+}
+
+// warn writes a warning, with the progress so far
+func (p *progressWriter) warn(msg string) {
+	p.write(progressLine{Type: "warning", Done: p.done, Total: p.total, Message: msg})
+}
+
+// write writes a line, dropping progress if the reader has gone away rather than failing the
+// split
+func (p *progressWriter) write(line progressLine) {
+	if p.enc == nil {
+		return
+	}
+	if err := p.enc.Encode(line); err != nil {
+		p.enc = nil
+		logger.Warn("Unable to write progress: " + err.Error())
+	}
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"errors"
+)
+
+// qrVersion is the layout of a QR code version at error correction level M
+type qrVersion struct {
+	ecPerBlock int
+	blocks     []int //data codewords of each block, short blocks first
+	alignment  []int //row and column centers of the alignment patterns
+}
+
+// qrVersions are versions 1 to 10 at level M (ISO/IEC 18004 tables 9 and E.1), which hold up
+// to 213 bytes
+var qrVersions = []qrVersion{
+	{10, []int{16}, nil},
+	{16, []int{28}, []int{6, 18}},
+	{26, []int{44}, []int{6, 22}},
+	{18, []int{32, 32}, []int{6, 26}},
+	{24, []int{43, 43}, []int{6, 30}},
+	{16, []int{27, 27, 27, 27}, []int{6, 34}},
+	{18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	{22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	{22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	{26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+// errQRTooLong is returned for data that doesn't fit the largest supported QR code
+var errQRTooLong = errors.New("data too long for a QR code")
+
+// qrCode is a QR code symbol, true for dark modules, indexed [row][column]
+type qrCode [][]bool
+
+// encodeQR returns the smallest QR code at error correction level M holding data in byte mode
+func encodeQR(data []byte) (qrCode, error) {
+	for v, ver := range qrVersions {
+		version := v + 1
+		capacity := 0
+		for _, n := range ver.blocks {
+			capacity += n
+		}
+
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) > 8*capacity {
+			continue
+		}
+
+		//mode indicator, count, data, terminator and padding
+		var bits qrBits
+		bits.append(0x4, 4)
+		bits.append(len(data), countBits)
+		for _, b := range data {
+			bits.append(int(b), 8)
+		}
+		for i := 0; i < 4 && len(bits) < 8*capacity; i++ {
+			bits = append(bits, false)
+		}
+		for len(bits)%8 != 0 {
+			bits = append(bits, false)
+		}
+		codewords := bits.bytes()
+		for pad := 0; len(codewords) < capacity; pad++ {
+			codewords = append(codewords, [2]byte{0xec, 0x11}[pad%2])
+		}
+
+		return newQRCode(version, ver, qrInterleave(ver, codewords)), nil
+	}
+
+	return nil, errQRTooLong
+}
+
+// qrBits is a bit stream, most significant bit first
+type qrBits []bool
+
+// append adds the low n bits of v
+func (b *qrBits) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>uint(i)&1 == 1)
+	}
+}
+
+// bytes returns the bits packed into bytes
+func (b qrBits) bytes() []byte {
+	out := make([]byte, (len(b)+7)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return out
+}
+
+// qrInterleave splits data into the blocks of the version, adds their error correction codewords
+// and returns the codewords in the order they are placed
+func qrInterleave(ver qrVersion, data []byte) []byte {
+	divisor := rsDivisor(ver.ecPerBlock)
+	var blocks, ecs [][]byte
+	for _, n := range ver.blocks {
+		blocks = append(blocks, data[:n])
+		ecs = append(ecs, rsRemainder(data[:n], divisor))
+		data = data[n:]
+	}
+
+	var out []byte
+	longest := ver.blocks[len(ver.blocks)-1]
+	for i := 0; i < longest; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < ver.ecPerBlock; i++ {
+		for _, ec := range ecs {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+// gfMultiply multiplies in GF(256) modulo the QR polynomial x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11d
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the coefficients of the Reed-Solomon generator polynomial of the given
+// degree, highest first without the leading 1
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	var root byte = 1
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// qrBuilder places the modules of a QR code
+type qrBuilder struct {
+	size     int
+	modules  qrCode
+	function [][]bool //modules of finder, timing, alignment, format and version patterns
+}
+
+// newQRCode returns the symbol of a version holding codewords, with the mask of the lowest
+// penalty
+func newQRCode(version int, ver qrVersion, codewords []byte) qrCode {
+	size := 17 + 4*version
+	b := &qrBuilder{size: size, modules: make(qrCode, size), function: make([][]bool, size)}
+	for i := range b.modules {
+		b.modules[i] = make([]bool, size)
+		b.function[i] = make([]bool, size)
+	}
+
+	b.drawFunctionPatterns(version, ver)
+	b.drawCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		b.applyMask(mask)
+		b.drawFormat(mask)
+		if p := b.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		b.applyMask(mask) //masks are their own inverse
+	}
+	b.applyMask(best)
+	b.drawFormat(best)
+
+	return b.modules
+}
+
+// set sets a function module
+func (b *qrBuilder) set(row, col int, dark bool) {
+	b.modules[row][col] = dark
+	b.function[row][col] = true
+}
+
+// drawFunctionPatterns draws the patterns that aren't data, reserving the format areas
+func (b *qrBuilder) drawFunctionPatterns(version int, ver qrVersion) {
+	for i := 0; i < b.size; i++ {
+		b.set(6, i, i%2 == 0)
+		b.set(i, 6, i%2 == 0)
+	}
+
+	//finders with their separators
+	for _, corner := range [][2]int{{3, 3}, {3, b.size - 4}, {b.size - 4, 3}} {
+		for dr := -4; dr <= 4; dr++ {
+			for dc := -4; dc <= 4; dc++ {
+				r, c := corner[0]+dr, corner[1]+dc
+				if r < 0 || r >= b.size || c < 0 || c >= b.size {
+					continue
+				}
+				d := ring(dr, dc)
+				b.set(r, c, d != 2 && d != 4)
+			}
+		}
+	}
+
+	//alignment patterns, except where they would overlap the finders
+	n := len(ver.alignment)
+	for i, r := range ver.alignment {
+		for j, c := range ver.alignment {
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue
+			}
+			for dr := -2; dr <= 2; dr++ {
+				for dc := -2; dc <= 2; dc++ {
+					b.set(r+dr, c+dc, ring(dr, dc) != 1)
+				}
+			}
+		}
+	}
+
+	//reserve the format areas, drawn once the mask is chosen
+	b.drawFormat(0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1f25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>uint(i)&1 == 1
+			a, c := b.size-11+i%3, i/3
+			b.set(c, a, dark)
+			b.set(a, c, dark)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information for level M and mask
+func (b *qrBuilder) drawFormat(mask int) {
+	data := 0<<3 | mask //level M is 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		b.set(i, 8, bit(i))
+	}
+	b.set(7, 8, bit(6))
+	b.set(8, 8, bit(7))
+	b.set(8, 7, bit(8))
+	for i := 9; i < 15; i++ {
+		b.set(8, 14-i, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		b.set(8, b.size-1-i, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		b.set(b.size-15+i, 8, bit(i))
+	}
+	b.set(b.size-8, 8, true) //dark module
+}
+
+// drawCodewords places the codewords in the zigzag order of two-module columns, from the bottom
+// right corner, skipping function modules. Remainder bits stay light.
+func (b *qrBuilder) drawCodewords(codewords []byte) {
+	i := 0
+	for right := b.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < b.size; vert++ {
+			for j := 0; j < 2; j++ {
+				col := right - j
+				row := vert
+				if (right+1)&2 == 0 {
+					row = b.size - 1 - vert
+				}
+				if !b.function[row][col] && i < 8*len(codewords) {
+					b.modules[row][col] = codewords[i/8]>>uint(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by mask
+func (b *qrBuilder) applyMask(mask int) {
+	for r := 0; r < b.size; r++ {
+		for c := 0; c < b.size; c++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (r+c)%2 == 0
+			case 1:
+				invert = r%2 == 0
+			case 2:
+				invert = c%3 == 0
+			case 3:
+				invert = (r+c)%3 == 0
+			case 4:
+				invert = (r/2+c/3)%2 == 0
+			case 5:
+				invert = r*c%2+r*c%3 == 0
+			case 6:
+				invert = (r*c%2+r*c%3)%2 == 0
+			case 7:
+				invert = ((r+c)%2+r*c%3)%2 == 0
+			}
+			if invert && !b.function[r][c] {
+				b.modules[r][c] = !b.modules[r][c]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol by the rules for choosing a mask: runs of five or more modules of a
+// color, 2x2 blocks of a color, finder-like patterns and imbalance of dark and light modules
+func (b *qrBuilder) penalty() int {
+	m := b.modules
+	at := func(r, c int, transpose bool) bool {
+		if transpose {
+			return m[c][r]
+		}
+		return m[r][c]
+	}
+
+	var score, dark int
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, transpose := range []bool{false, true} {
+		for r := 0; r < b.size; r++ {
+			run := 1
+			for c := 1; c <= b.size; c++ {
+				if c < b.size && at(r, c, transpose) == at(r, c-1, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += 3 + run - 5
+				}
+				run = 1
+			}
+
+			//1:1:3:1:1 with four light modules on either side
+			for c := 0; c+7 <= b.size; c++ {
+				match := true
+				for k, v := range finder {
+					match = match && at(r, c+k, transpose) == v
+				}
+				if !match {
+					continue
+				}
+				light := func(from, to int) bool {
+					for k := from; k < to; k++ {
+						if k >= 0 && k < b.size && at(r, k, transpose) {
+							return false
+						}
+					}
+					return true
+				}
+				if light(c-4, c) || light(c+7, c+11) {
+					score += 40
+				}
+			}
+		}
+	}
+
+	for r := 0; r < b.size; r++ {
+		for c := 0; c < b.size; c++ {
+			if m[r][c] {
+				dark++
+			}
+			if r+1 < b.size && c+1 < b.size && m[r][c] == m[r+1][c] && m[r][c] == m[r][c+1] && m[r][c] == m[r+1][c+1] {
+				score += 3
+			}
+		}
+	}
+
+	total := b.size * b.size
+	deviation := abs(dark*20-total*10) / total
+	return score + deviation*10
+}
+
+// ring returns which square ring around the center of a pattern the offset lies on, 0 for the
+// center itself
+func ring(dr, dc int) int {
+	if abs(dr) > abs(dc) {
+		return abs(dr)
+	}
+	return abs(dc)
+}
+
+// abs returns the absolute value of v
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
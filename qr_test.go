@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// Test the error correction codewords against the published examples of ISO/IEC 18004 (annex I)
+// and thonky.com's QR code tutorial, both 1-M symbols.
+func TestQRErrorCorrection(t *testing.T) {
+	tests := []struct {
+		data, ec []byte
+	}{
+		{
+			[]byte{0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11},
+			[]byte{0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87, 0x2c, 0x55},
+		},
+		{
+			[]byte{0x20, 0x5b, 0x0b, 0x78, 0xd1, 0x72, 0xdc, 0x4d, 0x43, 0x40, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11},
+			[]byte{0xc4, 0x23, 0x27, 0x77, 0xeb, 0xd7, 0xe7, 0xe2, 0x5d, 0x17},
+		},
+	}
+	for _, test := range tests {
+		if got := rsRemainder(test.data, rsDivisor(10)); !bytes.Equal(got, test.ec) {
+			t.Errorf("% x: error correction % x, expected % x", test.data, got, test.ec)
+		}
+		if got := qrInterleave(qrVersions[0], test.data); !bytes.Equal(got, append(append([]byte{}, test.data...), test.ec...)) {
+			t.Errorf("% x: codewords % x", test.data, got)
+		}
+	}
+}
+
+// Test that payloads at the capacity of each version get that version, and that the symbols
+// decode to the payload with valid format, version and error correction codewords.
+func TestQRDecode(t *testing.T) {
+	tests := []struct {
+		length, version int
+	}{
+		{0, 1}, {1, 1}, {14, 1},
+		{15, 2}, {26, 2},
+		{27, 3}, {42, 3},
+		{43, 4}, {62, 4},
+		{63, 5}, {84, 5},
+		{85, 6}, {106, 6},
+		{107, 7}, {122, 7},
+		{123, 8}, {152, 8},
+		{153, 9}, {180, 9},
+		{181, 10}, {213, 10},
+	}
+	for _, test := range tests {
+		data := make([]byte, test.length)
+		for i := range data {
+			data[i] = byte(i*37 + test.length)
+		}
+		code, err := encodeQR(data)
+		if err != nil {
+			t.Errorf("%d bytes: %v", test.length, err)
+			continue
+		}
+		if expected := 17 + 4*test.version; len(code) != expected {
+			t.Errorf("%d bytes: size %d, expected %d for version %d", test.length, len(code), expected, test.version)
+			continue
+		}
+		got, err := decodeQR(code)
+		if err != nil {
+			t.Errorf("%d bytes: %v", test.length, err)
+		} else if !bytes.Equal(got, data) {
+			t.Errorf("%d bytes: decoded % x", test.length, got)
+		}
+	}
+
+	if _, err := encodeQR(make([]byte, 214)); err != errQRTooLong {
+		t.Errorf("214 bytes: %v, expected %v", err, errQRTooLong)
+	}
+	if code, err := encodeQR([]byte("https://example.com/track?doc=invoice-1042&part=3")); err != nil {
+		t.Error(err)
+	} else if got, err := decodeQR(code); err != nil || string(got) != "https://example.com/track?doc=invoice-1042&part=3" {
+		t.Errorf("decoded %q, %v", got, err)
+	}
+}
+
+// qrTestVersions are the block structures of versions 1 to 10 at level M (ISO/IEC 18004 table
+// 9): error correction codewords per block, then the number of blocks and data codewords of each
+// of the two groups, and the alignment pattern centers (table E.1)
+var qrTestVersions = []struct {
+	ec, blocks1, data1, blocks2, data2 int
+	alignment                          []int
+}{
+	{10, 1, 16, 0, 0, nil},
+	{16, 1, 28, 0, 0, []int{6, 18}},
+	{26, 1, 44, 0, 0, []int{6, 22}},
+	{18, 2, 32, 0, 0, []int{6, 26}},
+	{24, 2, 43, 0, 0, []int{6, 30}},
+	{16, 4, 27, 0, 0, []int{6, 34}},
+	{18, 4, 31, 0, 0, []int{6, 22, 38}},
+	{22, 2, 38, 2, 39, []int{6, 24, 42}},
+	{22, 3, 36, 2, 37, []int{6, 26, 46}},
+	{26, 4, 43, 1, 44, []int{6, 28, 50}},
+}
+
+// qrTestFormats are the format information strings of level M by mask (ISO/IEC 18004 table C.1)
+var qrTestFormats = []string{
+	"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+	"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+}
+
+// qrTestVersionInfo are the version information strings of versions 7 to 10 (table D.1)
+var qrTestVersionInfo = []string{
+	"000111110010010100", "001000010110111100", "001001101010011001", "001010010011010011",
+}
+
+// qrTestMasks are the data mask conditions by mask (table 10)
+var qrTestMasks = []func(r, c int) bool{
+	func(r, c int) bool { return (r+c)%2 == 0 },
+	func(r, c int) bool { return r%2 == 0 },
+	func(r, c int) bool { return c%3 == 0 },
+	func(r, c int) bool { return (r+c)%3 == 0 },
+	func(r, c int) bool { return (r/2+c/3)%2 == 0 },
+	func(r, c int) bool { return r*c%2+r*c%3 == 0 },
+	func(r, c int) bool { return (r*c%2+r*c%3)%2 == 0 },
+	func(r, c int) bool { return ((r+c)%2+r*c%3)%2 == 0 },
+}
+
+// decodeQR decodes a level M byte mode symbol as a reader would, checking its finder and timing
+// patterns, both copies of its format and version information and its error correction
+func decodeQR(code qrCode) ([]byte, error) {
+	size := len(code)
+	version := (size - 17) / 4
+	if version < 1 || version > len(qrTestVersions) || size != 17+4*version {
+		return nil, fmt.Errorf("size %d", size)
+	}
+	ver := qrTestVersions[version-1]
+
+	//function modules: finders with separators and format areas, timing, alignment, version
+	function := make([][]bool, size)
+	for i := range function {
+		function[i] = make([]bool, size)
+	}
+	mark := func(row, col, height, width int) {
+		for r := row; r < row+height; r++ {
+			for c := col; c < col+width; c++ {
+				function[r][c] = true
+			}
+		}
+	}
+	mark(0, 0, 9, 9)
+	mark(0, size-8, 9, 8)
+	mark(size-8, 0, 8, 9)
+	mark(6, 0, 1, size)
+	mark(0, 6, size, 1)
+	last := len(ver.alignment) - 1
+	for i, r := range ver.alignment {
+		for j, c := range ver.alignment {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			mark(r-2, c-2, 5, 5)
+			if !code[r][c] || code[r-1][c] || !code[r-2][c] {
+				return nil, fmt.Errorf("no alignment pattern at %d,%d", r, c)
+			}
+		}
+	}
+	if version >= 7 {
+		mark(0, size-11, 6, 3)
+		mark(size-11, 0, 3, 6)
+	}
+
+	for _, corner := range [][2]int{{0, 0}, {0, size - 7}, {size - 7, 0}} {
+		for i, dark := range []bool{true, false, true, true, true, false, true} {
+			if code[corner[0]+3][corner[1]+i] != dark || code[corner[0]+i][corner[1]+3] != dark {
+				return nil, fmt.Errorf("no finder pattern at %d,%d", corner[0], corner[1])
+			}
+		}
+	}
+	for i := 8; i < size-8; i++ {
+		if code[6][i] != (i%2 == 0) || code[i][6] != (i%2 == 0) {
+			return nil, fmt.Errorf("timing patterns broken at %d", i)
+		}
+	}
+	if !code[size-8][8] {
+		return nil, fmt.Errorf("no dark module")
+	}
+
+	//format information, bit 0 the least significant
+	var format1, format2 int
+	for i := 0; i < 15; i++ {
+		var r, c int
+		switch {
+		case i < 6:
+			r, c = i, 8
+		case i < 8:
+			r, c = i+1, 8
+		case i == 8:
+			r, c = 8, 7
+		default:
+			r, c = 8, 14-i
+		}
+		if code[r][c] {
+			format1 |= 1 << uint(i)
+		}
+		if i < 8 {
+			r, c = 8, size-1-i
+		} else {
+			r, c = size-15+i, 8
+		}
+		if code[r][c] {
+			format2 |= 1 << uint(i)
+		}
+	}
+	if format1 != format2 {
+		return nil, fmt.Errorf("format information copies %015b and %015b differ", format1, format2)
+	}
+	mask := -1
+	for m, s := range qrTestFormats {
+		if v, _ := strconv.ParseInt(s, 2, 32); int(v) == format1 {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		return nil, fmt.Errorf("format information %015b isn't level M", format1)
+	}
+
+	if version >= 7 {
+		var info1, info2 int
+		for i := 0; i < 18; i++ {
+			if code[i/3][size-11+i%3] {
+				info1 |= 1 << uint(i)
+			}
+			if code[size-11+i%3][i/3] {
+				info2 |= 1 << uint(i)
+			}
+		}
+		expected, _ := strconv.ParseInt(qrTestVersionInfo[version-7], 2, 32)
+		if info1 != int(expected) || info2 != int(expected) {
+			return nil, fmt.Errorf("version information %018b and %018b, expected %s", info1, info2, qrTestVersionInfo[version-7])
+		}
+	}
+
+	//unmasked data bits in zigzag order
+	var bits []bool
+	upward := true
+	for right := size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for k := 0; k < size; k++ {
+			r := k
+			if upward {
+				r = size - 1 - k
+			}
+			for _, c := range []int{right, right - 1} {
+				if !function[r][c] {
+					bits = append(bits, code[r][c] != qrTestMasks[mask](r, c))
+				}
+			}
+		}
+		upward = !upward
+	}
+	total := ver.blocks1*(ver.data1+ver.ec) + ver.blocks2*(ver.data2+ver.ec)
+	if remainder := len(bits) - 8*total; remainder != 0 && remainder != 7 {
+		return nil, fmt.Errorf("%d data modules for %d codewords", len(bits), total)
+	}
+	codewords := make([]byte, total)
+	for i := range codewords {
+		for j := 0; j < 8; j++ {
+			if bits[8*i+j] {
+				codewords[i] |= 0x80 >> uint(j)
+			}
+		}
+	}
+
+	//deinterleave the blocks and check their syndromes are zero
+	var blocks [][]byte
+	for i := 0; i < ver.blocks1+ver.blocks2; i++ {
+		blocks = append(blocks, nil)
+	}
+	longest := ver.data1
+	if ver.blocks2 > 0 {
+		longest = ver.data2
+	}
+	for i := 0; i < longest; i++ {
+		for b := range blocks {
+			if i < ver.data1 || b >= ver.blocks1 {
+				blocks[b] = append(blocks[b], codewords[0])
+				codewords = codewords[1:]
+			}
+		}
+	}
+	var data []byte
+	for _, b := range blocks {
+		data = append(data, b...)
+	}
+	for i := 0; i < ver.ec; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[0])
+			codewords = codewords[1:]
+		}
+	}
+	var exp [255]int
+	log := make([]int, 256)
+	for i, x := 0, 1; i < 255; i++ {
+		exp[i], log[x] = x, i
+		if x <<= 1; x > 0xff {
+			x ^= 0x11d
+		}
+	}
+	for b, block := range blocks {
+		for i := 0; i < ver.ec; i++ {
+			s := 0
+			for _, cw := range block {
+				if s != 0 {
+					s = exp[(log[s]+i)%255]
+				}
+				s ^= int(cw)
+			}
+			if s != 0 {
+				return nil, fmt.Errorf("block %d: syndrome %d is %d", b, i, s)
+			}
+		}
+	}
+
+	//byte mode segment, terminator and padding
+	read := func(pos, n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v <<= 1
+			if data[(pos+i)/8]>>uint(7-(pos+i)%8)&1 == 1 {
+				v |= 1
+			}
+		}
+		return v
+	}
+	if mode := read(0, 4); mode != 0x4 {
+		return nil, fmt.Errorf("mode %04b, expected byte mode", mode)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	count := read(4, countBits)
+	end := 4 + countBits + 8*count
+	if end > 8*len(data) {
+		return nil, fmt.Errorf("count %d over the capacity", count)
+	}
+	out := make([]byte, count)
+	for i := range out {
+		out[i] = byte(read(4+countBits+8*i, 8))
+	}
+	for pos := end; pos < 8*len(data) && (pos < end+4 || pos%8 != 0); pos++ {
+		if read(pos, 1) != 0 {
+			return nil, fmt.Errorf("terminator bit %d set", pos)
+		}
+	}
+	for i, pos := 0, (end+4+7)/8*8; pos < 8*len(data); i, pos = i+1, pos+8 {
+		if p := read(pos, 8); p != [2]int{0xec, 0x11}[i%2] {
+			return nil, fmt.Errorf("pad codeword %#x", p)
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// runReorder writes the pages of a PDF in a new order, given as a list of the input pages or a
+// file mapping them to their new positions
+func runReorder(args []string) {
+	fs := flag.NewFlagSet("reorder", flag.ExitOnError)
+	output := outputFlags(fs)
+	out := fs.String("out", "", "output PDF")
+	order := fs.String("order", "", "input pages in their new order, e.g. \"2,1,5-10,3,4\"; every page must be listed once")
+	mapFile := fs.String("map", "", "`file` of \"old new\" lines, each moving input page old to position new, instead of -order")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter reorder: -out reordered.pdf -order pages|-map file input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("reorder", output)
+	defer finishOutput()
+
+	if *out == "" || fs.NArg() != 1 || (*order == "") == (*mapFile == "") {
+		usage(fs)
+	}
+
+	in := fs.Arg(0)
+	pdf, f, err := openPDF(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	var numbers []int
+	if *order != "" {
+		if numbers, err = parseRanges(*order, len(pdf.PageList)); err == nil {
+			err = checkPermutation(numbers, len(pdf.PageList))
+		}
+		if err != nil {
+			argError("Invalid -order:", err)
+		}
+	} else if numbers, err = readPageMap(*mapFile, len(pdf.PageList)); err != nil {
+		argError("Invalid -map:", err)
+	}
+
+	pages := make([]*model.PdfPage, len(numbers))
+	for i, n := range numbers {
+		pages[i] = pdf.PageList[n-1]
+	}
+
+	logInfo("Writing", *out)
+
+	if _, err := writePDF(*out, pdfPart{pages: pages}); err != nil {
+		exitError(writeStatus(err), err)
+	}
+
+	addResult(fileResult{File: *out, Pages: len(pages)})
+	logInfo("Wrote", len(pages), "pages.")
+}
+
+// checkPermutation checks that the 1-based page numbers, from 1 to numPages, hold each page of
+// the document once
+func checkPermutation(numbers []int, numPages int) error {
+	seen := make([]bool, numPages+1)
+	for _, n := range numbers {
+		if seen[n] {
+			return fmt.Errorf("page %d listed more than once", n)
+		}
+		seen[n] = true
+	}
+	for n := 1; n < len(seen); n++ {
+		if !seen[n] {
+			return fmt.Errorf("page %d missing", n)
+		}
+	}
+	return nil
+}
+
+// readPageMap reads a file of "old new" lines, the 1-based number of an input page and its
+// position in the output, and returns the input pages in their new order. Every page must be
+// moved once. Blank lines and lines starting with "#" are skipped, and the numbers may also be
+// separated by a comma or "->".
+func readPageMap(fn string, numPages int) ([]int, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	numbers := make([]int, numPages)
+	moved := make([]bool, numPages+1)
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(strings.NewReplacer(",", " ", "->", " ").Replace(text))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: %q must be \"old new\"", line, text)
+		}
+		old, err := strconv.Atoi(fields[0])
+		if err != nil || old < 1 || old > numPages {
+			return nil, fmt.Errorf("line %d: invalid page %q (document has %d pages)", line, fields[0], numPages)
+		}
+		pos, err := strconv.Atoi(fields[1])
+		if err != nil || pos < 1 || pos > numPages {
+			return nil, fmt.Errorf("line %d: invalid position %q (document has %d pages)", line, fields[1], numPages)
+		}
+		if moved[old] {
+			return nil, fmt.Errorf("line %d: page %d moved more than once", line, old)
+		}
+		if numbers[pos-1] != 0 {
+			return nil, fmt.Errorf("line %d: position %d already holds page %d", line, pos, numbers[pos-1])
+		}
+		moved[old] = true
+		numbers[pos-1] = old
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+
+	for n := 1; n <= numPages; n++ {
+		if !moved[n] {
+			return nil, fmt.Errorf("page %d has no new position", n)
+		}
+	}
+	return numbers, nil
+}
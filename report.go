@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+)
+
+// jobReportHeader is the header row of the job report
+var jobReportHeader = []string{"source", "pages", "output", "page_count", "bytes", "duration_ms", "status", "error"}
+
+// output statuses in the job report and events
+const (
+	statusWritten = "written"
+	statusSkipped = "skipped" //failed preflight
+	statusFailed  = "failed"
+)
+
+// jobReport is the CSV report of -report, with a row for each output: the input and its pages it
+// holds, where it was written, its page count and size, how long it took and whether it was
+// written, with the error if not. Rows are appended, so the runs of a batch can share a report.
+type jobReport struct {
+	f   *os.File
+	csv *csv.Writer
+}
+
+// createJobReport opens the job report fn, creating it with a header row if it doesn't exist
+func createJobReport(fn string) (*jobReport, error) {
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r := &jobReport{f: f, csv: csv.NewWriter(f)}
+	if fi.Size() == 0 {
+		r.csv.Write(jobReportHeader)
+	}
+	return r, nil
+}
+
+// add writes the row of an output
+func (r *jobReport) add(rec outputRecord) error {
+	status, msg := rec.outcome()
+	size := ""
+	if status == statusWritten {
+		size = strconv.FormatInt(rec.status.size, 10)
+	}
+
+	//flush each row so the report covers every output, even if the run is cut short
+	r.csv.Write([]string{rec.source, rec.pageRanges, rec.status.file, strconv.Itoa(rec.pages), size, strconv.FormatInt(rec.duration.Milliseconds(), 10), status, msg})
+	r.csv.Flush()
+	return r.csv.Error()
+}
+
+// outcome returns the status of the output, statusWritten, statusSkipped or statusFailed, and
+// the error, if any
+func (rec outputRecord) outcome() (string, string) {
+	switch {
+	case rec.err != nil:
+		return statusFailed, rec.err.Error()
+	case rec.status.skipped != nil:
+		return statusSkipped, rec.status.skipped.Error()
+	}
+	return statusWritten, ""
+}
+
+// close finishes the job report
+func (r *jobReport) close() error {
+	r.csv.Flush()
+	if err := r.csv.Error(); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
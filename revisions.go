@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// revision is one incremental revision of a PDF: the file up to the end of one of its %%EOF
+// markers, each incremental update appending a revision to the previous one
+type revision struct {
+	end  int64 //length of the file up to and including this revision
+	xref int64 //offset of its cross-reference section
+}
+
+var reRevisionEnd = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF(\r\n|\r|\n)?`)
+
+// revisionScanSize is the chunk size the file is scanned in for revisions, and
+// revisionScanOverlap how far chunks overlap so markers across chunk boundaries are found
+const (
+	revisionScanSize    = 1 << 20
+	revisionScanOverlap = 64
+)
+
+// findRevisions returns the revisions of the PDF r of size bytes, oldest first.
+// The first-page trailer of a linearized file, whose startxref is 0, doesn't end a revision.
+func findRevisions(r io.ReaderAt, size int64) ([]revision, error) {
+	var revs []revision
+	buf := make([]byte, revisionScanSize)
+	for offset := int64(0); offset < size; offset += revisionScanSize - revisionScanOverlap {
+		n, err := r.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		last := offset+int64(n) >= size
+
+		for _, m := range reRevisionEnd.FindAllSubmatchIndex(buf[:n], -1) {
+			//matches starting in the overlap are found in full in the next chunk
+			if !last && m[0] >= n-revisionScanOverlap {
+				break
+			}
+			end := offset + int64(m[1])
+			if len(revs) > 0 && end <= revs[len(revs)-1].end {
+				continue
+			}
+			xref, _ := strconv.ParseInt(string(buf[m[2]:m[3]]), 10, 64)
+			if xref == 0 || xref >= end {
+				continue
+			}
+			revs = append(revs, revision{end: end, xref: xref})
+		}
+
+		if last {
+			break
+		}
+	}
+
+	return revs, nil
+}
+
+// selectRevision returns the revision in revs given by spec: "latest", a revision number
+// counting from 1 for the original document, or "@" and the offset a revision ends at
+func selectRevision(revs []revision, spec string) (revision, error) {
+	if len(revs) == 0 {
+		return revision{}, fmt.Errorf("no revisions found")
+	}
+
+	if spec == "latest" {
+		return revs[len(revs)-1], nil
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		end, err := strconv.ParseInt(spec[1:], 10, 64)
+		if err != nil {
+			return revision{}, fmt.Errorf("invalid revision offset %q", spec)
+		}
+		for _, rev := range revs {
+			if rev.end == end {
+				return rev, nil
+			}
+		}
+		return revision{}, fmt.Errorf("no revision ends at offset %d", end)
+	}
+
+	n, err := strconv.Atoi(spec)
+	if err != nil {
+		return revision{}, fmt.Errorf("invalid revision %q, must be a number, @offset or latest", spec)
+	}
+	if n < 1 || n > len(revs) {
+		return revision{}, fmt.Errorf("revision %d out of bounds (document has %d revisions)", n, len(revs))
+	}
+	return revs[n-1], nil
+}
+
+// runRevisions runs a revisions subcommand, e.g. "pdf-splitter revisions extract"
+func runRevisions(args []string) {
+	if len(args) == 0 || args[0] != "extract" {
+		fmt.Fprintln(os.Stderr, "Usage of pdf-splitter revisions: extract [flags] input.pdf")
+		exit(exitUsage)
+	}
+	runRevisionsExtract(args[1:])
+}
+
+// runRevisionsExtract writes each incremental revision of a PDF to its own file. Each file is
+// the input up to the end of the revision, byte for byte, so signatures over a revision still
+// validate in its file.
+func runRevisionsExtract(args []string) {
+	fs := flag.NewFlagSet("revisions extract", flag.ExitOnError)
+	output := outputFlags(fs)
+	out := fs.String("out", "", "directory for the revision PDFs, named after the input with \"-rev1.pdf\", \"-rev2.pdf\", ...")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter revisions extract: -out directory input.pdf")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("revisions extract", output)
+	defer finishOutput()
+
+	if *out == "" || fs.NArg() != 1 {
+		usage(fs)
+	}
+
+	in := fs.Arg(0)
+	f, err := openInput(in)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	defer f.Close()
+
+	revs, err := findRevisions(f, f.size)
+	if err != nil {
+		fatalInput(in, err)
+	}
+	if len(revs) == 0 {
+		fatal("No revisions found in", in)
+	}
+
+	if err = os.MkdirAll(*out, 0755); err != nil {
+		exitError(exitWrite, "Unable to create output directory:", err)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(in), filepath.Ext(in))
+	for i, rev := range revs {
+		fn := path.Join(*out, fmt.Sprintf("%s-rev%d.pdf", base, i+1))
+		logInfo("Writing", fn)
+		if err = writeRevision(fn, io.NewSectionReader(f, 0, rev.end)); err != nil {
+			exitError(writeStatus(err), err)
+		}
+		addResult(fileResult{File: fn})
+	}
+
+	logInfo("Wrote", len(revs), "revisions.")
+}
+
+// writeRevision copies the revision r to the file fn
+func writeRevision(fn string, r io.Reader) error {
+	w, err := os.Create(fn)
+	if err != nil {
+		return fmt.Errorf("unable to create PDF file %s: %w", fn, err)
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("unable to write PDF file %s: %w", fn, err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("unable to write PDF file %s: %w", fn, err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"math"
+
+	"github.com/unidoc/unidoc/pdf/contentstream"
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// minOrientationText is the number of text bytes a page needs for -auto-rotate to tell its
+// orientation, so a stray page number or stamp doesn't rotate an otherwise empty page
+const minOrientationText = 20
+
+// autoRotate is a pageTransform setting the /Rotate of pages so their text reads upright, for
+// batches of scans fed in sideways or upside down. The orientation of a page is that of most of
+// its text, drawn by its content and the forms it draws; pages without enough text, or without a
+// clear majority, are kept as they are. Scans need a text layer from OCR.
+func autoRotate(pages []*model.PdfPage) ([]*model.PdfPage, error) {
+	rotated := make([]*model.PdfPage, len(pages))
+
+	for i, p := range pages {
+		rotated[i] = p
+
+		angle, ok := textOrientation(p)
+		if !ok {
+			continue
+		}
+		_, rotate, err := visibleBox(p)
+		if err != nil {
+			return nil, err
+		}
+		//text running at angle counterclockwise reads upright when the page is shown rotated
+		//by the same angle clockwise, whatever its rotation was
+		if rotate == angle {
+			continue
+		}
+
+		dup := p.Duplicate()
+		dup.Rotate = &angle
+		rotated[i] = dup
+	}
+
+	return rotated, nil
+}
+
+// textOrientation returns the direction most of the text of p runs in, counterclockwise in
+// degrees: 0 for text reading left to right, 90 for text running up the page, 180 for upside
+// down text and 270 for text running down. It returns false if the page has too little text, or
+// no direction has more than half of it.
+func textOrientation(p *model.PdfPage) (int64, bool) {
+	content, err := p.GetAllContentStreams()
+	if err != nil {
+		return 0, false
+	}
+	res, err := pageResources(p)
+	if err != nil || res == nil {
+		return 0, false
+	}
+
+	var amounts [4]int
+	textDirections(content, resourcesDict(res), identity, 0, func(quadrant, n int) {
+		amounts[quadrant] += n
+	})
+
+	total, best := 0, 0
+	for q, n := range amounts {
+		total += n
+		if n > amounts[best] {
+			best = q
+		}
+	}
+	if total < minOrientationText || 2*amounts[best] <= total {
+		return 0, false
+	}
+	return int64(best * 90), true
+}
+
+// textDirections calls f with the direction of each text shown by content, with resources res,
+// at the transformation ctm, as a quarter turn counterclockwise from 0 to 3, and its length in
+// bytes, following forms
+func textDirections(content string, res *core.PdfObjectDictionary, ctm matrix, depth int, f func(quadrant, n int)) {
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil {
+		return
+	}
+	var xobjs *core.PdfObjectDictionary
+	if res != nil {
+		xobjs, _ = core.TraceToDirectObject(res.Get("XObject")).(*core.PdfObjectDictionary)
+	}
+
+	var stack []matrix
+	tm := identity
+	for _, op := range *ops {
+		switch op.Operand {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := toMatrix(op.Params); ok {
+				ctm = m.mul(ctm)
+			}
+		case "BT":
+			tm = identity
+		case "Tm":
+			if m, ok := toMatrix(op.Params); ok {
+				tm = m
+			}
+		case "Tj", "'", "\"", "TJ":
+			if n := textLength(op.Params); n > 0 {
+				//the direction of the baseline, (1, 0) in text space, on the page
+				m := tm.mul(ctm)
+				if m[0] != 0 || m[1] != 0 {
+					angle := math.Atan2(m[1], m[0]) * 180 / math.Pi
+					f((int(math.Floor(angle/90+0.5))%4+4)%4, n)
+				}
+			}
+		case "Do":
+			if len(op.Params) != 1 || xobjs == nil || depth >= maxFormDepth {
+				continue
+			}
+			name, ok := op.Params[0].(*core.PdfObjectName)
+			if !ok {
+				continue
+			}
+			stream, ok := core.TraceToDirectObject(xobjs.Get(*name)).(*core.PdfObjectStream)
+			if !ok || !isForm(stream) {
+				continue
+			}
+			formCTM := ctm
+			if arr, ok := core.TraceToDirectObject(stream.Get("Matrix")).(*core.PdfObjectArray); ok {
+				if m, ok := toMatrix(*arr); ok {
+					formCTM = m.mul(ctm)
+				}
+			}
+			formContent, err := core.DecodeStream(stream)
+			if err != nil {
+				continue
+			}
+			formRes, _ := core.TraceToDirectObject(stream.Get("Resources")).(*core.PdfObjectDictionary)
+			textDirections(string(formContent), formRes, formCTM, depth+1, f)
+		}
+	}
+}
+
+// textLength returns the number of bytes of the strings shown by a text-showing operator with
+// params, the last of which is the string, or an array of strings and adjustments for TJ
+func textLength(params []core.PdfObject) int {
+	if len(params) == 0 {
+		return 0
+	}
+	switch o := params[len(params)-1].(type) {
+	case *core.PdfObjectString:
+		return len(*o)
+	case *core.PdfObjectArray:
+		n := 0
+		for _, item := range *o {
+			if s, ok := item.(*core.PdfObjectString); ok {
+				n += len(*s)
+			}
+		}
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// peakRSS isn't supported on this OS
+func peakRSS() int64 {
+	return 0
+}
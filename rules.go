@@ -0,0 +1,535 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/unidoc/unidoc/pdf/contentstream"
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// ruleKind is the type of a -rule expression
+type ruleKind int
+
+const (
+	ruleBool ruleKind = iota
+	ruleNumber
+	ruleString
+	rulePage //page, prev or next, compared by page number
+)
+
+func (k ruleKind) String() string {
+	return [...]string{"boolean", "number", "string", "page"}[k]
+}
+
+// paperSizes are the paper sizes -rule knows by name, in points, portrait. Pages match them in
+// either orientation.
+var paperSizes = []struct {
+	name          string
+	width, height float64
+}{
+	{"A3", 842, 1191},
+	{"A4", 595, 842},
+	{"A5", 420, 595},
+	{"Letter", 612, 792},
+	{"Legal", 612, 1008},
+	{"Tabloid", 792, 1224},
+}
+
+// paperTolerance is how many points the sides of a page may be off those of a paper size, as
+// scanners and PDF producers round them
+const paperTolerance = 5
+
+// pageRefs are the pages a -rule may refer to, by offset from the page it is evaluated for
+var pageRefs = map[string]int{"prev": -1, "page": 0, "next": 1}
+
+// pageAttributes are the attributes of pages a -rule may use, e.g. "prev.text"
+var pageAttributes = map[string]ruleKind{
+	"number":   ruleNumber, //1-based
+	"text":     ruleString, //extracted text
+	"width":    ruleNumber, //as shown, in points
+	"height":   ruleNumber,
+	"size":     ruleString, //paper size name, e.g. "A4", or "" if none
+	"rotation": ruleNumber, //degrees clockwise
+}
+
+// ruleExpr is a node of a parsed -rule
+type ruleExpr interface {
+	kind() ruleKind
+	//eval returns a bool, float64, string or, for pages, page index, or nil for attributes of
+	//pages before the first or after the last
+	eval(c *ruleContext) (interface{}, error)
+}
+
+// ruleContext is the page a -rule is evaluated for, with the text of pages extracted so far
+type ruleContext struct {
+	pdf   *model.PdfReader
+	page  int //0-based
+	texts map[int]string
+	warn  func(core.Warning)
+}
+
+// index returns the index of the page offset from that evaluated for, and whether it exists
+func (c *ruleContext) index(offset int) (int, bool) {
+	i := c.page + offset
+	return i, i >= 0 && i < len(c.pdf.PageList)
+}
+
+// text returns the text of page i, extracted once. If warn is set, pages whose text can't be
+// extracted have none, calling warn for each.
+func (c *ruleContext) text(i int) (string, error) {
+	if text, ok := c.texts[i]; ok {
+		return text, nil
+	}
+	text, err := pageText(c.pdf.PageList[i])
+	if err != nil && c.warn != nil {
+		c.warnPage(i, err, "taken as having no text")
+	} else if err != nil {
+		return "", fmt.Errorf("unable to extract PDF page %d text: %v", i+1, err)
+	}
+	c.texts[i] = text
+	return text, nil
+}
+
+// blank returns whether page i is blank. If warn is set, pages whose content can't be read
+// aren't, calling warn for each.
+func (c *ruleContext) blank(i int) (bool, error) {
+	blank, err := blankPage(c.pdf.PageList[i])
+	if err != nil && c.warn != nil {
+		c.warnPage(i, err, "taken as not blank")
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("unable to read PDF page %d content: %v", i+1, err)
+	}
+	return blank, nil
+}
+
+// warnPage calls warn for page i, which couldn't be read because of err and was taken as action
+// says instead
+func (c *ruleContext) warnPage(i int, err error, action string) {
+	obj, _ := c.pdf.PageList[i].GetContainingPdfObject().(*core.PdfIndirectObject)
+	var objNum int64
+	if obj != nil {
+		objNum = obj.ObjectNumber
+	}
+	c.warn(core.Warning{ObjectNumber: objNum, Offset: -1, Problem: err.Error(), Action: fmt.Sprintf("page %d %s", i+1, action)})
+}
+
+type ruleConst struct {
+	k     ruleKind
+	value interface{}
+}
+
+func (e ruleConst) kind() ruleKind                         { return e.k }
+func (e ruleConst) eval(*ruleContext) (interface{}, error) { return e.value, nil }
+
+// rulePageCount is the number of pages of the input
+type rulePageCount struct{}
+
+func (rulePageCount) kind() ruleKind { return ruleNumber }
+func (rulePageCount) eval(c *ruleContext) (interface{}, error) {
+	return float64(len(c.pdf.PageList)), nil
+}
+
+type rulePageRef struct{ offset int }
+
+func (e rulePageRef) kind() ruleKind { return rulePage }
+func (e rulePageRef) eval(c *ruleContext) (interface{}, error) {
+	if i, ok := c.index(e.offset); ok {
+		return i, nil
+	}
+	return nil, nil
+}
+
+type ruleAttribute struct {
+	offset int
+	name   string
+}
+
+func (e ruleAttribute) kind() ruleKind { return pageAttributes[e.name] }
+func (e ruleAttribute) eval(c *ruleContext) (interface{}, error) {
+	i, ok := c.index(e.offset)
+	if !ok {
+		return nil, nil
+	}
+	if e.name == "number" {
+		return float64(i + 1), nil
+	}
+	if e.name == "text" {
+		return c.text(i)
+	}
+
+	box, rotate, err := visibleBox(c.pdf.PageList[i])
+	if err != nil {
+		return nil, err
+	}
+	width, height := math.Abs(box.Urx-box.Llx), math.Abs(box.Ury-box.Lly)
+	if rotate == 90 || rotate == 270 {
+		width, height = height, width
+	}
+	switch e.name {
+	case "width":
+		return width, nil
+	case "height":
+		return height, nil
+	case "rotation":
+		return float64(rotate), nil
+	}
+	short, long := math.Min(width, height), math.Max(width, height)
+	for _, size := range paperSizes {
+		if math.Abs(short-size.width) <= paperTolerance && math.Abs(long-size.height) <= paperTolerance {
+			return size.name, nil
+		}
+	}
+	return "", nil
+}
+
+// ruleBlank is blank(p), whether page p draws nothing
+type ruleBlank struct{ offset int }
+
+func (e ruleBlank) kind() ruleKind { return ruleBool }
+func (e ruleBlank) eval(c *ruleContext) (interface{}, error) {
+	i, ok := c.index(e.offset)
+	if !ok {
+		return false, nil
+	}
+	return c.blank(i)
+}
+
+type ruleNot struct{ x ruleExpr }
+
+func (e ruleNot) kind() ruleKind { return ruleBool }
+func (e ruleNot) eval(c *ruleContext) (interface{}, error) {
+	x, err := e.x.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	return !x.(bool), nil
+}
+
+// ruleLogic is x && y or x || y, evaluating y only if needed
+type ruleLogic struct {
+	and  bool
+	x, y ruleExpr
+}
+
+func (e ruleLogic) kind() ruleKind { return ruleBool }
+func (e ruleLogic) eval(c *ruleContext) (interface{}, error) {
+	x, err := e.x.eval(c)
+	if err != nil || x.(bool) != e.and {
+		return x, err
+	}
+	return e.y.eval(c)
+}
+
+// ruleCompare compares two numbers, pages, strings or booleans. Comparisons with attributes of
+// pages that don't exist are false.
+type ruleCompare struct {
+	op   string
+	x, y ruleExpr
+}
+
+func (e ruleCompare) kind() ruleKind { return ruleBool }
+func (e ruleCompare) eval(c *ruleContext) (interface{}, error) {
+	x, err := e.x.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	y, err := e.y.eval(c)
+	if err != nil || x == nil || y == nil {
+		return false, err
+	}
+	//pages are compared by number
+	if i, ok := x.(int); ok {
+		x = float64(i + 1)
+	}
+	if i, ok := y.(int); ok {
+		y = float64(i + 1)
+	}
+
+	switch e.op {
+	case "==":
+		return x == y, nil
+	case "!=":
+		return x != y, nil
+	}
+	a, b := x.(float64), y.(float64)
+	switch e.op {
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case ">":
+		return a > b, nil
+	}
+	return a >= b, nil
+}
+
+// ruleMatch is x ~ re, or x !~ re if negated
+type ruleMatch struct {
+	x      ruleExpr
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (e ruleMatch) kind() ruleKind { return ruleBool }
+func (e ruleMatch) eval(c *ruleContext) (interface{}, error) {
+	x, err := e.x.eval(c)
+	if err != nil || x == nil {
+		return false, err
+	}
+	return e.re.MatchString(x.(string)) != e.negate, nil
+}
+
+// blankPage reports whether the content of p paints nothing: no text, paths, shadings, images or
+// forms. Annotations aren't taken into account, and scanned blank sheets are images, so they
+// aren't blank.
+func blankPage(p *model.PdfPage) (bool, error) {
+	content, err := p.GetAllContentStreams()
+	if err != nil {
+		return false, err
+	}
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil {
+		return false, err
+	}
+	for _, op := range *ops {
+		switch op.Operand {
+		case "Tj", "TJ", "'", "\"", "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "sh", "Do", "BI":
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ruleToken matches a token of a -rule: a number, a string in double quotes or backquotes, a
+// name such as "prev.text", or an operator
+var ruleToken = regexp.MustCompile("^(?:[0-9]+(?:\\.[0-9]*)?|\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`|[A-Za-z_][A-Za-z0-9_.]*|&&|\\|\\||==|!=|<=|>=|!~|[<>!~()])")
+
+// ruleParser parses a -rule by recursive descent
+type ruleParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseRule parses a -rule such as `text ~ "Invoice" && page.size == A4 || blank(prev)`. Its
+// result must be a boolean.
+func parseRule(rule string) (ruleExpr, error) {
+	p := &ruleParser{}
+	for s := strings.TrimSpace(rule); s != ""; s = strings.TrimLeftFunc(s, unicode.IsSpace) {
+		token := ruleToken.FindString(s)
+		if token == "" {
+			return nil, fmt.Errorf("unexpected %q", s)
+		}
+		p.tokens = append(p.tokens, token)
+		s = s[len(token):]
+	}
+
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if e.kind() != ruleBool {
+		return nil, fmt.Errorf("rule is a %v, not a condition", e.kind())
+	}
+	return e, nil
+}
+
+// peek returns the next token, or "" at the end
+func (p *ruleParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// next returns the next token and moves past it
+func (p *ruleParser) next() string {
+	token := p.peek()
+	if token != "" {
+		p.pos++
+	}
+	return token
+}
+
+func (p *ruleParser) expect(token string) error {
+	if next := p.next(); next != token {
+		return fmt.Errorf("expected %q, got %q", token, next)
+	}
+	return nil
+}
+
+// or is and { "||" and }
+func (p *ruleParser) or() (ruleExpr, error) {
+	return p.logic("||", p.and)
+}
+
+// and is unary { "&&" unary }
+func (p *ruleParser) and() (ruleExpr, error) {
+	return p.logic("&&", p.unary)
+}
+
+func (p *ruleParser) logic(op string, operand func() (ruleExpr, error)) (ruleExpr, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == op {
+		p.next()
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if x.kind() != ruleBool || y.kind() != ruleBool {
+			return nil, fmt.Errorf("%s needs conditions, not a %v and a %v", op, x.kind(), y.kind())
+		}
+		x = ruleLogic{and: op == "&&", x: x, y: y}
+	}
+	return x, nil
+}
+
+// unary is "!" unary | comparison
+func (p *ruleParser) unary() (ruleExpr, error) {
+	if p.peek() != "!" {
+		return p.comparison()
+	}
+	p.next()
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if x.kind() != ruleBool {
+		return nil, fmt.Errorf("! needs a condition, not a %v", x.kind())
+	}
+	return ruleNot{x}, nil
+}
+
+// comparison is operand [ op operand ]
+func (p *ruleParser) comparison() (ruleExpr, error) {
+	x, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "~", "!~":
+		p.next()
+	default:
+		return x, nil
+	}
+	y, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if op == "~" || op == "!~" {
+		pattern, ok := y.(ruleConst)
+		if x.kind() != ruleString || !ok || pattern.k != ruleString {
+			return nil, fmt.Errorf("%s needs a string and a regular expression string", op)
+		}
+		re, err := regexp.Compile(pattern.value.(string))
+		if err != nil {
+			return nil, err
+		}
+		return ruleMatch{x: x, re: re, negate: op == "!~"}, nil
+	}
+
+	//pages compare as numbers
+	kx, ky := x.kind(), y.kind()
+	if kx == rulePage {
+		kx = ruleNumber
+	}
+	if ky == rulePage {
+		ky = ruleNumber
+	}
+	if kx != ky || (kx != ruleNumber && op != "==" && op != "!=") {
+		return nil, fmt.Errorf("can't compare a %v %s a %v", x.kind(), op, y.kind())
+	}
+	return ruleCompare{op: op, x: x, y: y}, nil
+}
+
+// operand is a number, a string, "(" or ")" around an expression, a call such as blank(prev),
+// or a name: true, false, pages, a paper size, a page or a page attribute such as prev.text.
+// text alone is page.text.
+func (p *ruleParser) operand() (ruleExpr, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of rule")
+	case token == "(":
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case token[0] >= '0' && token[0] <= '9':
+		n, err := strconv.ParseFloat(token, 64)
+		return ruleConst{ruleNumber, n}, err
+	case token[0] == '"' || token[0] == '`':
+		s, err := strconv.Unquote(token)
+		return ruleConst{ruleString, s}, err
+	case token == "blank":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		offset, ok := pageRefs[p.next()]
+		if !ok {
+			return nil, fmt.Errorf("blank needs page, prev or next")
+		}
+		return ruleBlank{offset}, p.expect(")")
+	case token == "true" || token == "false":
+		return ruleConst{ruleBool, token == "true"}, nil
+	case token == "pages":
+		return rulePageCount{}, nil
+	case token == "text":
+		return ruleAttribute{0, "text"}, nil
+	}
+
+	for _, size := range paperSizes {
+		if token == size.name {
+			return ruleConst{ruleString, size.name}, nil
+		}
+	}
+	ref := strings.SplitN(token, ".", 2)
+	offset, ok := pageRefs[ref[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown name %q", token)
+	}
+	if len(ref) == 1 {
+		return rulePageRef{offset}, nil
+	}
+	if _, ok := pageAttributes[ref[1]]; !ok {
+		return nil, fmt.Errorf("unknown page attribute %q", ref[1])
+	}
+	return ruleAttribute{offset, ref[1]}, nil
+}
+
+// ruleParts splits the pages of pdf at each page rule is true for, returning the 1-based page
+// numbers of each part. The first page always starts one.
+func ruleParts(pdf *model.PdfReader, rule ruleExpr, warn func(core.Warning)) ([][]int, error) {
+	c := &ruleContext{pdf: pdf, texts: map[int]string{}, warn: warn}
+	var parts [][]int
+	for i := range pdf.PageList {
+		c.page = i
+		//only the previous page's text may be needed again
+		delete(c.texts, i-2)
+
+		start, err := rule.eval(c)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %v", i+1, err)
+		}
+		if start.(bool) || len(parts) == 0 {
+			parts = append(parts, nil)
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], i+1)
+	}
+	return parts, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// testPage is a page of a test PDF: its media box and content stream object
+type testPage struct{ size, content string }
+
+// rulesPDF returns a PDF of pages of the sizes and contents given, drawing text in Helvetica
+func rulesPDF(t *testing.T, pages ...testPage) *model.PdfReader {
+	t.Helper()
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := ""
+	for _, p := range pages {
+		kids += fmt.Sprintf("%d 0 R ", len(objects)+1)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox %s /Contents %d 0 R /Resources << /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> >> >> >>", p.size, len(objects)+2),
+			p.content,
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pages))
+
+	pdf, err := loadPDF(bytes.NewReader(rawPDF(objects...)), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pdf
+}
+
+// contentStream returns a content stream object drawing content
+func contentStream(content string) string {
+	return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+}
+
+const (
+	a4     = "[0 0 595 842]"
+	letter = "[0 0 612 792]"
+)
+
+// Test that -rule expressions parse with && binding tighter than ||, and evaluate literals,
+// comparisons, matches and blank() as documented, with pages before the first and after the last
+// making comparisons and blank() false.
+func TestRuleEval(t *testing.T) {
+	pdf := rulesPDF(t,
+		testPage{a4, contentStream("BT /F1 12 Tf 72 700 Td (Invoice No. 1042) Tj ET")},
+		testPage{letter, contentStream("")},
+		testPage{a4, contentStream("BT /F1 12 Tf 72 700 Td (Terms and conditions) Tj ET")},
+	)
+
+	tests := []struct {
+		rule     string
+		expected []bool //for each page
+	}{
+		{"true", []bool{true, true, true}},
+		{"true || false && false", []bool{true, true, true}},
+		{"false && true || true", []bool{true, true, true}},
+		{"(true || false) && false", []bool{false, false, false}},
+		{"!false && false", []bool{false, false, false}},
+		{"!(false && false)", []bool{true, true, true}},
+		{"!!true", []bool{true, true, true}},
+		{`text ~ "Invoice No\\. \\d+"`, []bool{true, false, false}},
+		{"text ~ `Invoice No\\. \\d+`", []bool{true, false, false}},
+		{`text !~ "Invoice"`, []bool{false, true, true}},
+		{`prev.text ~ "Invoice"`, []bool{false, true, false}},
+		{`next.text ~ "Terms"`, []bool{false, true, false}},
+		{`page.text == ""`, []bool{false, true, false}},
+		{`"a\"b" == "a\"b"`, []bool{true, true, true}},
+		{"page.size == A4", []bool{true, false, true}},
+		{"page.size == Letter", []bool{false, true, false}},
+		{"page.size != prev.size", []bool{false, true, true}},
+		{"page.width == 612 && page.height == 792", []bool{false, true, false}},
+		{"page.number >= 2", []bool{false, true, true}},
+		{"page == 2.0", []bool{false, true, false}},
+		{"page.number == pages", []bool{false, false, true}},
+		{"prev.number == 1", []bool{false, true, false}},
+		{"next.number < 3", []bool{true, false, false}},
+		{"page.rotation == 0", []bool{true, true, true}},
+		{"2.5 > 2 && 3. == 3", []bool{true, true, true}},
+		{"blank(page)", []bool{false, true, false}},
+		{"blank(prev)", []bool{false, false, true}},
+		{"blank(next)", []bool{true, false, false}},
+		{"!blank(prev)", []bool{true, true, false}},
+		{`text ~ "Invoice" && page.size == A4 || blank(prev)`, []bool{true, false, true}},
+	}
+	for _, test := range tests {
+		rule, err := parseRule(test.rule)
+		if err != nil {
+			t.Errorf("%s: %v", test.rule, err)
+			continue
+		}
+		c := &ruleContext{pdf: pdf, texts: map[int]string{}}
+		for i, expected := range test.expected {
+			c.page = i
+			got, err := rule.eval(c)
+			if err != nil {
+				t.Errorf("%s: page %d: %v", test.rule, i+1, err)
+			} else if got != expected {
+				t.Errorf("%s: page %d: %v, expected %v", test.rule, i+1, got, expected)
+			}
+		}
+	}
+}
+
+// Test that malformed -rule expressions and those of the wrong types fail to parse.
+func TestRuleParseErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"text",
+		"1",
+		"pages",
+		`"Invoice"`,
+		"text ~",
+		"text ~ text",
+		`text ~ "("`,
+		`1 ~ "1"`,
+		"true &&",
+		"|| true",
+		"1 && true",
+		"!text",
+		"(true",
+		"true)",
+		"blank",
+		"blank(",
+		"blank(first)",
+		"blank(prev",
+		"text == 1",
+		`"a" < "b"`,
+		"page.size == A6",
+		"foo",
+		"page.foo",
+		"page.number = 1",
+		"page.number == 1 1",
+		`"unterminated`,
+		"text ~ 'Invoice'",
+		"1.2.3 == 1",
+	} {
+		if _, err := parseRule(rule); err == nil {
+			t.Errorf("%q parsed", rule)
+		}
+	}
+}
+
+// Test that pages whose content can't be read stop a -rule split, unless lenient, where they
+// are taken as not blank with a warning.
+func TestRuleUnreadablePage(t *testing.T) {
+	pdf := rulesPDF(t,
+		testPage{a4, contentStream("BT /F1 12 Tf 72 700 Td (Cover) Tj ET")},
+		testPage{a4, contentStream("")},
+		testPage{a4, "<< /Length 4 /Filter /FlateDecode >>\nstream\nxxxx\nendstream"},
+		testPage{a4, contentStream("BT /F1 12 Tf 72 700 Td (Letter) Tj ET")},
+	)
+	rule, err := parseRule("blank(prev)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ruleParts(pdf, rule, nil); err == nil {
+		t.Error("strict: no error for the unreadable page")
+	}
+
+	var warnings []core.Warning
+	parts, err := ruleParts(pdf, rule, func(w core.Warning) { warnings = append(warnings, w) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][]int{{1, 2}, {3, 4}}; !reflect.DeepEqual(parts, expected) {
+		t.Errorf("lenient: parts %v, expected %v", parts, expected)
+	}
+	if len(warnings) != 1 || warnings[0].Action != "page 3 taken as not blank" {
+		t.Errorf("lenient: warnings %+v, expected one for page 3", warnings)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// separatorParts splits the pages of pdf at separator sheets, pages whose text contains the
+// literal text sep, returning the 1-based page numbers of each part. Runs of white space match
+// any white space, as text extraction may change it. A separator starts the next part, or is
+// left out if drop is set, and parts left empty are skipped. If warn is set, pages whose text
+// can't be extracted are taken as content, calling warn for each.
+func separatorParts(pdf *model.PdfReader, sep string, drop bool, warn func(core.Warning)) ([][]int, error) {
+	sep = strings.Join(strings.Fields(sep), " ")
+
+	var parts [][]int
+	var current []int
+	for i, p := range pdf.PageList {
+		text, err := pageText(p)
+		if err != nil && warn != nil {
+			obj, _ := p.GetContainingPdfObject().(*core.PdfIndirectObject)
+			var objNum int64
+			if obj != nil {
+				objNum = obj.ObjectNumber
+			}
+			warn(core.Warning{ObjectNumber: objNum, Offset: -1, Problem: err.Error(), Action: fmt.Sprintf("page %d taken as content", i+1)})
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to extract PDF page %d text: %v", i+1, err)
+		}
+
+		if err == nil && strings.Contains(strings.Join(strings.Fields(text), " "), sep) {
+			if len(current) > 0 {
+				parts = append(parts, current)
+			}
+			current = nil
+			if drop {
+				continue
+			}
+		}
+		current = append(current, i+1)
+	}
+	if len(current) > 0 {
+		parts = append(parts, current)
+	}
+	return parts, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// environment variables of SFTP password authentication
+const (
+	sftpPasswordEnv = "PDF_SPLITTER_SFTP_PASSWORD" //the password, if any
+	askpassEnv      = "PDF_SPLITTER_ASKPASS"       //set when ssh runs this program as SSH_ASKPASS
+)
+
+// sftpOptions are the SSH options of SFTP transfers
+type sftpOptions struct {
+	key        string //private key file, if not the ssh defaults
+	knownHosts string //known hosts file, if not the ssh defaults
+}
+
+// sftpConfig is the SFTP options of the running command
+var sftpConfig sftpOptions
+
+// isSFTP reports whether fn is an SFTP URL rather than a file
+func isSFTP(fn string) bool {
+	return strings.HasPrefix(fn, "sftp://")
+}
+
+// sftpTarget is a file or directory on an SFTP server
+type sftpTarget struct {
+	dest string //[user@]host
+	port string
+	path string
+}
+
+// parseSFTP parses an SFTP URL such as "sftp://user@host:2222/drop/input.pdf"
+func parseSFTP(s string) (*sftpTarget, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Hostname() == "" || u.Path == "" || u.Path == "/" {
+		return nil, fmt.Errorf("SFTP URL %s needs a host and a path", s)
+	}
+	if _, ok := u.User.Password(); ok {
+		return nil, fmt.Errorf("SFTP URL %s must not contain a password, set %s instead", u.Redacted(), sftpPasswordEnv)
+	}
+
+	t := &sftpTarget{dest: u.Hostname(), port: u.Port(), path: u.Path}
+	if u.User != nil {
+		t.dest = u.User.Username() + "@" + t.dest
+	}
+	return t, nil
+}
+
+// url returns the SFTP URL of the file rel relative to the target directory
+func (t *sftpTarget) url(rel string) string {
+	host := t.dest
+	if t.port != "" {
+		host += ":" + t.port
+	}
+	return "sftp://" + host + path.Join(t.path, rel)
+}
+
+// run runs the sftp batch commands on the server, with the host key checked against the known
+// hosts. It authenticates with the key, or the password in sftpPasswordEnv, if set, and the ssh
+// defaults otherwise.
+func (t *sftpTarget) run(commands ...string) error {
+	args := []string{"-q", "-o", "StrictHostKeyChecking=yes"}
+	if t.port != "" {
+		args = append(args, "-P", t.port)
+	}
+	if sftpConfig.key != "" {
+		args = append(args, "-i", sftpConfig.key)
+	}
+	if sftpConfig.knownHosts != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+sftpConfig.knownHosts)
+	}
+
+	cmd := exec.Command("sftp")
+	cmd.Env = os.Environ()
+	if os.Getenv(sftpPasswordEnv) != "" {
+		//ssh asks this program for the password, which it reads from the environment, so it
+		//never appears in arguments; BatchMode=no comes before -b, which would disable it
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		args = append(args, "-o", "BatchMode=no")
+		cmd.Env = append(cmd.Env, "SSH_ASKPASS="+exe, "SSH_ASKPASS_REQUIRE=force", askpassEnv+"=1")
+	}
+	cmd.Args = append(append([]string{"sftp"}, args...), "-b", "-", t.dest)
+
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("sftp %s: %s", t.dest, msg)
+		}
+		return fmt.Errorf("sftp %s: %v", t.dest, err)
+	}
+	return nil
+}
+
+// sftpQuote quotes an argument of an sftp batch command, leaving glob characters as they are
+func sftpQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// openSFTP downloads the PDF at an SFTP URL to a temporary file and opens it
+func openSFTP(fn string) (*inputFile, error) {
+	t, err := parseSFTP(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "pdf-splitter-*.pdf")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	atExit(func() { os.Remove(tmp.Name()) })
+
+	logInfo("Downloading", fn)
+	if err = t.run("get " + sftpQuote(t.path) + " " + sftpQuote(tmp.Name())); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	f, err := openInput(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	closeFile := f.close
+	f.close = func() error {
+		err := closeFile()
+		os.Remove(tmp.Name())
+		return err
+	}
+	return f, nil
+}
+
+// upload uploads the files and directories in the local directory dir to the target directory,
+// creating it if its parent exists
+func (t *sftpTarget) upload(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	logInfo("Uploading to", t.dest+":"+t.path)
+	return t.run("-mkdir "+sftpQuote(t.path), "put -R "+sftpQuote(filepath.Join(dir, "*"))+" "+sftpQuote(t.path))
+}
+
+// runAskpass prints the SFTP password if this program was run by ssh as SSH_ASKPASS, and reports
+// whether it was
+func runAskpass() bool {
+	if os.Getenv(askpassEnv) == "" {
+		return false
+	}
+	password := os.Getenv(sftpPasswordEnv)
+	if password == "" {
+		fmt.Fprintln(os.Stderr, sftpPasswordEnv, "not set")
+		os.Exit(exitFailure)
+	}
+	fmt.Println(password)
+	return true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// minSpreadRatio is the ratio of width to height, as shown, from which a page is taken as a spread
+// of two pages scanned together: single pages are at most square, and a spread of two A4 or Letter
+// pages is about 1.4 times as wide as it is high
+const minSpreadRatio = 1.2
+
+// splitSpreads splits the spreads among pages, book scans of two pages side by side, into their
+// left and right halves, each a copy of the page cropped to its half. Other pages are kept. It
+// also returns the index in the result of the first page split from each of pages.
+func splitSpreads(pages []*model.PdfPage) ([]*model.PdfPage, []int, error) {
+	split := make([]*model.PdfPage, 0, len(pages))
+	index := make([]int, len(pages))
+
+	for i, p := range pages {
+		index[i] = len(split)
+
+		box, rotate, err := visibleBox(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		width, height := box.Urx-box.Llx, box.Ury-box.Lly
+		if rotate == 90 || rotate == 270 {
+			width, height = height, width
+		}
+		if height <= 0 || width < minSpreadRatio*height {
+			split = append(split, p)
+			continue
+		}
+
+		//the halves of the box as shown: rotated a quarter turn clockwise, its bottom is shown on
+		//the left, and rotated half a turn, its right
+		first, second := *box, *box
+		switch rotate {
+		case 0, 180:
+			first.Urx = (box.Llx + box.Urx) / 2
+			second.Llx = first.Urx
+		default:
+			first.Ury = (box.Lly + box.Ury) / 2
+			second.Lly = first.Ury
+		}
+		if rotate == 180 || rotate == 270 {
+			first, second = second, first
+		}
+
+		for _, half := range []model.PdfRectangle{first, second} {
+			dup := p.Duplicate()
+			crop := half
+			dup.CropBox = &crop
+			split = append(split, dup)
+		}
+	}
+
+	return split, index, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// copyPage returns a copy of p that can be stamped without affecting p or other outputs sharing it.
+// Inherited resources are resolved, and the XObject and Font resource dictionaries are copied so
+// new entries can be added.
+func copyPage(p *model.PdfPage) (*model.PdfPage, error) {
+	res, err := pageResources(p)
+	if err != nil {
+		return nil, err
+	}
+
+	dup := p.Duplicate()
+	dup.Resources = copyResources(res)
+
+	return dup, nil
+}
+
+// copyResources returns a copy of res, which may be nil, whose XObject and Font resource
+// dictionaries are copied so entries can be added or replaced
+func copyResources(res *model.PdfPageResources) *model.PdfPageResources {
+	dup := model.NewPdfPageResources()
+	if res != nil {
+		dup.ExtGState = res.ExtGState
+		dup.ColorSpace = res.ColorSpace
+		dup.Pattern = res.Pattern
+		dup.Shading = res.Shading
+		dup.XObject = copyDict(res.XObject)
+		dup.Font = copyDict(res.Font)
+		dup.ProcSet = res.ProcSet
+		dup.Properties = res.Properties
+	}
+
+	return dup
+}
+
+// pageResources returns the resources of p, which may be inherited from its ancestors
+func pageResources(p *model.PdfPage) (*model.PdfPageResources, error) {
+	if p.Resources != nil {
+		return p.Resources, nil
+	}
+
+	for node := p.Parent; node != nil; {
+		ind, ok := node.(*core.PdfIndirectObject)
+		if !ok {
+			return nil, errors.New("invalid page parent")
+		}
+		dict, ok := ind.PdfObject.(*core.PdfObjectDictionary)
+		if !ok {
+			return nil, errors.New("invalid page parent dictionary")
+		}
+
+		if obj := dict.Get("Resources"); obj != nil {
+			resDict, ok := core.TraceToDirectObject(obj).(*core.PdfObjectDictionary)
+			if !ok {
+				return nil, errors.New("invalid inherited resources")
+			}
+			return model.NewPdfPageResourcesFromDict(resDict)
+		}
+
+		node = dict.Get("Parent")
+	}
+
+	return nil, nil
+}
+
+// copyDict returns a shallow copy of a (possibly indirect) dictionary, or obj itself if it isn't one
+func copyDict(obj core.PdfObject) core.PdfObject {
+	dict, ok := core.TraceToDirectObject(obj).(*core.PdfObjectDictionary)
+	if !ok {
+		return obj
+	}
+
+	dup := core.MakeDict()
+	for _, key := range dict.Keys() {
+		dup.Set(key, dict.Get(key))
+	}
+
+	return dup
+}
+
+// stampContents adds content under and over the existing content of p, which should be a copy
+// from copyPage. The existing content is wrapped in q/Q so the over content starts from the
+// default graphics state.
+func stampContents(p *model.PdfPage, under, over string) {
+	contents := core.PdfObjectArray{}
+
+	if under != "" {
+		contents = append(contents, makeContentStream(under))
+	}
+
+	if over != "" {
+		contents = append(contents, makeContentStream("q\n"))
+	}
+
+	switch c := core.TraceToDirectObject(p.Contents).(type) {
+	case *core.PdfObjectArray:
+		contents = append(contents, *c...)
+	case nil:
+	default:
+		contents = append(contents, p.Contents)
+	}
+
+	if over != "" {
+		contents = append(contents, makeContentStream("\nQ\n"+over))
+	}
+
+	p.Contents = &contents
+}
+
+// makeContentStream returns an unencoded content stream object
+func makeContentStream(content string) *core.PdfObjectStream {
+	dict := core.MakeDict()
+	dict.Set("Length", core.MakeInteger(int64(len(content))))
+	return &core.PdfObjectStream{PdfObjectDictionary: dict, Stream: []byte(content)}
+}
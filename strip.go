@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/unidoc/unidoc/pdf/contentstream"
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// imageStripper removes the images of output pages, for lightweight text-only outputs. Image
+// XObjects are replaced by an empty form, so the content drawing them stays valid, and inline
+// images are removed from the content.
+type imageStripper struct {
+	placeholder *core.PdfObjectStream
+	stripped    map[*core.PdfObjectStream]*core.PdfObjectStream //stripped forms, shared by outputs
+}
+
+func newImageStripper() *imageStripper {
+	dict := core.MakeDict()
+	dict.Set("Type", core.MakeName("XObject"))
+	dict.Set("Subtype", core.MakeName("Form"))
+	dict.Set("BBox", core.MakeArrayFromFloats([]float64{0, 0, 1, 1}))
+	dict.Set("Length", core.MakeInteger(0))
+
+	return &imageStripper{
+		placeholder: &core.PdfObjectStream{PdfObjectDictionary: dict},
+		stripped:    map[*core.PdfObjectStream]*core.PdfObjectStream{},
+	}
+}
+
+// apply is a pageTransform removing the images of pages
+func (s *imageStripper) apply(pages []*model.PdfPage) ([]*model.PdfPage, error) {
+	stripped := make([]*model.PdfPage, len(pages))
+
+	for i, p := range pages {
+		content, err := p.GetAllContentStreams()
+		if err != nil {
+			return nil, err
+		}
+
+		dup, err := copyPage(p)
+		if err != nil {
+			return nil, err
+		}
+		if err = s.stripXObjects(dup.Resources); err != nil {
+			return nil, err
+		}
+		if text, ok, err := stripInlineImages(content); err != nil {
+			return nil, fmt.Errorf("unable to remove inline images: %v", err)
+		} else if ok {
+			if err = dup.SetContentStreams([]string{text}, core.NewFlateEncoder()); err != nil {
+				return nil, err
+			}
+		}
+
+		stripped[i] = dup
+	}
+
+	return stripped, nil
+}
+
+// stripXObjects replaces the image XObjects of res, which should be a copy from copyResources,
+// with the placeholder, and its forms with copies without images
+func (s *imageStripper) stripXObjects(res *model.PdfPageResources) error {
+	xobjs, ok := res.XObject.(*core.PdfObjectDictionary)
+	if !ok {
+		return nil
+	}
+
+	for _, name := range xobjs.Keys() {
+		stream, ok := core.TraceToDirectObject(xobjs.Get(name)).(*core.PdfObjectStream)
+		if !ok {
+			continue
+		}
+		subtype, _ := core.TraceToDirectObject(stream.Get("Subtype")).(*core.PdfObjectName)
+		switch {
+		case subtype == nil:
+		case *subtype == "Image":
+			xobjs.Set(name, s.placeholder)
+		case *subtype == "Form":
+			form, err := s.stripForm(stream)
+			if err != nil {
+				return fmt.Errorf("XObject %s: %v", name, err)
+			}
+			xobjs.Set(name, form)
+		}
+	}
+
+	return nil
+}
+
+// stripForm returns a copy of a form XObject without images
+func (s *imageStripper) stripForm(stream *core.PdfObjectStream) (*core.PdfObjectStream, error) {
+	if form, ok := s.stripped[stream]; ok {
+		return form, nil
+	}
+	//forms drawing themselves are left as they are
+	s.stripped[stream] = stream
+
+	form, err := model.NewXObjectFormFromStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	res := copyResources(form.Resources)
+	if err = s.stripXObjects(res); err != nil {
+		return nil, err
+	}
+
+	//copy the form dictionary, replacing its resources and, if it has inline images, its content
+	dict := copyDict(stream.PdfObjectDictionary).(*core.PdfObjectDictionary)
+	dict.Set("Resources", res.ToPdfObject())
+	dup := &core.PdfObjectStream{PdfObjectDictionary: dict, Stream: stream.Stream}
+
+	content, err := form.GetContentStream()
+	if err != nil {
+		return nil, err
+	}
+	if text, ok, err := stripInlineImages(string(content)); err != nil {
+		return nil, err
+	} else if ok {
+		encoder := core.NewFlateEncoder()
+		if dup.Stream, err = encoder.EncodeBytes([]byte(text)); err != nil {
+			return nil, err
+		}
+		dict.Set("Filter", core.MakeName(encoder.GetFilterName()))
+		dict.Remove("DecodeParms")
+		dict.Set("Length", core.MakeInteger(int64(len(dup.Stream))))
+	}
+
+	s.stripped[stream] = dup
+	return dup, nil
+}
+
+// inlineImage matches the operator starting an inline image, to skip parsing content without any
+var inlineImage = regexp.MustCompile(`(^|\s)BI\s`)
+
+// stripInlineImages returns content without its inline images, or false if it has none
+func stripInlineImages(content string) (string, bool, error) {
+	if !inlineImage.MatchString(content) {
+		return content, false, nil
+	}
+
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil {
+		return "", false, err
+	}
+
+	var text contentstream.ContentStreamOperations
+	for _, op := range *ops {
+		if op.Operand != "BI" {
+			text = append(text, op)
+		}
+	}
+	if len(text) == len(*ops) {
+		return content, false, nil
+	}
+
+	return string(text.Bytes()), true, nil
+}
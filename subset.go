@@ -0,0 +1,507 @@
+package main
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/unidoc/unidoc/pdf/contentstream"
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// compositeFont is a Type 0 font with an Identity encoding and an embedded TrueType program, whose
+// glyphs can be told from the codes of the text drawn with it
+type compositeFont struct {
+	program  *core.PdfObjectStream //FontFile2 of its descendant font
+	cidToGID []byte                //CIDToGIDMap data, nil for Identity
+}
+
+// gid returns the glyph ID for a CID
+func (cf *compositeFont) gid(cid uint16) uint16 {
+	if cf.cidToGID == nil {
+		return cid
+	}
+	if i := 2 * int(cid); i+1 < len(cf.cidToGID) {
+		return binary.BigEndian.Uint16(cf.cidToGID[i:])
+	}
+	return 0
+}
+
+// fontSubsetter subsets the embedded fonts of each output to the glyphs its pages draw, so a
+// large font, such as a CJK one, isn't copied in full into every output. Only the TrueType
+// programs of Type 0 fonts with an Identity encoding are subset; other fonts are kept as they are.
+// Decoded font programs and subsets are cached for later outputs, so outputs drawing the same
+// glyphs share a subset, up to a limit beyond which the least recently used are dropped.
+type fontSubsetter struct {
+	fonts map[*core.PdfObjectDictionary]*compositeFont //nil for fonts that can't be subset
+	cache map[fontCacheKey]*list.Element               //of *fontCacheEntry, least recently used first in order
+	order *list.List
+	size  int //bytes cached
+	limit int
+}
+
+// fontSubsetKey identifies the subset of a font program to some glyphs
+type fontSubsetKey struct {
+	program *core.PdfObjectStream
+	glyphs  string //glyph IDs, sorted, as big-endian uint16s
+}
+
+// fontCacheKey identifies a decoded font program, or a subset of it
+type fontCacheKey struct {
+	fontSubsetKey
+	decoded bool //the decoded program rather than a subset
+}
+
+// fontCacheEntry is a decoded font program or a subset cached by a fontSubsetter
+type fontCacheEntry struct {
+	key    fontCacheKey
+	data   []byte                //the decoded program, nil if undecodable
+	stream *core.PdfObjectStream //the subset, nil for subsets no smaller than the program
+}
+
+// fontCacheLimit is the number of bytes of decoded font programs and subsets a fontSubsetter
+// caches, or a quarter of -max-memory if that is less
+const fontCacheLimit = 64 << 20
+
+func newFontSubsetter() *fontSubsetter {
+	limit := fontCacheLimit
+	if memoryBudget > 0 && memoryBudget/4 < int64(limit) {
+		limit = int(memoryBudget / 4)
+	}
+	return &fontSubsetter{
+		fonts: map[*core.PdfObjectDictionary]*compositeFont{},
+		cache: map[fontCacheKey]*list.Element{},
+		order: list.New(),
+		limit: limit,
+	}
+}
+
+// cached returns the cached entry for k, if any, marking it as recently used
+func (s *fontSubsetter) cached(k fontCacheKey) (*fontCacheEntry, bool) {
+	elem, ok := s.cache[k]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToBack(elem)
+	return elem.Value.(*fontCacheEntry), true
+}
+
+// keep caches e, dropping the least recently used entries beyond the limit, e itself if it is
+// over the limit alone
+func (s *fontSubsetter) keep(e *fontCacheEntry) {
+	s.cache[e.key] = s.order.PushBack(e)
+	s.size += e.bytes()
+	for s.size > s.limit {
+		evicted := s.order.Remove(s.order.Front()).(*fontCacheEntry)
+		delete(s.cache, evicted.key)
+		s.size -= evicted.bytes()
+	}
+}
+
+// bytes returns the size of the entry as cached
+func (e *fontCacheEntry) bytes() int {
+	n := len(e.key.glyphs) + len(e.data)
+	if e.stream != nil {
+		n += len(e.stream.Stream)
+	}
+	return n
+}
+
+// subsetJob is the state of subsetting the fonts of one output
+type subsetJob struct {
+	*fontSubsetter
+	glyphs   map[*core.PdfObjectStream]map[uint16]bool //glyph IDs drawn, by font program
+	visited  map[*core.PdfObjectStream]bool
+	programs map[*core.PdfObjectStream]*core.PdfObjectStream //subset font programs
+	tags     map[*core.PdfObjectStream]string
+	fontDups map[*core.PdfObjectDictionary]*core.PdfObjectDictionary
+	formDups map[*core.PdfObjectStream]*core.PdfObjectStream
+}
+
+// apply is a pageTransform subsetting the fonts of pages
+func (s *fontSubsetter) apply(pages []*model.PdfPage) ([]*model.PdfPage, error) {
+	job := &subsetJob{
+		fontSubsetter: s,
+		glyphs:        map[*core.PdfObjectStream]map[uint16]bool{},
+		visited:       map[*core.PdfObjectStream]bool{},
+		programs:      map[*core.PdfObjectStream]*core.PdfObjectStream{},
+		tags:          map[*core.PdfObjectStream]string{},
+		fontDups:      map[*core.PdfObjectDictionary]*core.PdfObjectDictionary{},
+		formDups:      map[*core.PdfObjectStream]*core.PdfObjectStream{},
+	}
+
+	//find the glyphs drawn with each font
+	for i, p := range pages {
+		res, err := pageResources(p)
+		if err != nil {
+			return nil, err
+		}
+		content, err := p.GetAllContentStreams()
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			continue
+		}
+		if err = job.collect(content, resourcesDict(res)); err != nil {
+			return nil, fmt.Errorf("unable to subset fonts of page %d: %v", i+1, err)
+		}
+	}
+	if len(job.glyphs) == 0 {
+		return pages, nil
+	}
+
+	//subset the font programs
+	for program, glyphs := range job.glyphs {
+		stream, err := s.subset(program, glyphs)
+		if err != nil {
+			return nil, err
+		}
+		if stream != nil {
+			job.programs[program] = stream
+			job.tags[program] = subsetTag(glyphs)
+		}
+	}
+
+	subset := make([]*model.PdfPage, len(pages))
+	for i, p := range pages {
+		dup, err := copyPage(p)
+		if err != nil {
+			return nil, err
+		}
+		job.replaceFonts(dup.Resources.Font)
+		job.replaceForms(dup.Resources.XObject)
+		subset[i] = dup
+	}
+
+	return subset, nil
+}
+
+// subset returns the font file stream of program subset to glyphs, or nil if it can't be subset
+// or the subset isn't smaller
+func (s *fontSubsetter) subset(program *core.PdfObjectStream, glyphs map[uint16]bool) (*core.PdfObjectStream, error) {
+	gids := sortedGlyphs(glyphs)
+	key := make([]byte, 0, 2*len(gids))
+	for _, gid := range gids {
+		key = append(key, byte(gid>>8), byte(gid))
+	}
+	k := fontCacheKey{fontSubsetKey: fontSubsetKey{program: program, glyphs: string(key)}}
+	if e, ok := s.cached(k); ok {
+		return e.stream, nil
+	}
+
+	data := s.decode(program)
+	if data == nil {
+		return nil, nil
+	}
+	e := &fontCacheEntry{key: k}
+	if subset, err := subsetTrueType(data, glyphs); err == nil {
+		stream, err := fontFileStream(program, subset)
+		if err != nil {
+			return nil, err
+		}
+		if len(stream.Stream) < len(program.Stream) {
+			e.stream = stream
+		}
+	}
+	s.keep(e)
+	return e.stream, nil
+}
+
+// decode returns the decoded font program, or nil if it can't be decoded
+func (s *fontSubsetter) decode(program *core.PdfObjectStream) []byte {
+	k := fontCacheKey{fontSubsetKey: fontSubsetKey{program: program}, decoded: true}
+	if e, ok := s.cached(k); ok {
+		return e.data
+	}
+	data, _ := core.DecodeStream(program)
+	s.keep(&fontCacheEntry{key: k, data: data})
+	return data
+}
+
+// compositeFont returns the font dictionary font as a compositeFont, or nil if it can't be subset
+func (s *fontSubsetter) compositeFont(font *core.PdfObjectDictionary) *compositeFont {
+	if cf, ok := s.fonts[font]; ok {
+		return cf
+	}
+	s.fonts[font] = nil
+
+	subtype, _ := core.TraceToDirectObject(font.Get("Subtype")).(*core.PdfObjectName)
+	encoding, _ := core.TraceToDirectObject(font.Get("Encoding")).(*core.PdfObjectName)
+	if subtype == nil || *subtype != "Type0" || encoding == nil || *encoding != "Identity-H" && *encoding != "Identity-V" {
+		return nil
+	}
+	descendants, ok := core.TraceToDirectObject(font.Get("DescendantFonts")).(*core.PdfObjectArray)
+	if !ok || len(*descendants) == 0 {
+		return nil
+	}
+	cidFont, ok := core.TraceToDirectObject((*descendants)[0]).(*core.PdfObjectDictionary)
+	if !ok {
+		return nil
+	}
+	if subtype, ok := core.TraceToDirectObject(cidFont.Get("Subtype")).(*core.PdfObjectName); !ok || *subtype != "CIDFontType2" {
+		return nil
+	}
+	key, program := fontFile(font)
+	if key != "FontFile2" {
+		return nil
+	}
+
+	cf := &compositeFont{program: program}
+	switch m := core.TraceToDirectObject(cidFont.Get("CIDToGIDMap")).(type) {
+	case nil:
+	case *core.PdfObjectName:
+		if *m != "Identity" {
+			return nil
+		}
+	case *core.PdfObjectStream:
+		data, err := core.DecodeStream(m)
+		if err != nil {
+			return nil
+		}
+		cf.cidToGID = data
+	default:
+		return nil
+	}
+
+	s.fonts[font] = cf
+	return cf
+}
+
+// collect adds the glyphs drawn by content, with resources res, and the forms it draws
+func (job *subsetJob) collect(content string, res *core.PdfObjectDictionary) error {
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil {
+		return err
+	}
+
+	fonts, _ := core.TraceToDirectObject(res.Get("Font")).(*core.PdfObjectDictionary)
+	xobjs, _ := core.TraceToDirectObject(res.Get("XObject")).(*core.PdfObjectDictionary)
+
+	//the font is part of the graphics state, saved by q and restored by Q
+	var font *compositeFont
+	var saved []*compositeFont
+	addText := func(obj core.PdfObject) {
+		s, ok := obj.(*core.PdfObjectString)
+		if !ok || font == nil {
+			return
+		}
+		glyphs := job.glyphs[font.program]
+		if glyphs == nil {
+			glyphs = map[uint16]bool{}
+			job.glyphs[font.program] = glyphs
+		}
+		for i := 0; i+1 < len(*s); i += 2 {
+			glyphs[font.gid(uint16((*s)[i])<<8|uint16((*s)[i+1]))] = true
+		}
+	}
+
+	for _, op := range *ops {
+		switch op.Operand {
+		case "q":
+			saved = append(saved, font)
+		case "Q":
+			if len(saved) > 0 {
+				font, saved = saved[len(saved)-1], saved[:len(saved)-1]
+			}
+		case "Tf":
+			font = nil
+			if len(op.Params) == 2 && fonts != nil {
+				if name, ok := op.Params[0].(*core.PdfObjectName); ok {
+					if dict, ok := core.TraceToDirectObject(fonts.Get(*name)).(*core.PdfObjectDictionary); ok {
+						font = job.compositeFont(dict)
+					}
+				}
+			}
+		case "Tj", "'", "\"":
+			if len(op.Params) > 0 {
+				addText(op.Params[len(op.Params)-1])
+			}
+		case "TJ":
+			if len(op.Params) == 1 {
+				if arr, ok := op.Params[0].(*core.PdfObjectArray); ok {
+					for _, obj := range *arr {
+						addText(obj)
+					}
+				}
+			}
+		case "Do":
+			if len(op.Params) != 1 || xobjs == nil {
+				continue
+			}
+			name, ok := op.Params[0].(*core.PdfObjectName)
+			if !ok {
+				continue
+			}
+			form, ok := core.TraceToDirectObject(xobjs.Get(*name)).(*core.PdfObjectStream)
+			if !ok || job.visited[form] || !isForm(form) {
+				continue
+			}
+			job.visited[form] = true
+			formContent, err := core.DecodeStream(form)
+			if err != nil {
+				return fmt.Errorf("XObject %s: %v", *name, err)
+			}
+			//forms without resources use those of the page drawing them
+			formRes, ok := core.TraceToDirectObject(form.Get("Resources")).(*core.PdfObjectDictionary)
+			if !ok {
+				formRes = res
+			}
+			if err = job.collect(string(formContent), formRes); err != nil {
+				return fmt.Errorf("XObject %s: %v", *name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// replaceFonts replaces the fonts in the font resource dictionary fonts, which should be a copy,
+// with ones using the subset programs
+func (job *subsetJob) replaceFonts(fonts core.PdfObject) {
+	dict, ok := fonts.(*core.PdfObjectDictionary)
+	if !ok {
+		return
+	}
+
+	for _, name := range dict.Keys() {
+		font, ok := core.TraceToDirectObject(dict.Get(name)).(*core.PdfObjectDictionary)
+		if !ok {
+			continue
+		}
+		if dup := job.subsetFont(font); dup != nil {
+			dict.Set(name, dup)
+		}
+	}
+}
+
+// subsetFont returns a copy of the Type 0 font, its descendant font and font descriptor using the
+// subset program, or nil if it isn't subset
+func (job *subsetJob) subsetFont(font *core.PdfObjectDictionary) *core.PdfObjectDictionary {
+	if dup, ok := job.fontDups[font]; ok {
+		return dup
+	}
+	cf := job.compositeFont(font)
+	if cf == nil || job.programs[cf.program] == nil {
+		return nil
+	}
+	tag := job.tags[cf.program]
+
+	descendants := core.TraceToDirectObject(font.Get("DescendantFonts")).(*core.PdfObjectArray)
+	cidFont := copyDict((*descendants)[0]).(*core.PdfObjectDictionary)
+	descriptor := copyDict(cidFont.Get("FontDescriptor")).(*core.PdfObjectDictionary)
+
+	//the CIDs listed by a CIDSet no longer all have glyphs
+	descriptor.Set("FontFile2", job.programs[cf.program])
+	descriptor.Remove("CIDSet")
+	setSubsetName(descriptor, "FontName", tag)
+	cidFont.Set("FontDescriptor", descriptor)
+	setSubsetName(cidFont, "BaseFont", tag)
+
+	dup := copyDict(font).(*core.PdfObjectDictionary)
+	dup.Set("DescendantFonts", core.MakeArray(cidFont))
+	setSubsetName(dup, "BaseFont", tag)
+
+	job.fontDups[font] = dup
+	return dup
+}
+
+// replaceForms replaces the forms in the XObject resource dictionary xobjs, which should be a
+// copy, with copies whose fonts use the subset programs
+func (job *subsetJob) replaceForms(xobjs core.PdfObject) {
+	dict, ok := xobjs.(*core.PdfObjectDictionary)
+	if !ok {
+		return
+	}
+
+	for _, name := range dict.Keys() {
+		if form, ok := core.TraceToDirectObject(dict.Get(name)).(*core.PdfObjectStream); ok && isForm(form) {
+			dict.Set(name, job.subsetForm(form))
+		}
+	}
+}
+
+// subsetForm returns a copy of the form XObject whose fonts use the subset programs
+func (job *subsetJob) subsetForm(form *core.PdfObjectStream) *core.PdfObjectStream {
+	if dup, ok := job.formDups[form]; ok {
+		return dup
+	}
+	//forms drawing themselves are left as they are
+	job.formDups[form] = form
+
+	res, ok := core.TraceToDirectObject(form.Get("Resources")).(*core.PdfObjectDictionary)
+	if !ok {
+		return form
+	}
+	res = copyDict(res).(*core.PdfObjectDictionary)
+	if fonts := res.Get("Font"); fonts != nil {
+		fonts = copyDict(fonts)
+		job.replaceFonts(fonts)
+		res.Set("Font", fonts)
+	}
+	if xobjs := res.Get("XObject"); xobjs != nil {
+		xobjs = copyDict(xobjs)
+		job.replaceForms(xobjs)
+		res.Set("XObject", xobjs)
+	}
+
+	dict := copyDict(form.PdfObjectDictionary).(*core.PdfObjectDictionary)
+	dict.Set("Resources", res)
+	dup := &core.PdfObjectStream{PdfObjectDictionary: dict, Stream: form.Stream}
+
+	job.formDups[form] = dup
+	return dup
+}
+
+// fontFileStream returns a copy of the font program stream program with the data of the subset
+func fontFileStream(program *core.PdfObjectStream, subset []byte) (*core.PdfObjectStream, error) {
+	encoder := core.NewFlateEncoder()
+	encoded, err := encoder.EncodeBytes(subset)
+	if err != nil {
+		return nil, err
+	}
+
+	dict := copyDict(program.PdfObjectDictionary).(*core.PdfObjectDictionary)
+	dict.Set("Filter", core.MakeName(encoder.GetFilterName()))
+	dict.Remove("DecodeParms")
+	dict.Set("Length", core.MakeInteger(int64(len(encoded))))
+	dict.Set("Length1", core.MakeInteger(int64(len(subset))))
+
+	return &core.PdfObjectStream{PdfObjectDictionary: dict, Stream: encoded}, nil
+}
+
+// subsetTag returns the six upper case letters tagging the name of a font subset to the glyphs
+func subsetTag(glyphs map[uint16]bool) string {
+	h := fnv.New32a()
+	for _, gid := range sortedGlyphs(glyphs) {
+		h.Write([]byte{byte(gid >> 8), byte(gid)})
+	}
+
+	sum := h.Sum32()
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(sum%26)
+		sum /= 26
+	}
+	return string(tag)
+}
+
+// sortedGlyphs returns the glyph IDs of glyphs in ascending order
+func sortedGlyphs(glyphs map[uint16]bool) []int {
+	gids := make([]int, 0, len(glyphs))
+	for gid := range glyphs {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+	return gids
+}
+
+// setSubsetName tags the font name under key in dict as a subset, replacing any earlier tag
+func setSubsetName(dict *core.PdfObjectDictionary, key core.PdfObjectName, tag string) {
+	if name, ok := core.TraceToDirectObject(dict.Get(key)).(*core.PdfObjectName); ok {
+		dict.Set(key, core.MakeName(tag+"+"+subsetPrefix.ReplaceAllString(string(*name), "")))
+	}
+}
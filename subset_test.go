@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/unidoc/unidoc/pdf/core"
+)
+
+// Test that the font subsetter keeps the decoded font programs it caches within its limit,
+// dropping the least recently used first.
+func TestFontCacheLimit(t *testing.T) {
+	s := newFontSubsetter()
+	s.limit = 10
+	programs := make([]*core.PdfObjectStream, 4)
+	for i := range programs {
+		programs[i] = &core.PdfObjectStream{PdfObjectDictionary: core.MakeDict(), Stream: []byte("font")}
+	}
+
+	s.decode(programs[0])
+	s.decode(programs[1])
+	s.decode(programs[0])
+	s.decode(programs[2])
+	if s.size > s.limit {
+		t.Errorf("%d bytes cached, over the limit of %d", s.size, s.limit)
+	}
+	for i, kept := range []bool{true, false, true} {
+		if _, ok := s.cache[fontCacheKey{fontSubsetKey: fontSubsetKey{program: programs[i]}, decoded: true}]; ok != kept {
+			t.Errorf("program %d cached: %v, expected %v", i, ok, kept)
+		}
+	}
+
+	//a program over the limit alone isn't cached, but still decoded
+	large := &core.PdfObjectStream{PdfObjectDictionary: core.MakeDict(), Stream: make([]byte, 20)}
+	if data := s.decode(large); len(data) != 20 {
+		t.Errorf("decoded %d bytes, expected 20", len(data))
+	}
+	if s.order.Len() != len(s.cache) || s.size > s.limit {
+		t.Errorf("%d entries, %d in order, %d bytes after a program over the limit", len(s.cache), s.order.Len(), s.size)
+	}
+}
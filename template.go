@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templateVar matches a variable in a -name template, e.g. "{bookmark2}"
+var templateVar = regexp.MustCompile(`\{([a-z]+)([0-9]*)\}`)
+
+// templateVars are the variables a -name template may use. "bookmark" is also accepted with a
+// level, e.g. "bookmark1" for the top-level title.
+var templateVars = map[string]bool{
+	"value":    true, //-re capture group, -field value or -classifier name
+	"bookmark": true, //bookmark title
+	"index":    true, //1-based output number
+	"page":     true, //first input page of the output
+	"title":    true, //document information
+	"author":   true,
+	"subject":  true,
+	"year":     true, //document creation date
+	"month":    true,
+	"day":      true,
+}
+
+// nameTemplate builds output paths such as "{year}/{bookmark1}/{index}.pdf". Each value is
+// passed through nameOptions.fileName, so only the template itself can add directories.
+type nameTemplate struct {
+	tmpl  string
+	names nameOptions
+}
+
+// check returns an error if the template uses an unknown variable. Extra variables may be
+// allowed besides templateVars.
+func (t nameTemplate) check(extra ...string) error {
+	for _, m := range templateVar.FindAllStringSubmatch(t.tmpl, -1) {
+		known := templateVars[m[1]]
+		for _, v := range extra {
+			known = known || m[1] == v
+		}
+		if !known || (m[2] != "" && m[1] != "bookmark") {
+			return fmt.Errorf("unknown variable %s in template %q", m[0], t.tmpl)
+		}
+	}
+	return nil
+}
+
+// expand returns the output path for vars. Missing or empty values leave their path element
+// out, e.g. "{bookmark2}" for a top-level bookmark.
+func (t nameTemplate) expand(vars map[string]string) string {
+	name := templateVar.ReplaceAllStringFunc(t.tmpl, func(v string) string {
+		value := vars[v[1:len(v)-1]]
+		if value == "" {
+			return ""
+		}
+		return t.names.fileName(value)
+	})
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return t.names.fileName("")
+	}
+
+	return name
+}
+
+// expandRaw returns the template with the values in vars, unchanged
+func (t nameTemplate) expandRaw(vars map[string]string) string {
+	return templateVar.ReplaceAllStringFunc(t.tmpl, func(v string) string {
+		return vars[v[1:len(v)-1]]
+	})
+}
+
+// uniqueName returns name, or name with a number added before the extension if it was
+// returned before, so outputs with the same name don't overwrite each other. Numbered names are
+// recorded too, and skipped if a name already took them, e.g. "a-2.pdf" from a title.
+func uniqueName(used map[string]int, name string) string {
+	used[name]++
+	if used[name] == 1 {
+		return name
+	}
+
+	ext := path.Ext(name)
+	for {
+		unique := fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), used[name], ext)
+		if used[unique] == 0 {
+			used[unique] = 1
+			return unique
+		}
+		used[name]++
+	}
+}
+
+// bookmarkVars adds the template variables for a bookmark
+func bookmarkVars(vars map[string]string, b bookmark) {
+	vars["bookmark"] = b.title()
+	for i, title := range b.titles {
+		vars["bookmark"+strconv.Itoa(i+1)] = title
+	}
+}
+
+// docVars returns the template variables taken from the document information dictionary
+func docVars(info map[string]string) map[string]string {
+	vars := map[string]string{
+		"title":   info["Title"],
+		"author":  info["Author"],
+		"subject": info["Subject"],
+	}
+
+	//dates are "D:YYYYMMDDHHmmSSOHH'mm", with everything after the year optional
+	date := strings.TrimPrefix(info["CreationDate"], "D:")
+	if len(date) >= 4 {
+		vars["year"] = date[:4]
+	}
+	if len(date) >= 6 {
+		vars["month"] = date[4:6]
+	}
+	if len(date) >= 8 {
+		vars["day"] = date[6:8]
+	}
+
+	return vars
+}
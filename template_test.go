@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+)
+
+// Test that templates accept only known variables, with a level for bookmark only.
+func TestTemplateCheck(t *testing.T) {
+	tests := []struct {
+		tmpl  string
+		valid bool
+	}{
+		{"{year}/{bookmark1}/{index}.pdf", true},
+		{"{title} - {author} {month}-{day}.pdf", true},
+		{"{bookmark12}.pdf", true},
+		{"literal.pdf", true},
+		{"{value}-{page}.pdf", true},
+		{"{name}.pdf", false},
+		{"{index2}.pdf", false},
+		{"{field}.pdf", true}, //allowed as extra
+	}
+	for _, test := range tests {
+		err := nameTemplate{tmpl: test.tmpl}.check("field")
+		if (err == nil) != test.valid {
+			t.Errorf("%s: %v", test.tmpl, err)
+		}
+	}
+}
+
+// Test that expanded templates stay inside the output directory, whether ".." comes from the
+// template or a value, and that empty values leave their path element out.
+func TestTemplateExpand(t *testing.T) {
+	vars := map[string]string{
+		"title":     "Annual Report",
+		"year":      "2024",
+		"bookmark1": "Part I",
+		"index":     "3",
+		"dots":      "..",
+		"path":      "../../etc/passwd",
+	}
+	tests := []struct {
+		tmpl, expected string
+	}{
+		{"{year}/{bookmark1}/{index}.pdf", "2024/Part I/3.pdf"},
+		{"{year}/{bookmark2}/{index}.pdf", "2024/3.pdf"},
+		{"../{title}.pdf", "Annual Report.pdf"},
+		{"../../../{index}.pdf", "3.pdf"},
+		{"{year}/../../{index}.pdf", "3.pdf"},
+		{"/abs/{index}.pdf", "abs/3.pdf"},
+		{"./{year}//{index}.pdf", "2024/3.pdf"},
+		{"{dots}/{index}.pdf", "_../3.pdf"},
+		{"{dots}", "_.."},
+		{"{path}.pdf", ".._.._etc_passwd.pdf"},
+		{"{missing}", "_"},
+		{"..", "_"},
+	}
+	for _, test := range tests {
+		tmpl := nameTemplate{tmpl: test.tmpl, names: nameOptions{policy: sanitizePOSIX, replace: "_"}}
+		if got := tmpl.expand(vars); got != test.expected {
+			t.Errorf("%s: %q, expected %q", test.tmpl, got, test.expected)
+		}
+	}
+}
+
+// Test that uniqueName numbers repeated names before their extension, without colliding with
+// names that are taken already.
+func TestUniqueName(t *testing.T) {
+	tests := []struct {
+		names, expected []string
+	}{
+		{[]string{"a.pdf", "b.pdf", "a.pdf", "a.pdf"}, []string{"a.pdf", "b.pdf", "a-2.pdf", "a-3.pdf"}},
+		{[]string{"dir/a.pdf", "dir/a.pdf", "a.pdf"}, []string{"dir/a.pdf", "dir/a-2.pdf", "a.pdf"}},
+		{[]string{"v1.2/notes", "v1.2/notes"}, []string{"v1.2/notes", "v1.2/notes-2"}},
+		{[]string{"a-2.pdf", "a.pdf", "a.pdf"}, []string{"a-2.pdf", "a.pdf", "a-3.pdf"}},
+		{[]string{"a.pdf", "a.pdf", "a-2.pdf"}, []string{"a.pdf", "a-2.pdf", "a-2-2.pdf"}},
+		{[]string{"a.pdf", "a-2.pdf", "a-3.pdf", "a.pdf", "a.pdf"}, []string{"a.pdf", "a-2.pdf", "a-3.pdf", "a-4.pdf", "a-5.pdf"}},
+	}
+	for _, test := range tests {
+		used := map[string]int{}
+		seen := map[string]bool{}
+		for i, name := range test.names {
+			got := uniqueName(used, name)
+			if got != test.expected[i] {
+				t.Errorf("%v: name %d %q, expected %q", test.names, i+1, got, test.expected[i])
+			}
+			if seen[got] {
+				t.Errorf("%v: %q returned twice", test.names, got)
+			}
+			seen[got] = true
+		}
+	}
+}
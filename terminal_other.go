@@ -0,0 +1,18 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// isTerminal can't tell terminals apart on this OS, so passwords are read from stdin as they are
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+// readNoEcho isn't supported on this OS
+func readNoEcho(f *os.File) (string, error) {
+	return "", errors.New("no-echo password prompt not supported")
+}
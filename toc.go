@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+	"github.com/unidoc/unidoc/pdf/model/fonts"
+	"github.com/unidoc/unidoc/pdf/model/textencoding"
+)
+
+// layout of table of contents pages, in points
+const (
+	tocMargin      = 72
+	tocHeadingSize = 18
+	tocFontSize    = 11
+	tocLeading     = 18 //baseline to baseline
+)
+
+// tocEntry is a line of the table of contents, linking to the first page of a merged input
+type tocEntry struct {
+	text string
+	page int //0-based index in the merged pages, not counting the contents pages
+}
+
+// tocEntries returns an entry for each input, with its line built from tmpl. The template has
+// the document information variables of the input plus {name} (its file name without
+// extension), {index}, {page} (its first page in the merged output) and {pages} (its page
+// count). {title} falls back to {name} for inputs without a title.
+func tocEntries(tmpl nameTemplate, fns []string, inputs []*model.PdfReader, tocPages int) []tocEntry {
+	var entries []tocEntry
+	first := 0
+	for i, pdf := range inputs {
+		vars := docVars(docInfo(pdf))
+		vars["name"] = strings.TrimSuffix(filepath.Base(fns[i]), filepath.Ext(fns[i]))
+		if vars["title"] == "" {
+			vars["title"] = vars["name"]
+		}
+		vars["index"] = strconv.Itoa(i + 1)
+		vars["page"] = strconv.Itoa(tocPages + first + 1)
+		vars["pages"] = strconv.Itoa(len(pdf.PageList))
+
+		entries = append(entries, tocEntry{text: tmpl.expandRaw(vars), page: first})
+		first += len(pdf.PageList)
+	}
+	return entries
+}
+
+// tocLayout places the lines of a table of contents on pages of a size
+type tocLayout struct {
+	width, height float64
+	heading       string
+}
+
+// newTOCLayout returns the layout for contents pages the size of the first merged page, or A4 if
+// its size isn't known
+func newTOCLayout(heading string, pages []*model.PdfPage) tocLayout {
+	l := tocLayout{width: 595, height: 842, heading: heading}
+	if len(pages) > 0 {
+		if box, err := pages[0].GetMediaBox(); err == nil && box.Urx-box.Llx > 2*tocMargin && box.Ury-box.Lly > 3*tocMargin {
+			l.width, l.height = box.Urx-box.Llx, box.Ury-box.Lly
+		}
+	}
+	return l
+}
+
+// perPage returns the number of entries fitting on a contents page, below the heading on the first
+func (l tocLayout) perPage(first bool) int {
+	space := l.height - 2*tocMargin
+	if first && l.heading != "" {
+		space -= 2 * tocLeading
+	}
+	return int(space / tocLeading)
+}
+
+// pageCount returns the number of contents pages n entries take
+func (l tocLayout) pageCount(n int) int {
+	count := 1
+	for n -= l.perPage(true); n > 0; n -= l.perPage(false) {
+		count++
+	}
+	return count
+}
+
+// tocPages returns the contents pages for entries, which link to the merged pages following them
+func (l tocLayout) tocPages(entries []tocEntry, pages []*model.PdfPage) ([]*model.PdfPage, error) {
+	regular, bold := fonts.NewFontHelvetica(), fonts.NewFontHelveticaBold()
+	numPages := l.pageCount(len(entries))
+
+	var out []*model.PdfPage
+	for len(out) < numPages {
+		p := model.NewPdfPage()
+		p.MediaBox = &model.PdfRectangle{Urx: l.width, Ury: l.height}
+		p.Resources = model.NewPdfPageResources()
+		if err := p.Resources.SetFontByName("F1", regular.ToPdfObject()); err != nil {
+			return nil, err
+		}
+
+		var content strings.Builder
+		y := l.height - tocMargin
+		if len(out) == 0 && l.heading != "" {
+			if err := p.Resources.SetFontByName("F2", bold.ToPdfObject()); err != nil {
+				return nil, err
+			}
+			y -= tocHeadingSize
+			fmt.Fprintf(&content, "BT /F2 %d Tf %d %.2f Td %s Tj ET\n", tocHeadingSize, tocMargin, y, winAnsiString(l.heading))
+			y -= 2*tocLeading - tocHeadingSize
+		}
+
+		n := l.perPage(len(out) == 0)
+		if n > len(entries) {
+			n = len(entries)
+		}
+		for _, e := range entries[:n] {
+			y -= tocLeading
+
+			//the page number is right aligned, the text cut to fit before it
+			number := strconv.Itoa(numPages + e.page + 1)
+			numberWidth := textWidth(regular, number, tocFontSize)
+			right := l.width - tocMargin
+			text := fitText(regular, e.text, tocFontSize, right-tocMargin-numberWidth-tocFontSize)
+			fmt.Fprintf(&content, "BT /F1 %d Tf %d %.2f Td %s Tj ET\n", tocFontSize, tocMargin, y, winAnsiString(text))
+			fmt.Fprintf(&content, "BT /F1 %d Tf %.2f %.2f Td %s Tj ET\n", tocFontSize, right-numberWidth, y, winAnsiString(number))
+
+			link := model.NewPdfAnnotationLink()
+			link.Rect = core.MakeArray(core.MakeFloat(tocMargin), core.MakeFloat(y-tocLeading/4), core.MakeFloat(right), core.MakeFloat(y+tocFontSize))
+			link.Border = core.MakeArray(core.MakeInteger(0), core.MakeInteger(0), core.MakeInteger(0))
+			link.F = core.MakeInteger(4) //print
+			link.Dest = core.MakeArray(pages[e.page].GetPageAsIndirectObject(), core.MakeName("Fit"))
+			p.Annotations = append(p.Annotations, link.PdfAnnotation)
+		}
+		entries = entries[n:]
+
+		p.Contents = makeContentStream(content.String())
+		out = append(out, p)
+	}
+
+	return out, nil
+}
+
+// glyphFont is a standard font with metrics
+type glyphFont interface {
+	GetGlyphCharMetrics(glyph string) (fonts.CharMetrics, bool)
+}
+
+// textWidth returns the width of s in font at size, as encoded by winAnsiString
+func textWidth(font glyphFont, s string, size float64) float64 {
+	enc := textencoding.NewWinAnsiTextEncoder()
+	var width float64
+	for _, b := range []byte(winAnsi(s)) {
+		if glyph, ok := enc.CharcodeToGlyph(b); ok {
+			if m, ok := font.GetGlyphCharMetrics(glyph); ok {
+				width += m.Wx
+			}
+		}
+	}
+	return width * size / 1000
+}
+
+// fitText returns s, cut and ended with an ellipsis if it is wider than width
+func fitText(font glyphFont, s string, size, width float64) string {
+	if textWidth(font, s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(font, string(runes)+"…", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "…"
+}
+
+// winAnsi returns s in WinAnsiEncoding, the encoding of the standard fonts on contents pages,
+// with "?" for characters it lacks
+func winAnsi(s string) string {
+	enc := textencoding.NewWinAnsiTextEncoder()
+	var b []byte
+	for _, r := range s {
+		c, ok := enc.RuneToCharcode(r)
+		if !ok {
+			c = '?'
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+// winAnsiString returns s as a content stream string operand in WinAnsiEncoding
+func winAnsiString(s string) string {
+	return core.MakeString(winAnsi(s)).DefaultWriteString()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// qrQuietZone is the light border a QR code needs around it, in modules
+const qrQuietZone = 4
+
+// qrStamper stamps a QR code identifying each output on its first page, so printed copies
+// that come back can be matched to the output they were printed from
+type qrStamper struct {
+	job       string
+	vertical  string
+	alignment string
+	size      float64 //width of the code with its quiet zone, in points
+	margin    float64
+	manifest  *csv.Writer //if set, output names and code contents are written to it
+	manifestF *os.File
+}
+
+// newQRStamper returns a stamper for the job ID, placing codes of size points at pos, e.g.
+// "bottom-right"
+func newQRStamper(job, pos string, size, margin float64) (*qrStamper, error) {
+	if size <= 0 {
+		return nil, errors.New("QR code size must be positive")
+	}
+
+	q := &qrStamper{job: job, size: size, margin: margin}
+	var err error
+	if q.vertical, q.alignment, err = parsePosition(pos); err != nil {
+		return nil, fmt.Errorf("QR code %v", err)
+	}
+	return q, nil
+}
+
+// createManifest creates the manifest CSV file fn, with a row of output name, job ID, part index,
+// checksum for each output
+func (q *qrStamper) createManifest(fn string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+
+	q.manifestF = f
+	q.manifest = csv.NewWriter(f)
+	return nil
+}
+
+// qrLabel identifies an output in its QR code
+type qrLabel struct {
+	part     string //output index
+	checksum string
+}
+
+// label returns the label of an output holding the input pages. The checksum is the start of a
+// SHA-256 hash of the content hashes of its pages, before any transform, so reprinting the same
+// pages gives the same checksum.
+func (q *qrStamper) label(pages []*model.PdfPage, vars map[string]string) qrLabel {
+	h := sha256.New()
+	for _, ph := range pageHashes(pages) {
+		h.Write([]byte(ph))
+	}
+	return qrLabel{part: vars["index"], checksum: hex.EncodeToString(h.Sum(nil))[:16]}
+}
+
+// text returns the contents of the QR code for label, e.g.
+// "job=2024-07&part=3&sha256=9f86d081884c7d65"
+func (q *qrStamper) text(label qrLabel) string {
+	v := url.Values{}
+	v.Set("job", q.job)
+	v.Set("part", label.part)
+	v.Set("sha256", label.checksum)
+	return v.Encode()
+}
+
+// stamp returns pages with the QR code of label on the first page, and adds the output name to
+// the manifest
+func (q *qrStamper) stamp(name string, pages []*model.PdfPage, label qrLabel) ([]*model.PdfPage, error) {
+	if len(pages) == 0 {
+		return pages, nil
+	}
+
+	code, err := encodeQR([]byte(q.text(label)))
+	if err != nil {
+		return nil, err
+	}
+
+	view, err := newPageView(pages[0])
+	if err != nil {
+		return nil, err
+	}
+	dup, err := copyPage(pages[0])
+	if err != nil {
+		return nil, err
+	}
+
+	//a light background with the quiet zone, and a rectangle for each run of dark modules
+	module := q.size / float64(len(code)+2*qrQuietZone)
+	x, y := view.place(q.vertical, q.alignment, q.size, q.size, q.margin)
+	var draw strings.Builder
+	fmt.Fprintf(&draw, "q %s1 g %.2f %.2f %.2f %.2f re f 0 g\n", view.matrix, x, y, q.size, q.size)
+	for r, row := range code {
+		top := y + q.size - float64(qrQuietZone+r)*module
+		for c := 0; c < len(row); c++ {
+			if !row[c] {
+				continue
+			}
+			start := c
+			for c+1 < len(row) && row[c+1] {
+				c++
+			}
+			fmt.Fprintf(&draw, "%.3f %.3f %.3f %.3f re\n", x+float64(qrQuietZone+start)*module, top-module, float64(c-start+1)*module, module)
+		}
+	}
+	draw.WriteString("f Q\n")
+	stampContents(dup, "", draw.String())
+
+	if q.manifest != nil {
+		q.manifest.Write([]string{name, q.job, label.part, label.checksum})
+		if q.manifest.Flush(); q.manifest.Error() != nil {
+			return nil, errors.New("unable to write QR code manifest")
+		}
+	}
+
+	return append([]*model.PdfPage{dup}, pages[1:]...), nil
+}
+
+// close finishes the manifest, if any
+func (q *qrStamper) close() error {
+	if q.manifest == nil {
+		return nil
+	}
+
+	q.manifest.Flush()
+	if err := q.manifest.Error(); err != nil {
+		q.manifestF.Close()
+		return err
+	}
+	return q.manifestF.Close()
+}
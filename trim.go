@@ -0,0 +1,223 @@
+package main
+
+import (
+	"math"
+
+	"github.com/unidoc/unidoc/pdf/contentstream"
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// darkLevel is the gray level, from 0 for black to 1 for white, below which a scan sample is dark
+const darkLevel = 0.25
+
+// darkLineShare is the share of dark samples from which a row or column at the edge of a scan is
+// part of its border, so dust and stray light pixels don't end the border
+const darkLineShare = 0.9
+
+// minScanCoverage is the share of a page a single image must cover for the page to be taken as a
+// scan
+const minScanCoverage = 0.9
+
+// borderTrimmer crops the dark borders scanners leave around pages, where the scanner lid or
+// the book edge shows, by tightening the CropBox of output pages. Pages are taken as scans if a
+// single image covers most of them, and the border of that image is found from its samples.
+type borderTrimmer struct {
+	maxMargin float64                                 //maximum trimmed from each edge, in points
+	borders   map[*core.PdfObjectStream]*imageBorders //borders of scan images, nil if none, shared by outputs
+}
+
+// imageBorders are the dark borders of an image, as shares of its width and height
+type imageBorders struct {
+	left, right, top, bottom float64
+}
+
+func newBorderTrimmer(maxMargin float64) *borderTrimmer {
+	return &borderTrimmer{maxMargin: maxMargin, borders: map[*core.PdfObjectStream]*imageBorders{}}
+}
+
+// apply is a pageTransform cropping the dark borders of scanned pages
+func (t *borderTrimmer) apply(pages []*model.PdfPage) ([]*model.PdfPage, error) {
+	trimmed := make([]*model.PdfPage, len(pages))
+
+	for i, p := range pages {
+		trimmed[i] = p
+
+		box, _, err := visibleBox(p)
+		if err != nil {
+			return nil, err
+		}
+		stream, ctm, ok := scanImage(p, box)
+		if !ok {
+			continue
+		}
+		b, err := t.imageBorders(stream)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			continue
+		}
+
+		//the borders of the image as drawn on the page, which may flip it
+		llx, urx := ctm[4], ctm[4]+ctm[0]
+		lly, ury := ctm[5], ctm[5]+ctm[3]
+		left, right, bottom, top := b.left, b.right, b.bottom, b.top
+		if ctm[0] < 0 {
+			llx, urx, left, right = urx, llx, right, left
+		}
+		if ctm[3] < 0 {
+			lly, ury, bottom, top = ury, lly, top, bottom
+		}
+		w, h := urx-llx, ury-lly
+
+		crop := *box
+		crop.Llx += t.trim(llx+left*w-box.Llx, left)
+		crop.Urx -= t.trim(box.Urx-(urx-right*w), right)
+		crop.Lly += t.trim(lly+bottom*h-box.Lly, bottom)
+		crop.Ury -= t.trim(box.Ury-(ury-top*h), top)
+		if crop == *box {
+			continue
+		}
+
+		dup := p.Duplicate()
+		dup.CropBox = &crop
+		trimmed[i] = dup
+	}
+
+	return trimmed, nil
+}
+
+// trim returns how far to move an edge of the page box in, given the distance from it to the
+// inner edge of the border, and the share of the image the border takes
+func (t *borderTrimmer) trim(distance, border float64) float64 {
+	if border == 0 || distance <= 0 {
+		return 0
+	}
+	return math.Min(distance, t.maxMargin)
+}
+
+// imageBorders returns the dark borders of the image stream, or nil if it has none or can't be
+// decoded
+func (t *borderTrimmer) imageBorders(stream *core.PdfObjectStream) (*imageBorders, error) {
+	if b, ok := t.borders[stream]; ok {
+		return b, nil
+	}
+	t.borders[stream] = nil
+
+	ximg, err := model.NewXObjectImageFromStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	if mask, ok := core.TraceToDirectObject(ximg.ImageMask).(*core.PdfObjectBool); ok && bool(*mask) || ximg.ColorSpace == nil {
+		return nil, nil
+	}
+	img, err := ximg.ToImage()
+	if err != nil {
+		if undecodable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	gray, err := imageToGray(ximg.ColorSpace, img)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := int(gray.Width), int(gray.Height)
+	samples := gray.GetSamples()
+	if width == 0 || height == 0 || len(samples) < width*height {
+		return nil, nil
+	}
+	dark := uint32(darkLevel * float64(uint32(1)<<uint(gray.BitsPerComponent)-1))
+	//darkLines counts the dark lines from an edge, up to half the image, where line i has n
+	//samples, sample j at index at(i, j)
+	darkLines := func(lines, n int, at func(i, j int) int) int {
+		for i := 0; i < lines/2; i++ {
+			count := 0
+			for j := 0; j < n; j++ {
+				if samples[at(i, j)] < dark {
+					count++
+				}
+			}
+			if float64(count) < darkLineShare*float64(n) {
+				return i
+			}
+		}
+		return lines / 2
+	}
+
+	b := &imageBorders{
+		top:    float64(darkLines(height, width, func(i, j int) int { return i*width + j })) / float64(height),
+		bottom: float64(darkLines(height, width, func(i, j int) int { return (height-1-i)*width + j })) / float64(height),
+		left:   float64(darkLines(width, height, func(i, j int) int { return j*width + i })) / float64(width),
+		right:  float64(darkLines(width, height, func(i, j int) int { return j*width + width - 1 - i })) / float64(width),
+	}
+	if *b == (imageBorders{}) {
+		return nil, nil
+	}
+	t.borders[stream] = b
+	return b, nil
+}
+
+// scanImage returns the image XObject drawn over most of box by the content of p, as a scan is,
+// and the transformation it is drawn at, which must not rotate it. Forms are not followed.
+func scanImage(p *model.PdfPage, box *model.PdfRectangle) (*core.PdfObjectStream, matrix, bool) {
+	content, err := p.GetAllContentStreams()
+	if err != nil {
+		return nil, identity, false
+	}
+	res, err := pageResources(p)
+	if err != nil || res == nil {
+		return nil, identity, false
+	}
+	xobjs, _ := core.TraceToDirectObject(resourcesDict(res).Get("XObject")).(*core.PdfObjectDictionary)
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil || xobjs == nil {
+		return nil, identity, false
+	}
+
+	boxArea := (box.Urx - box.Llx) * (box.Ury - box.Lly)
+	var scan *core.PdfObjectStream
+	var scanCTM matrix
+	ctm := identity
+	var stack []matrix
+	for _, op := range *ops {
+		switch op.Operand {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := toMatrix(op.Params); ok {
+				ctm = m.mul(ctm)
+			}
+		case "Do":
+			if len(op.Params) != 1 || ctm[1] != 0 || ctm[2] != 0 {
+				continue
+			}
+			name, ok := op.Params[0].(*core.PdfObjectName)
+			if !ok {
+				continue
+			}
+			stream, ok := core.TraceToDirectObject(xobjs.Get(*name)).(*core.PdfObjectStream)
+			if !ok {
+				continue
+			}
+			if subtype, ok := core.TraceToDirectObject(stream.Get("Subtype")).(*core.PdfObjectName); !ok || *subtype != "Image" {
+				continue
+			}
+
+			//the part of box the unit square of the image covers
+			w := math.Min(box.Urx, math.Max(ctm[4], ctm[4]+ctm[0])) - math.Max(box.Llx, math.Min(ctm[4], ctm[4]+ctm[0]))
+			h := math.Min(box.Ury, math.Max(ctm[5], ctm[5]+ctm[3])) - math.Max(box.Lly, math.Min(ctm[5], ctm[5]+ctm[3]))
+			if w > 0 && h > 0 && w*h >= minScanCoverage*boxArea {
+				scan, scanCTM = stream, ctm
+			}
+		}
+	}
+
+	return scan, scanCTM, scan != nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// trueTypeTable is a table of a TrueType font program
+type trueTypeTable struct {
+	tag  string
+	data []byte
+}
+
+// errTrueType is returned for font programs that aren't well-formed TrueType
+var errTrueType = errors.New("invalid TrueType font")
+
+// parseTrueType returns the tables of the TrueType font program data, by tag
+func parseTrueType(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, errTrueType
+	}
+	if version := binary.BigEndian.Uint32(data); version != 0x00010000 && version != 0x74727565 { //"true"
+		return nil, errTrueType
+	}
+
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, errTrueType
+	}
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		rec := data[12+16*i:]
+		offset, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, errTrueType
+		}
+		tables[string(rec[:4])] = data[offset : offset+length]
+	}
+
+	return tables, nil
+}
+
+// glyph flags of composite glyph components
+const (
+	argsAreWords   = 0x0001
+	haveScale      = 0x0008
+	moreComponents = 0x0020
+	haveXYScale    = 0x0040
+	haveTwoByTwo   = 0x0080
+)
+
+// compositeHeader is the size of the numberOfContours and bounding box before the components of
+// a composite glyph
+const compositeHeader = 10
+
+// subsetTrueType returns data with the outlines of all glyphs but those in keep, the glyphs
+// their composite glyphs are built from and .notdef removed. Glyph IDs are kept, so content and
+// CIDToGIDMaps referring to them stay valid.
+func subsetTrueType(data []byte, keep map[uint16]bool) ([]byte, error) {
+	tables, err := parseTrueType(data)
+	if err != nil {
+		return nil, err
+	}
+	head, maxp, loca, glyf := tables["head"], tables["maxp"], tables["loca"], tables["glyf"]
+	if len(head) < 54 || len(maxp) < 6 || loca == nil || glyf == nil {
+		return nil, errTrueType
+	}
+
+	//read the glyph offsets
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+	offsets := make([]uint32, numGlyphs+1)
+	for i := range offsets {
+		if longLoca {
+			if len(loca) < 4*(i+1) {
+				return nil, errTrueType
+			}
+			offsets[i] = binary.BigEndian.Uint32(loca[4*i:])
+		} else {
+			if len(loca) < 2*(i+1) {
+				return nil, errTrueType
+			}
+			offsets[i] = 2 * uint32(binary.BigEndian.Uint16(loca[2*i:]))
+		}
+	}
+	glyph := func(gid int) []byte {
+		if offsets[gid] >= offsets[gid+1] || offsets[gid+1] > uint32(len(glyf)) {
+			return nil
+		}
+		return glyf[offsets[gid]:offsets[gid+1]]
+	}
+
+	//add .notdef and the components of composite glyphs
+	kept := map[int]bool{0: true}
+	todo := []int{0}
+	for gid := range keep {
+		if int(gid) < numGlyphs && !kept[int(gid)] {
+			kept[int(gid)] = true
+			todo = append(todo, int(gid))
+		}
+	}
+	for len(todo) > 0 {
+		g := glyph(todo[len(todo)-1])
+		todo = todo[:len(todo)-1]
+		if len(g) < compositeHeader || int16(binary.BigEndian.Uint16(g)) >= 0 {
+			continue
+		}
+		for pos := compositeHeader; pos+4 <= len(g); {
+			flags := binary.BigEndian.Uint16(g[pos:])
+			component := int(binary.BigEndian.Uint16(g[pos+2:]))
+			if component < numGlyphs && !kept[component] {
+				kept[component] = true
+				todo = append(todo, component)
+			}
+			if flags&moreComponents == 0 {
+				break
+			}
+			pos += 4
+			if flags&argsAreWords != 0 {
+				pos += 4
+			} else {
+				pos += 2
+			}
+			switch {
+			case flags&haveScale != 0:
+				pos += 2
+			case flags&haveXYScale != 0:
+				pos += 4
+			case flags&haveTwoByTwo != 0:
+				pos += 8
+			}
+		}
+	}
+
+	//rebuild glyf and loca with the kept glyphs only, with long offsets
+	var newGlyf []byte
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for gid := 0; gid < numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(newLoca[4*gid:], uint32(len(newGlyf)))
+		if kept[gid] {
+			newGlyf = append(newGlyf, glyph(gid)...)
+			for len(newGlyf)%4 != 0 {
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(len(newGlyf)))
+
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+	tables["head"], tables["loca"], tables["glyf"] = newHead, newLoca, newGlyf
+
+	var list []trueTypeTable
+	for tag, data := range tables {
+		list = append(list, trueTypeTable{tag: tag, data: data})
+	}
+	return writeTrueType(list), nil
+}
+
+// writeTrueType returns a TrueType font program of the tables, with their checksums and the
+// head checksum adjustment updated
+func writeTrueType(tables []trueTypeTable) []byte {
+	sort.Slice(tables, func(i, j int) bool { return tables[i].tag < tables[j].tag })
+
+	//the search fields of the table directory, from the largest power of two tables
+	numTables := len(tables)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	out := make([]byte, 12+16*numTables)
+	binary.BigEndian.PutUint32(out, 0x00010000)
+	binary.BigEndian.PutUint16(out[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(16*numTables-searchRange))
+
+	headOffset := -1
+	for i, t := range tables {
+		data := t.data
+		if t.tag == "head" {
+			headOffset = len(out)
+			data = append([]byte(nil), data...)
+			binary.BigEndian.PutUint32(data[8:], 0)
+		}
+
+		rec := out[12+16*i:]
+		copy(rec, t.tag)
+		binary.BigEndian.PutUint32(rec[4:], trueTypeChecksum(data))
+		binary.BigEndian.PutUint32(rec[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(data)))
+
+		out = append(out, data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+
+	if headOffset >= 0 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-trueTypeChecksum(out))
+	}
+	return out
+}
+
+// trueTypeChecksum is the sum of data as big-endian uint32s, padded with zeros
+func trueTypeChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
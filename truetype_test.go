@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/unidoc/unidoc/pdf/core"
+)
+
+// testGlyphs are the glyphs of testTrueType: simple glyphs with their ID as xMin, composites of
+// them, a large glyph no composite uses and an empty one
+var testGlyphs = [][]byte{
+	simpleGlyph(0, 0),
+	simpleGlyph(1, 0),
+	simpleGlyph(2, 0),
+	compositeGlyph(
+		[]byte{0x00, 0x29, 0x00, 0x02, 0x00, 0x10, 0xff, 0xf0, 0x20, 0x00}, //words, scale, more: glyph 2
+		[]byte{0x00, 0x00, 0x00, 0x04, 0x05, 0x06},                         //bytes: glyph 4
+	),
+	simpleGlyph(4, 0),
+	compositeGlyph(
+		[]byte{0x00, 0xa0, 0x00, 0x01, 0x01, 0x02, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40, 0x00}, //2x2, more: glyph 1
+		[]byte{0x00, 0x41, 0x00, 0x07, 0x00, 0x01, 0x00, 0x02, 0x40, 0x00, 0x20, 0x00},             //words, x and y scale: glyph 7
+	),
+	simpleGlyph(6, 400),
+	simpleGlyph(7, 0),
+	nil,
+}
+
+// simpleGlyph returns a glyph of one contour of a single point, with xMin id, and extra bytes of
+// padding
+func simpleGlyph(id int16, extra int) []byte {
+	g := make([]byte, 16+extra)
+	binary.BigEndian.PutUint16(g, 1)
+	binary.BigEndian.PutUint16(g[2:], uint16(id))
+	g[14] = 0x31 //on curve, x and y same
+	return g
+}
+
+// compositeGlyph returns a glyph of the components, padded to an even length
+func compositeGlyph(components ...[]byte) []byte {
+	g := []byte{0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0}
+	for _, c := range components {
+		g = append(g, c...)
+	}
+	if len(g)%2 != 0 {
+		g = append(g, 0)
+	}
+	return g
+}
+
+// testTrueType returns a font program of testGlyphs with short loca offsets, with its table
+// checksums left zero
+func testTrueType() []byte {
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint32(head, 0x00010000)
+	binary.BigEndian.PutUint32(head[12:], 0x5f0f3cf5)
+	maxp := []byte{0x00, 0x00, 0x50, 0x00, 0x00, byte(len(testGlyphs))}
+	var glyf, loca []byte
+	for _, g := range testGlyphs {
+		loca = binary.BigEndian.AppendUint16(loca, uint16(len(glyf)/2))
+		glyf = append(glyf, g...)
+	}
+	loca = binary.BigEndian.AppendUint16(loca, uint16(len(glyf)/2))
+
+	tables := []trueTypeTable{{"cmap", []byte("cmap data")}, {"glyf", glyf}, {"head", head}, {"loca", loca}, {"maxp", maxp}}
+	out := make([]byte, 12+16*len(tables))
+	binary.BigEndian.PutUint32(out, 0x00010000)
+	binary.BigEndian.PutUint16(out[4:], uint16(len(tables)))
+	for i, t := range tables {
+		rec := out[12+16*i:]
+		copy(rec, t.tag)
+		binary.BigEndian.PutUint32(rec[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(t.data)))
+		out = append(out, t.data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out
+}
+
+// checkTrueTypeSubset checks that subset is a well-formed font program holding the glyphs of
+// testGlyphs in kept only, returning the first problem found
+func checkTrueTypeSubset(subset []byte, kept []int) error {
+	tables, err := parseTrueType(subset)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(tables); i++ {
+		rec := subset[12+16*i:]
+		tag, sum := string(rec[:4]), binary.BigEndian.Uint32(rec[4:])
+		data := append([]byte(nil), tables[tag]...)
+		if tag == "head" {
+			binary.BigEndian.PutUint32(data[8:], 0)
+		}
+		if sum != trueTypeChecksum(data) {
+			return fmt.Errorf("%s checksum %#x, expected %#x", tag, sum, trueTypeChecksum(data))
+		}
+	}
+	if sum := trueTypeChecksum(subset); sum != 0xB1B0AFBA {
+		return fmt.Errorf("font checksum %#x", sum)
+	}
+	if string(tables["cmap"]) != "cmap data" || len(tables["maxp"]) != 6 {
+		return fmt.Errorf("cmap %q, maxp % x", tables["cmap"], tables["maxp"])
+	}
+	if format := binary.BigEndian.Uint16(tables["head"][50:]); format != 1 {
+		return fmt.Errorf("loca format %d, expected long", format)
+	}
+
+	loca, glyf := tables["loca"], tables["glyf"]
+	if len(loca) != 4*(len(testGlyphs)+1) {
+		return fmt.Errorf("loca of %d bytes", len(loca))
+	}
+	var got []int
+	for gid, g := range testGlyphs {
+		start, end := binary.BigEndian.Uint32(loca[4*gid:]), binary.BigEndian.Uint32(loca[4*gid+4:])
+		if start > end || end > uint32(len(glyf)) || start%4 != 0 {
+			return fmt.Errorf("glyph %d at %d to %d", gid, start, end)
+		}
+		if end > start {
+			got = append(got, gid)
+			if !bytes.HasPrefix(glyf[start:end], g) || end-start-uint32(len(g)) > 3 {
+				return fmt.Errorf("glyph %d is % x, expected % x", gid, glyf[start:end], g)
+			}
+		}
+	}
+	if got == nil {
+		got = []int{}
+	}
+	if !reflect.DeepEqual(got, kept) {
+		return fmt.Errorf("glyphs %v, expected %v", got, kept)
+	}
+	return nil
+}
+
+// Test that subsetting keeps .notdef, the glyphs asked for and the components of composite
+// glyphs, whatever their argument and scale forms, and writes a font that parses again.
+func TestSubsetTrueType(t *testing.T) {
+	tests := []struct {
+		keep []uint16
+		kept []int
+	}{
+		{nil, []int{0}},
+		{[]uint16{1}, []int{0, 1}},
+		{[]uint16{3}, []int{0, 2, 3, 4}},
+		{[]uint16{5}, []int{0, 1, 5, 7}},
+		{[]uint16{3, 5, 6}, []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{[]uint16{8, 100}, []int{0}},
+	}
+	for _, test := range tests {
+		keep := map[uint16]bool{}
+		for _, gid := range test.keep {
+			keep[gid] = true
+		}
+		subset, err := subsetTrueType(testTrueType(), keep)
+		if err != nil {
+			t.Errorf("%v: %v", test.keep, err)
+		} else if err = checkTrueTypeSubset(subset, test.kept); err != nil {
+			t.Errorf("%v: %v", test.keep, err)
+		}
+	}
+
+	font := testTrueType()
+	for _, data := range [][]byte{nil, font[:11], []byte("OTTO\x00\x00\x00\x00\x00\x00\x00\x00"), font[:60]} {
+		if _, err := subsetTrueType(data, map[uint16]bool{1: true}); err != errTrueType {
+			t.Errorf("% x: %v, expected %v", data, err, errTrueType)
+		}
+	}
+}
+
+// Test that the font subsetter replaces the embedded TrueType program of a Type 0 font with a
+// subset of the glyphs drawn on the pages, tagging the font names.
+func TestSubsetEmbeddedFont(t *testing.T) {
+	font := testTrueType()
+	input := rawPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		contentStream("BT /F1 12 Tf 72 700 Td <00030001> Tj ET"),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Test /Encoding /Identity-H /DescendantFonts [6 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /Test /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor 7 0 R /CIDToGIDMap /Identity >>",
+		"<< /Type /FontDescriptor /FontName /Test /Flags 4 /FontBBox [0 0 1000 1000] /ItalicAngle 0 /Ascent 800 /Descent -200 /CapHeight 700 /StemV 80 /FontFile2 8 0 R >>",
+		fmt.Sprintf("<< /Length %d /Length1 %d >>\nstream\n%s\nendstream", len(font), len(font), font),
+	)
+	pdf, err := loadPDF(bytes.NewReader(input), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := newFontSubsetter().apply(pdf.PageList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fonts := core.TraceToDirectObject(pages[0].Resources.Font).(*core.PdfObjectDictionary)
+	type0 := core.TraceToDirectObject(fonts.Get("F1")).(*core.PdfObjectDictionary)
+	key, program := fontFile(type0)
+	if key != "FontFile2" || program == nil {
+		t.Fatalf("font program %s", key)
+	}
+	subset, err := core.DecodeStream(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = checkTrueTypeSubset(subset, []int{0, 1, 2, 3, 4}); err != nil {
+		t.Error(err)
+	}
+	if name, ok := core.TraceToDirectObject(type0.Get("BaseFont")).(*core.PdfObjectName); !ok || !subsetPrefix.MatchString(string(*name)) {
+		t.Errorf("BaseFont %v, expected a subset tag", type0.Get("BaseFont"))
+	}
+}
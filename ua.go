@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/core"
+)
+
+// xmpTitle matches a non-empty dc:title in XMP metadata
+var xmpTitle = regexp.MustCompile(`(?s)<dc:title>.*?<rdf:li[^>]*>\s*[^<\s]`)
+
+// uaViolations checks the PDF rs against the basic PDF/UA (ISO 14289-1) requirements of tagged
+// content, a document title and a document language, returning a description of each unmet
+// requirement
+func uaViolations(rs io.ReadSeeker) ([]string, error) {
+	parser, err := core.NewParser(rs)
+	if err != nil {
+		return nil, err
+	}
+	trailer := parser.GetTrailer()
+	if trailer == nil {
+		return nil, errors.New("missing trailer")
+	}
+	catalog, ok := traceObject(parser, trailer.Get("Root")).(*core.PdfObjectDictionary)
+	if !ok {
+		return nil, errors.New("invalid catalog")
+	}
+	dict := func(obj core.PdfObject) *core.PdfObjectDictionary {
+		d, _ := traceObject(parser, obj).(*core.PdfObjectDictionary)
+		if d == nil {
+			d = core.MakeDict()
+		}
+		return d
+	}
+
+	var violations []string
+
+	//tagged content (7.1)
+	if marked, ok := traceObject(parser, dict(catalog.Get("MarkInfo")).Get("Marked")).(*core.PdfObjectBool); !ok || !bool(*marked) {
+		violations = append(violations, "not marked as tagged (MarkInfo Marked is not true)")
+	}
+	if _, ok := traceObject(parser, catalog.Get("StructTreeRoot")).(*core.PdfObjectNull); ok {
+		violations = append(violations, "no structure tree (StructTreeRoot missing)")
+	}
+
+	//document title (7.1), shown by viewers instead of the file name
+	var title bool
+	if s, ok := traceObject(parser, dict(trailer.Get("Info")).Get("Title")).(*core.PdfObjectString); ok && strings.TrimSpace(string(*s)) != "" {
+		title = true
+	}
+	if stream, ok := traceObject(parser, catalog.Get("Metadata")).(*core.PdfObjectStream); ok {
+		if xmp, err := core.DecodeStream(stream); err == nil && xmpTitle.Match(xmp) {
+			title = true
+		}
+	}
+	if !title {
+		violations = append(violations, "no document title")
+	}
+	if show, ok := traceObject(parser, dict(catalog.Get("ViewerPreferences")).Get("DisplayDocTitle")).(*core.PdfObjectBool); !ok || !bool(*show) {
+		violations = append(violations, "title not displayed (ViewerPreferences DisplayDocTitle is not true)")
+	}
+
+	//document language (7.2)
+	if lang, ok := traceObject(parser, catalog.Get("Lang")).(*core.PdfObjectString); !ok || strings.TrimSpace(string(*lang)) == "" {
+		violations = append(violations, "no document language (Lang missing)")
+	}
+
+	return violations, nil
+}
+
+// traceObject looks up obj if it is a reference, returning null if obj is missing or broken
+func traceObject(parser *core.PdfParser, obj core.PdfObject) core.PdfObject {
+	if obj == nil {
+		return core.MakeNull()
+	}
+	traced, err := parser.Trace(obj)
+	if err != nil || traced == nil {
+		return core.MakeNull()
+	}
+	return traced
+}
+
+// reportUA logs each basic PDF/UA requirement that the output fn, with content data, doesn't meet
+func reportUA(fn string, data []byte) {
+	violations, err := uaViolations(bytes.NewReader(data))
+	if err != nil {
+		warning("Unable to check %s for PDF/UA: %v", fn, err)
+		return
+	}
+	for _, v := range violations {
+		warning("PDF/UA: %s: %s", fn, v)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/unidoc/unidoc/pdf/core"
+)
+
+// validationIssue is a structural problem found by the validate command
+type validationIssue struct {
+	objNum  int64 //0 for the trailer
+	offset  int64 //-1 if unknown
+	problem string
+}
+
+func (vi validationIssue) String() string {
+	s := "trailer"
+	if vi.objNum != 0 {
+		s = fmt.Sprintf("object %d", vi.objNum)
+	}
+	if vi.offset >= 0 {
+		s += fmt.Sprintf(" at offset %d", vi.offset)
+	}
+	return s + ": " + vi.problem
+}
+
+// validationResult is what validate reports about a PDF with -output json
+type validationResult struct {
+	File   string        `json:"file"`
+	Valid  bool          `json:"valid"`
+	Error  string        `json:"error,omitempty"` //set if the PDF couldn't be checked
+	Issues []issueResult `json:"issues"`
+}
+
+// issueResult is a validationIssue in a validationResult
+type issueResult struct {
+	Object  int64  `json:"object"` //0 for the trailer
+	Offset  *int64 `json:"offset,omitempty"`
+	Problem string `json:"problem"`
+}
+
+// runValidate checks the structure of PDFs, such as the outputs of a split, and reports each
+// problem found. It exits with status 1 if any PDF has problems.
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	output := outputFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage of pdf-splitter validate: file.pdf ...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	startOutput("validate", output)
+	defer finishOutput()
+
+	if fs.NArg() == 0 {
+		usage(fs)
+	}
+
+	valid := true
+	for _, fn := range fs.Args() {
+		f, err := openInput(fn)
+		if err != nil {
+			fatalInput(fn, err)
+		}
+		issues, err := validatePDF(f)
+		f.Close()
+		if err != nil {
+			checkEncryption(fn, err)
+			valid = false
+			if jsonReport != nil {
+				addResult(validationResult{File: fn, Error: err.Error(), Issues: []issueResult{}})
+				continue
+			}
+			fmt.Printf("%s: unreadable: %v\n", fn, err)
+			continue
+		}
+		if len(issues) > 0 {
+			valid = false
+		}
+
+		if jsonReport != nil {
+			res := validationResult{File: fn, Valid: len(issues) == 0, Issues: []issueResult{}}
+			for _, vi := range issues {
+				ir := issueResult{Object: vi.objNum, Problem: vi.problem}
+				if vi.offset >= 0 {
+					ir.Offset = &vi.offset
+				}
+				res.Issues = append(res.Issues, ir)
+			}
+			addResult(res)
+			continue
+		}
+		for _, vi := range issues {
+			fmt.Printf("%s: %s\n", fn, vi)
+		}
+		if len(issues) == 0 {
+			fmt.Printf("%s: no problems found\n", fn)
+		}
+	}
+
+	if !valid {
+		exit(exitFailure)
+	}
+}
+
+// validatePDF checks the page tree, references, stream lengths and stream filters of the PDF rs.
+// It returns an error if the PDF can't be checked at all, e.g. as it needs a user password.
+func validatePDF(rs io.ReadSeeker) ([]validationIssue, error) {
+	parser, err := core.NewParser(rs)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted, err := parser.IsEncrypted(); err != nil {
+		return nil, err
+	} else if encrypted {
+		if ok, err := parser.Decrypt(nil); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, errors.New("user password needed")
+		}
+	}
+
+	//parse leniently, turning what is worked around into issues
+	var issues []validationIssue
+	parser.SetLenient(func(w core.Warning) {
+		issues = append(issues, validationIssue{w.ObjectNumber, w.Offset, w.Problem + ", " + w.Action})
+	})
+
+	trailer := parser.GetTrailer()
+	if trailer == nil {
+		return nil, errors.New("missing trailer")
+	}
+
+	objNums := parser.GetObjectNums()
+	defined := make(map[int64]bool, len(objNums))
+	for _, n := range objNums {
+		defined[int64(n)] = true
+	}
+
+	//check each object's references and stream data
+	checkRefs := func(objNum int64, obj core.PdfObject) {
+		reported := map[int64]bool{}
+		walkReferences(obj, func(ref *core.PdfObjectReference) {
+			if !defined[ref.ObjectNumber] && !reported[ref.ObjectNumber] {
+				reported[ref.ObjectNumber] = true
+				issues = append(issues, validationIssue{objNum, -1, fmt.Sprintf("reference to missing object %d", ref.ObjectNumber)})
+			}
+		})
+	}
+	checkRefs(0, trailer)
+	for _, n := range objNums {
+		obj, err := parser.LookupByNumber(n)
+		if err != nil {
+			issues = append(issues, validationIssue{int64(n), -1, err.Error()})
+			continue
+		}
+		checkRefs(int64(n), obj)
+
+		if stream, ok := obj.(*core.PdfObjectStream); ok {
+			if _, err := core.DecodeStream(stream); err != nil && !undecodable(err) {
+				issues = append(issues, validationIssue{int64(n), -1, "filter: " + err.Error()})
+			}
+		}
+	}
+
+	//check the page tree from the catalog
+	root, ok := trailer.Get("Root").(*core.PdfObjectReference)
+	if !ok {
+		return append(issues, validationIssue{0, -1, "Root missing"}), nil
+	}
+	catalog, ok := lookupDict(parser, root.ObjectNumber)
+	if !ok {
+		return append(issues, validationIssue{root.ObjectNumber, -1, "catalog is not a dictionary"}), nil
+	}
+	pages, ok := catalog.Get("Pages").(*core.PdfObjectReference)
+	if !ok {
+		return append(issues, validationIssue{root.ObjectNumber, -1, "catalog Pages missing or not a reference"}), nil
+	}
+	pt := pageTreeCheck{parser: parser, visited: map[int64]bool{}}
+	pt.check(pages.ObjectNumber, 0)
+	issues = append(issues, pt.issues...)
+
+	return issues, nil
+}
+
+// pageTreeCheck checks the integrity of a page tree
+type pageTreeCheck struct {
+	parser  *core.PdfParser
+	visited map[int64]bool
+	issues  []validationIssue
+}
+
+// check checks the page tree node objNum, whose parent is the node parent (0 for the root),
+// and returns the number of pages below it
+func (pt *pageTreeCheck) check(objNum, parent int64) int {
+	issue := func(format string, a ...interface{}) {
+		pt.issues = append(pt.issues, validationIssue{objNum, -1, fmt.Sprintf(format, a...)})
+	}
+
+	if pt.visited[objNum] {
+		issue("page tree cycle")
+		return 0
+	}
+	pt.visited[objNum] = true
+
+	node, ok := lookupDict(pt.parser, objNum)
+	if !ok {
+		issue("page tree node is not a dictionary")
+		return 0
+	}
+	if parent != 0 {
+		if ref, ok := node.Get("Parent").(*core.PdfObjectReference); !ok || ref.ObjectNumber != parent {
+			issue("Parent is %v, expected %d 0 R", node.Get("Parent"), parent)
+		}
+	}
+
+	typ, _ := core.TraceToDirectObject(node.Get("Type")).(*core.PdfObjectName)
+	switch {
+	case typ == nil:
+		issue("page tree node Type missing")
+		return 0
+	case *typ == "Page":
+		return 1
+	case *typ != "Pages":
+		issue("page tree node Type is %s, expected Page or Pages", *typ)
+		return 0
+	}
+
+	kidsObj, err := pt.parser.Trace(node.Get("Kids"))
+	if err != nil {
+		issue("Kids: %v", err)
+		return 0
+	}
+	kids, ok := kidsObj.(*core.PdfObjectArray)
+	if !ok {
+		issue("Kids is not an array")
+		return 0
+	}
+
+	var count int
+	for _, kid := range *kids {
+		ref, ok := kid.(*core.PdfObjectReference)
+		if !ok {
+			issue("Kids entry %s is not a reference", kid)
+			continue
+		}
+		count += pt.check(ref.ObjectNumber, objNum)
+	}
+
+	if n, ok := core.TraceToDirectObject(node.Get("Count")).(*core.PdfObjectInteger); !ok {
+		issue("Count missing")
+	} else if int(*n) != count {
+		issue("Count is %d, but %d pages found below", *n, count)
+	}
+	return count
+}
+
+// lookupDict returns the dictionary object objNum, or false if it is not a dictionary
+func lookupDict(parser *core.PdfParser, objNum int64) (*core.PdfObjectDictionary, bool) {
+	obj, err := parser.LookupByNumber(int(objNum))
+	if err != nil {
+		return nil, false
+	}
+	if stream, ok := obj.(*core.PdfObjectStream); ok {
+		return stream.PdfObjectDictionary, true
+	}
+	dict, ok := core.TraceToDirectObject(obj).(*core.PdfObjectDictionary)
+	return dict, ok
+}
+
+// walkReferences calls f for each reference in obj, without following them
+func walkReferences(obj core.PdfObject, f func(ref *core.PdfObjectReference)) {
+	switch t := obj.(type) {
+	case *core.PdfObjectReference:
+		f(t)
+	case *core.PdfIndirectObject:
+		walkReferences(t.PdfObject, f)
+	case *core.PdfObjectStream:
+		walkReferences(t.PdfObjectDictionary, f)
+	case *core.PdfObjectArray:
+		for _, o := range *t {
+			walkReferences(o, f)
+		}
+	case *core.PdfObjectDictionary:
+		for _, key := range t.Keys() {
+			walkReferences(t.Get(key), f)
+		}
+	}
+}
+
+// undecodable returns true if err is for a filter that can't be decoded, only passed through,
+// so the data can't be checked
+func undecodable(err error) bool {
+	return err == core.ErrNoCCITTFaxDecode || err == core.ErrNoJBIG2Decode || err == core.ErrNoJPXDecode
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc/pdf/core"
+	"github.com/unidoc/unidoc/pdf/model"
+)
+
+// pageLayouts are the PDF page layouts by -page-layout name
+var pageLayouts = map[string]core.PdfObjectName{
+	"single":           "SinglePage",
+	"one-column":       "OneColumn",
+	"two-column-left":  "TwoColumnLeft",
+	"two-column-right": "TwoColumnRight",
+	"two-page-left":    "TwoPageLeft",
+	"two-page-right":   "TwoPageRight",
+}
+
+// pageModes are the PDF page modes, the panel outputs open with, by -page-mode name
+var pageModes = map[string]core.PdfObjectName{
+	"none":        "UseNone",
+	"outlines":    "UseOutlines",
+	"thumbnails":  "UseThumbs",
+	"attachments": "UseAttachments",
+	"layers":      "UseOC",
+	"full-screen": "FullScreen",
+}
+
+// openFits are the views of the destination after the page by -open-fit name
+var openFits = map[string]func() []core.PdfObject{
+	"page":    func() []core.PdfObject { return []core.PdfObject{core.MakeName("Fit")} },
+	"width":   func() []core.PdfObject { return []core.PdfObject{core.MakeName("FitH"), core.MakeNull()} },
+	"height":  func() []core.PdfObject { return []core.PdfObject{core.MakeName("FitV"), core.MakeNull()} },
+	"visible": func() []core.PdfObject { return []core.PdfObject{core.MakeName("FitB")} },
+	"actual": func() []core.PdfObject {
+		return []core.PdfObject{core.MakeName("XYZ"), core.MakeNull(), core.MakeNull(), core.MakeInteger(1)}
+	},
+}
+
+// openView returns the view of the destination after the page for an -open-fit name, or a zoom
+// percentage such as "150" or "150%", and whether fit is either
+func openView(fit string) ([]core.PdfObject, bool) {
+	if view, ok := openFits[fit]; ok {
+		return view(), true
+	}
+	zoom, err := strconv.ParseFloat(strings.TrimSuffix(fit, "%"), 64)
+	if err != nil || zoom <= 0 || math.IsInf(zoom, 0) {
+		return nil, false
+	}
+	return []core.PdfObject{core.MakeName("XYZ"), core.MakeNull(), core.MakeNull(), core.MakeFloat(zoom / 100)}, true
+}
+
+// viewerEntries are the catalog entries of the initial view, which outputs of the fast path
+// would lose
+var viewerEntries = []core.PdfObjectName{"ViewerPreferences", "OpenAction", "PageLayout"}
+
+// viewerSettings is the initial view of outputs: the viewer preferences, page layout and open
+// action of the input, the open action kept only if its page is in the output, and the page mode
+// of -page-mode
+type viewerSettings struct {
+	preferences *core.PdfObjectDictionary //if set, a copy of those of the input
+	layout      core.PdfObject            //if set, the PageLayout name
+	mode        core.PdfObject            //if set, the PageMode name, replacing that of outputs with an outline
+	openPage    int                       //1-based input page of the open action, 0 if none
+	openView    []core.PdfObject          //the destination after the page, e.g. /XYZ left top zoom
+	fit         bool                      //if set, openView is -open-fit, opening the first page of outputs without the open action page
+}
+
+// newViewerSettings returns the initial view of outputs of pdf. layout, mode and fit, if set, are
+// the -page-layout, -page-mode and -open-fit values overriding those of the input.
+func newViewerSettings(pdf *model.PdfReader, layout, mode, fit string) (*viewerSettings, error) {
+	r, err := newOutlineReader(pdf)
+	if err != nil {
+		return nil, err
+	}
+	v := &viewerSettings{}
+
+	//the print page ranges refer to the input pages, so they are dropped
+	if prefs, ok := r.resolve(r.catalog.Get("ViewerPreferences")).(*core.PdfObjectDictionary); ok {
+		v.preferences = core.MakeDict()
+		for _, key := range prefs.Keys() {
+			if key != "PrintPageRange" {
+				v.preferences.Set(key, r.resolve(prefs.Get(key)))
+			}
+		}
+	}
+	if name, ok := r.resolve(r.catalog.Get("PageLayout")).(*core.PdfObjectName); ok {
+		v.layout = name
+	}
+	if layout != "" {
+		v.layout = core.MakeName(string(pageLayouts[layout]))
+	}
+	if mode != "" {
+		v.mode = core.MakeName(string(pageModes[mode]))
+	}
+
+	//only destinations of pages are kept, not actions such as scripts or launching files
+	if open := r.catalog.Get("OpenAction"); open != nil {
+		var dest *core.PdfObjectArray
+		if v.openPage, dest = r.pageDest(open); v.openPage > 0 {
+			for _, obj := range (*dest)[1:] {
+				v.openView = append(v.openView, r.resolve(obj))
+			}
+		} else {
+			logInfo("Dropping the open action of the input, which isn't a page destination")
+		}
+	}
+	if fit != "" {
+		v.openView, v.fit = openView(fit)
+	}
+
+	return v, nil
+}
+
+// openIndex returns the index of the page of pages to open outputs at, given the 1-based input
+// page numbers of pages, or -1 to leave outputs without an open action
+func (v *viewerSettings) openIndex(pages []*model.PdfPage, inputPages map[*model.PdfPage]int) int {
+	for i, p := range pages {
+		if v.openPage > 0 && inputPages[p] == v.openPage {
+			return i
+		}
+	}
+	if v.fit && len(pages) > 0 {
+		return 0
+	}
+	return -1
+}
+
+// apply sets the initial view of an output written by w with pages, opening at pages[open]
+// unless open is -1
+func (v *viewerSettings) apply(w *model.PdfWriter, pages []*model.PdfPage, open int) error {
+	if v.preferences != nil {
+		if err := w.SetCatalogEntry("ViewerPreferences", v.preferences); err != nil {
+			return err
+		}
+	}
+	if v.layout != nil {
+		if err := w.SetCatalogEntry("PageLayout", v.layout); err != nil {
+			return err
+		}
+	}
+	if v.mode != nil {
+		if err := w.SetCatalogEntry("PageMode", v.mode); err != nil {
+			return err
+		}
+	}
+	if open >= 0 && open < len(pages) {
+		dest := core.MakeArray(pages[open].GetPageAsIndirectObject())
+		if len(v.openView) == 0 {
+			dest.Append(core.MakeName("Fit"))
+		}
+		for _, obj := range v.openView {
+			dest.Append(obj)
+		}
+		return w.SetCatalogEntry("OpenAction", dest)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+// WinZip AES encryption (AE-2), as read by 7-Zip and WinZip
+const (
+	zipMethodAES   = 99
+	zipAESExtraID  = 0x9901
+	zipAESStrength = 3 //AES-256
+	zipAESKeyLen   = 32
+	zipAESSaltLen  = 16
+	zipAESMacLen   = 10
+	zipAESRounds   = 1000
+)
+
+// zipArchive collects the outputs in a single ZIP file, optionally encrypted with a password
+type zipArchive struct {
+	f        *os.File
+	zw       *zip.Writer
+	password []byte
+}
+
+// createZip creates the ZIP file fn. If password is set, entries are AES-256 encrypted.
+func createZip(fn string, password string) (*zipArchive, error) {
+	f, err := os.Create(fn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ZIP file %s: %w", fn, err)
+	}
+
+	return &zipArchive{f: f, zw: zip.NewWriter(f), password: []byte(password)}, nil
+}
+
+// add writes the entry name to the archive from src, returning its uncompressed size
+func (a *zipArchive) add(name string, src io.WriterTo) (int64, error) {
+	if len(a.password) == 0 {
+		w, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return 0, fmt.Errorf("unable to add %s to ZIP file: %w", name, err)
+		}
+		return src.WriteTo(w)
+	}
+
+	//compress first, encryption output doesn't compress
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return 0, err
+	}
+	size, err := src.WriteTo(fw)
+	if err != nil {
+		return 0, err
+	}
+	if err = fw.Close(); err != nil {
+		return 0, err
+	}
+
+	payload, err := zipAESEncrypt(a.password, compressed.Bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	//extra field: vendor version 2 (AE-2), vendor "AE", strength, actual compression method
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], zipAESExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], 2)
+	copy(extra[6:], "AE")
+	extra[8] = zipAESStrength
+	binary.LittleEndian.PutUint16(extra[9:], zip.Deflate)
+
+	//AE-2 leaves the CRC out, the authentication code covers the data instead.
+	//Raw entries get no timestamp or UTF-8 name flag from zip.Writer, so set them here.
+	now := time.Now()
+	fh := &zip.FileHeader{
+		Name:               name,
+		Method:             zipMethodAES,
+		Flags:              0x1,
+		Modified:           now,
+		ModifiedDate:       uint16((now.Year()-1980)<<9 | int(now.Month())<<5 | now.Day()),
+		ModifiedTime:       uint16(now.Hour()<<11 | now.Minute()<<5 | now.Second()/2),
+		Extra:              extra,
+		CompressedSize64:   uint64(len(payload)),
+		UncompressedSize64: uint64(size),
+	}
+	for _, r := range name {
+		if r >= utf8.RuneSelf {
+			fh.Flags |= 0x800
+			break
+		}
+	}
+	w, err := a.zw.CreateRaw(fh)
+	if err != nil {
+		return 0, fmt.Errorf("unable to add %s to ZIP file: %w", name, err)
+	}
+	if _, err = w.Write(payload); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// Close finishes the archive
+func (a *zipArchive) Close() error {
+	if err := a.zw.Close(); err != nil {
+		a.f.Close()
+		return err
+	}
+	return a.f.Close()
+}
+
+// zipAESEncrypt returns salt, password verifier, encrypted data and authentication code
+func zipAESEncrypt(password, data []byte) ([]byte, error) {
+	salt := make([]byte, zipAESSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	keys, err := pbkdf2.Key(sha1.New, string(password), salt, zipAESRounds, 2*zipAESKeyLen+2)
+	if err != nil {
+		return nil, err
+	}
+	encKey, macKey, verifier := keys[:zipAESKeyLen], keys[zipAESKeyLen:2*zipAESKeyLen], keys[2*zipAESKeyLen:]
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	//CTR mode with a little endian counter starting at 1, unlike crypto/cipher's CTR
+	out := make([]byte, len(data))
+	var counter, stream [aes.BlockSize]byte
+	for i := 0; i < len(data); i += aes.BlockSize {
+		for j := range counter {
+			counter[j]++
+			if counter[j] != 0 {
+				break
+			}
+		}
+		block.Encrypt(stream[:], counter[:])
+		for j := i; j < len(data) && j < i+aes.BlockSize; j++ {
+			out[j] = data[j] ^ stream[j-i]
+		}
+	}
+
+	mac := hmac.New(sha1.New, macKey)
+	mac.Write(out)
+
+	payload := make([]byte, 0, len(salt)+len(verifier)+len(out)+zipAESMacLen)
+	payload = append(payload, salt...)
+	payload = append(payload, verifier...)
+	payload = append(payload, out...)
+	payload = append(payload, mac.Sum(nil)[:zipAESMacLen]...)
+
+	return payload, nil
+}
+
+// seekBuffer is an in-memory io.WriteSeeker for writing a PDF that is processed further.
+// The PDF writer only seeks to find its current offset.
+type seekBuffer struct {
+	bytes.Buffer
+}
+
+func (b *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekCurrent {
+		return 0, errors.New("seekBuffer only reports the current offset")
+	}
+	return int64(b.Len()), nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"path"
+	"testing"
+)
+
+// Test the PBKDF2-HMAC-SHA1 key derivation of AE-2 against the vectors of RFC 6070.
+func TestZipPBKDF2(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iter, keyLen   int
+		expected       string
+	}{
+		{"password", "salt", 1, 20, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"password", "salt", 2, 20, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{"password", "salt", 4096, 20, "4b007901b765489abead49d926f721d065a429c1"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 25, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+		{"pass\x00word", "sa\x00lt", 4096, 16, "56fa6aa75548099dcc37d7f03425e0c3"},
+	}
+	for _, test := range tests {
+		key, err := pbkdf2.Key(sha1.New, test.password, []byte(test.salt), test.iter, test.keyLen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(key); got != test.expected {
+			t.Errorf("%q, %q, %d: %s, expected %s", test.password, test.salt, test.iter, got, test.expected)
+		}
+	}
+}
+
+// Test that an encrypted ZIP entry has the AE-2 extra field and no CRC, and that its salt,
+// password verifier and authentication code let it be decrypted again.
+func TestZipAESEntry(t *testing.T) {
+	fn := path.Join(t.TempDir(), "out.zip")
+	a, err := createZip(fn, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := bytes.Repeat([]byte("%PDF-1.7 page content "), 100)
+	if _, err = a.add("part 1.pdf", bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.OpenReader(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if len(zr.File) != 1 {
+		t.Fatalf("%d entries, expected 1", len(zr.File))
+	}
+	f := zr.File[0]
+	if f.Name != "part 1.pdf" || f.Method != zipMethodAES || f.Flags&0x1 == 0 || f.CRC32 != 0 {
+		t.Errorf("entry %s, method %d, flags %#x, CRC %#x", f.Name, f.Method, f.Flags, f.CRC32)
+	}
+	expectedExtra := []byte{0x01, 0x99, 7, 0, 2, 0, 'A', 'E', 3, 8, 0}
+	if !bytes.Contains(f.Extra, expectedExtra) {
+		t.Errorf("extra field % x, expected % x", f.Extra, expectedExtra)
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := io.ReadAll(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) < zipAESSaltLen+2+zipAESMacLen {
+		t.Fatalf("payload of %d bytes", len(payload))
+	}
+	salt, verifier := payload[:zipAESSaltLen], payload[zipAESSaltLen:zipAESSaltLen+2]
+	data, mac := payload[zipAESSaltLen+2:len(payload)-zipAESMacLen], payload[len(payload)-zipAESMacLen:]
+
+	keys, err := pbkdf2.Key(sha1.New, "secret", salt, 1000, 66)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(verifier, keys[64:]) {
+		t.Errorf("password verifier % x, expected % x", verifier, keys[64:])
+	}
+	h := hmac.New(sha1.New, keys[32:64])
+	h.Write(data)
+	if expected := h.Sum(nil)[:10]; !bytes.Equal(mac, expected) {
+		t.Errorf("authentication code % x, expected % x", mac, expected)
+	}
+
+	block, err := aes.NewCipher(keys[:32])
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := make([]byte, len(data))
+	var counter, stream [aes.BlockSize]byte
+	for i := 0; i < len(data); i += aes.BlockSize {
+		binary.LittleEndian.PutUint64(counter[:], uint64(i/aes.BlockSize+1))
+		block.Encrypt(stream[:], counter[:])
+		for j := i; j < len(data) && j < i+aes.BlockSize; j++ {
+			compressed[j] = data[j] ^ stream[j-i]
+		}
+	}
+	got, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) || f.UncompressedSize64 != uint64(len(content)) {
+		t.Errorf("decrypted %d bytes, size %d, expected the %d bytes added", len(got), f.UncompressedSize64, len(content))
+	}
+}